@@ -2,6 +2,7 @@ package UserAPIs
 
 import (
 	"PTS/controllers"
+	"PTS/utils"
 
 	"github.com/gorilla/mux"
 )
@@ -13,6 +14,27 @@ func RegisterAuthRoutes(router *mux.Router) {
 	courierController := &controllers.CourierController{}
 	adminController := &controllers.AdminController{}
 	ownerController := &controllers.OwnerController{}
+	locationController := &controllers.LocationController{}
+	orderController := &controllers.OrderController{}
+	streamController := &controllers.StreamController{}
+	webhookController := &controllers.WebhookController{}
+	notificationController := &controllers.NotificationController{}
+	pricingController := &controllers.PricingController{}
+	rateCardController := &controllers.RateCardController{}
+	promoController := &controllers.PromoController{}
+	paymentController := &controllers.PaymentController{}
+	cashController := &controllers.CashController{}
+	earningsController := &controllers.EarningsController{}
+	invoiceController := &controllers.InvoiceController{}
+	proofOfDeliveryController := &controllers.ProofOfDeliveryController{}
+	deliveryCodeController := &controllers.DeliveryCodeController{}
+	blobController := &controllers.BlobController{}
+	deliveryAttemptController := &controllers.DeliveryAttemptController{}
+	deliveryWindowController := &controllers.DeliveryWindowController{}
+	recurringOrderController := &controllers.RecurringOrderController{}
+	addressController := &controllers.AddressController{}
+	serviceZoneController := &controllers.ServiceZoneController{}
+	batchController := &controllers.BatchController{}
 
 	// Routes for Normal Users
 	router.HandleFunc("/users/register", userController.Register).Methods("POST") // Corrected to /users/register
@@ -22,6 +44,127 @@ func RegisterAuthRoutes(router *mux.Router) {
 	router.HandleFunc("/couriers/register", courierController.CourierRegister).Methods("POST")
 	router.HandleFunc("/couriers/login", courierController.CourierLogin).Methods("POST")
 
+	// Routes for Courier availability and shifts
+	router.HandleFunc("/couriers/status", utils.RequireAuth(courierController.GetCourierStatus)).Methods("GET")
+	router.HandleFunc("/couriers/availability", utils.RequireAuth(courierController.SetAvailability)).Methods("PUT")
+	router.HandleFunc("/couriers/heartbeat", utils.RequireAuth(courierController.Heartbeat)).Methods("POST")
+	router.HandleFunc("/couriers/shifts/start", utils.RequireAuth(courierController.StartShift)).Methods("POST")
+	router.HandleFunc("/couriers/shifts/end", utils.RequireAuth(courierController.EndShift)).Methods("POST")
+	router.HandleFunc("/couriers/breaks/start", utils.RequireAuth(courierController.StartBreak)).Methods("POST")
+	router.HandleFunc("/couriers/breaks/end", utils.RequireAuth(courierController.EndBreak)).Methods("POST")
+
+	// Routes for Courier multi-stop routes
+	router.HandleFunc("/couriers/routes", utils.RequireAuth(courierController.SuggestRoute)).Methods("POST")
+	router.HandleFunc("/couriers/routes/current", utils.RequireAuth(courierController.GetCurrentRoute)).Methods("GET")
+	router.HandleFunc("/couriers/routes/{id}/accept", utils.RequireAuth(courierController.AcceptRoute)).Methods("POST")
+
+	// Routes for vehicle profiles and batching orders onto couriers within their capacity
+	router.HandleFunc("/vehicles", batchController.ListVehicleProfiles).Methods("GET")
+	router.HandleFunc("/couriers/{id}/batch-suggestion", utils.RequireAuth(batchController.SuggestBatch)).Methods("GET")
+	router.HandleFunc("/orders/batches", utils.RequireAuth(batchController.AssignBatch)).Methods("POST")
+
+	// Routes for Orders
+	router.HandleFunc("/orders", utils.RequireAuth(orderController.PlaceOrder)).Methods("POST")
+	router.HandleFunc("/orders", utils.RequireAuth(orderController.ListOrders)).Methods("GET")
+	router.HandleFunc("/orders/{id}", utils.RequireAuth(orderController.GetOrder)).Methods("GET")
+	router.HandleFunc("/orders/{id}", utils.RequireAuth(orderController.CancelOrder)).Methods("DELETE")
+	router.HandleFunc("/orders/{id}/assign", utils.RequireAuth(orderController.AssignOrder)).Methods("PUT")
+	router.HandleFunc("/orders/{id}/status", utils.RequireAuth(orderController.UpdateOrderStatus)).Methods("PUT")
+
+	// Routes for Proof of delivery
+	router.HandleFunc("/orders/{id}/proof-of-delivery", utils.RequireAuth(proofOfDeliveryController.SubmitProofOfDelivery)).Methods("POST")
+	router.HandleFunc("/orders/{id}/proof-of-delivery", utils.RequireAuth(proofOfDeliveryController.GetProofOfDelivery)).Methods("GET")
+	router.HandleFunc("/orders/{id}/proof-of-delivery/{kind:signature|photo}", utils.RequireAuth(proofOfDeliveryController.GetProofOfDeliveryFile)).Methods("GET")
+
+	// Routes for signed attachment downloads (the link itself is the credential)
+	router.HandleFunc("/blobs/{key:.+}", blobController.DownloadBlob).Methods("GET")
+
+	// Routes for Store delivery windows and their capacity
+	router.HandleFunc("/delivery-windows", utils.RequireAuth(deliveryWindowController.GetDeliverySchedule)).Methods("GET")
+	router.HandleFunc("/delivery-windows", utils.RequireAuth(deliveryWindowController.UpdateDeliverySchedule)).Methods("PUT")
+	router.HandleFunc("/stores/{id}/delivery-windows/availability", utils.RequireAuth(deliveryWindowController.GetWindowAvailability)).Methods("GET")
+
+	// Routes for customers' recurring orders and skipped dates
+	router.HandleFunc("/recurring-orders", utils.RequireAuth(recurringOrderController.CreateRecurringOrder)).Methods("POST")
+	router.HandleFunc("/recurring-orders", utils.RequireAuth(recurringOrderController.ListRecurringOrders)).Methods("GET")
+	router.HandleFunc("/recurring-orders/{id}", utils.RequireAuth(recurringOrderController.GetRecurringOrder)).Methods("GET")
+	router.HandleFunc("/recurring-orders/{id}", utils.RequireAuth(recurringOrderController.CancelRecurringOrder)).Methods("DELETE")
+	router.HandleFunc("/recurring-orders/{id}/pause", utils.RequireAuth(recurringOrderController.PauseRecurringOrder)).Methods("POST")
+	router.HandleFunc("/recurring-orders/{id}/resume", utils.RequireAuth(recurringOrderController.ResumeRecurringOrder)).Methods("POST")
+	router.HandleFunc("/recurring-orders/{id}/skips", utils.RequireAuth(recurringOrderController.SkipOccurrence)).Methods("POST")
+	router.HandleFunc("/recurring-orders/{id}/skips/{date}", utils.RequireAuth(recurringOrderController.UnskipOccurrence)).Methods("DELETE")
+
+	// Routes for customers' address books and address lookup
+	router.HandleFunc("/addresses", utils.RequireAuth(addressController.ListAddresses)).Methods("GET")
+	router.HandleFunc("/addresses", utils.RequireAuth(addressController.CreateAddress)).Methods("POST")
+	router.HandleFunc("/addresses/{id}", utils.RequireAuth(addressController.GetAddress)).Methods("GET")
+	router.HandleFunc("/addresses/{id}", utils.RequireAuth(addressController.UpdateAddress)).Methods("PUT")
+	router.HandleFunc("/addresses/{id}", utils.RequireAuth(addressController.DeleteAddress)).Methods("DELETE")
+	router.HandleFunc("/geocode", utils.RequireAuth(addressController.SearchAddresses)).Methods("GET")
+
+	// Routes for Store service zones (owners manage them, anyone signed in can check them)
+	router.HandleFunc("/service-zones", utils.RequireAuth(serviceZoneController.CreateServiceZone)).Methods("POST")
+	router.HandleFunc("/service-zones", utils.RequireAuth(serviceZoneController.ListServiceZones)).Methods("GET")
+	router.HandleFunc("/service-zones/{id}", utils.RequireAuth(serviceZoneController.UpdateServiceZone)).Methods("PUT")
+	router.HandleFunc("/service-zones/{id}", utils.RequireAuth(serviceZoneController.DeleteServiceZone)).Methods("DELETE")
+	router.HandleFunc("/stores/{id}/service-zones", utils.RequireAuth(serviceZoneController.GetStoreServiceZones)).Methods("GET")
+	router.HandleFunc("/stores/{id}/service-zones/check", utils.RequireAuth(serviceZoneController.CheckServiceArea)).Methods("GET")
+
+	// Routes for Failed delivery attempts
+	router.HandleFunc("/orders/{id}/failed-attempts", utils.RequireAuth(deliveryAttemptController.RecordFailedAttempt)).Methods("POST")
+	router.HandleFunc("/orders/{id}/failed-attempts", utils.RequireAuth(deliveryAttemptController.ListFailedAttempts)).Methods("GET")
+
+	// Routes for Delivery handoff codes
+	router.HandleFunc("/orders/{id}/delivery-code", utils.RequireAuth(deliveryCodeController.GetDeliveryCode)).Methods("GET")
+	router.HandleFunc("/orders/{id}/delivery-code/override", utils.RequireAuth(deliveryCodeController.OverrideDeliveryCode)).Methods("POST")
+
+	// Routes for delivery price quotes
+	router.HandleFunc("/quotes", utils.RequireAuth(pricingController.CreateQuote)).Methods("POST")
+
+	// Routes for Store rate cards (owners publish, staff view)
+	router.HandleFunc("/rate-cards", utils.RequireAuth(rateCardController.CreateRateCard)).Methods("POST")
+	router.HandleFunc("/rate-cards", utils.RequireAuth(rateCardController.ListRateCards)).Methods("GET")
+	router.HandleFunc("/rate-cards/current", utils.RequireAuth(rateCardController.GetCurrentRateCard)).Methods("GET")
+	router.HandleFunc("/rate-cards/{version:[0-9]+}", utils.RequireAuth(rateCardController.GetRateCardVersion)).Methods("GET")
+
+	// Routes for Order payments
+	router.HandleFunc("/orders/{id}/payment", utils.RequireAuth(paymentController.CreatePayment)).Methods("POST")
+	router.HandleFunc("/orders/{id}/payment", utils.RequireAuth(paymentController.GetPayment)).Methods("GET")
+	router.HandleFunc("/orders/{id}/payment/capture", utils.RequireAuth(paymentController.CapturePayment)).Methods("POST")
+	router.HandleFunc("/orders/{id}/payment/refund", utils.RequireAuth(paymentController.RefundPayment)).Methods("POST")
+
+	// Routes for Invoices and statements
+	router.HandleFunc("/orders/{id}/invoice", utils.RequireAuth(invoiceController.GetOrderInvoice)).Methods("GET")
+	router.HandleFunc("/stores/statements/{month}", utils.RequireAuth(invoiceController.GetStoreStatement)).Methods("GET")
+
+	// Routes for Courier cash reconciliation
+	router.HandleFunc("/couriers/{id}/cash", utils.RequireAuth(cashController.GetCourierCash)).Methods("GET")
+	router.HandleFunc("/couriers/{id}/cash-report", utils.RequireAuth(cashController.GetCourierCashReport)).Methods("GET")
+	router.HandleFunc("/couriers/{id}/settlements", utils.RequireAuth(cashController.SettleCourierCash)).Methods("POST")
+	router.HandleFunc("/couriers/{id}/settlements", utils.RequireAuth(cashController.ListCourierSettlements)).Methods("GET")
+	router.HandleFunc("/stores/cash-report", utils.RequireAuth(cashController.GetStoreCashReport)).Methods("GET")
+
+	// Routes for Courier pay rules, earnings and payouts
+	router.HandleFunc("/courier-pay-rules", utils.RequireAuth(earningsController.CreatePayRules)).Methods("POST")
+	router.HandleFunc("/courier-pay-rules", utils.RequireAuth(earningsController.ListPayRules)).Methods("GET")
+	router.HandleFunc("/courier-pay-rules/current", utils.RequireAuth(earningsController.GetCurrentPayRules)).Methods("GET")
+	router.HandleFunc("/couriers/{id}/earnings", utils.RequireAuth(earningsController.GetCourierEarnings)).Methods("GET")
+	router.HandleFunc("/stores/payout-statement", utils.RequireAuth(earningsController.GetPayoutStatement)).Methods("GET")
+
+	// Routes for Store promo codes (owners only)
+	router.HandleFunc("/promo-codes", utils.RequireAuth(promoController.CreatePromoCode)).Methods("POST")
+	router.HandleFunc("/promo-codes", utils.RequireAuth(promoController.ListPromoCodes)).Methods("GET")
+	router.HandleFunc("/promo-codes/{id}", utils.RequireAuth(promoController.UpdatePromoCode)).Methods("PUT")
+	router.HandleFunc("/promo-codes/{id}", utils.RequireAuth(promoController.DeactivatePromoCode)).Methods("DELETE")
+
+	// Routes for live order updates (Server-Sent Events)
+	router.HandleFunc("/orders/{id}/stream", utils.RequireStreamAuth(streamController.StreamOrder)).Methods("GET")
+	router.HandleFunc("/stores/{id}/stream", utils.RequireStreamAuth(streamController.StreamStore)).Methods("GET")
+
+	// Routes for Courier location tracking
+	router.HandleFunc("/couriers/locations", utils.RequireAuth(locationController.IngestLocations)).Methods("POST")
+	router.HandleFunc("/orders/{id}/tracking", utils.RequireAuth(locationController.GetOrderTracking)).Methods("GET")
+
 	// Routes for Admins Users
 	router.HandleFunc("/admins/register", adminController.AdminRegister).Methods("POST")
 	router.HandleFunc("/admins/login", adminController.AdminLogin).Methods("POST")
@@ -29,4 +172,21 @@ func RegisterAuthRoutes(router *mux.Router) {
 	// Routes for Owners Users
 	router.HandleFunc("/owners/register", ownerController.OwnerRegister).Methods("POST")
 	router.HandleFunc("/owners/login", ownerController.OwnerLogin).Methods("POST")
+
+	// Routes for Store webhooks (owners only)
+	router.HandleFunc("/webhooks", utils.RequireAuth(webhookController.CreateWebhook)).Methods("POST")
+	router.HandleFunc("/webhooks", utils.RequireAuth(webhookController.ListWebhooks)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", utils.RequireAuth(webhookController.UpdateWebhook)).Methods("PUT")
+	router.HandleFunc("/webhooks/{id}", utils.RequireAuth(webhookController.DeleteWebhook)).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/ping", utils.RequireAuth(webhookController.PingWebhook)).Methods("POST")
+	router.HandleFunc("/webhooks/{id}/deliveries", utils.RequireAuth(webhookController.ListWebhookDeliveries)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}", utils.RequireAuth(webhookController.GetWebhookDelivery)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/redeliver", utils.RequireAuth(webhookController.RedeliverWebhook)).Methods("POST")
+
+	// Routes for the Notification inbox and preferences
+	router.HandleFunc("/notifications", utils.RequireAuth(notificationController.ListNotifications)).Methods("GET")
+	router.HandleFunc("/notifications/unread-count", utils.RequireAuth(notificationController.GetUnreadCount)).Methods("GET")
+	router.HandleFunc("/notifications/read", utils.RequireAuth(notificationController.MarkNotificationsRead)).Methods("POST")
+	router.HandleFunc("/notifications/preferences", utils.RequireAuth(notificationController.GetNotificationPreferences)).Methods("GET")
+	router.HandleFunc("/notifications/preferences", utils.RequireAuth(notificationController.UpdateNotificationPreferences)).Methods("PUT")
 }
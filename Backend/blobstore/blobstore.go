@@ -0,0 +1,153 @@
+// Package blobstore stores uploaded files such as proof-of-delivery photos behind a pluggable interface,
+// with a local filesystem implementation and one for S3-compatible object stores.
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty or try to escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Store saves and retrieves blobs by key. Keys are slash-separated paths such as "pod/<order>/<submission>-photo".
+type Store interface {
+	Put(key, contentType string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// List returns the blobs whose keys start with prefix
+	List(prefix string) ([]Object, error)
+}
+
+// Object describes a stored blob
+type Object struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Default is the store used by the API, configured in main
+var Default Store = NewLocalStore("data/blobs")
+
+// tempPrefix marks partially written files, which List skips
+const tempPrefix = ".upload-"
+
+// LocalStore keeps blobs as files under a root directory
+type LocalStore struct {
+	Root string
+}
+
+// NewLocalStore creates a store rooted at dir
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{Root: dir}
+}
+
+// checkKey rejects keys that are empty or contain empty, "." or ".." segments
+func checkKey(key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// path maps a key to a file under the root
+func (s *LocalStore) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so readers never see a partial blob.
+// The content type is not kept; it is sniffed again when the blob is served.
+func (s *LocalStore) Put(key, contentType string, content io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) List(prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(s.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
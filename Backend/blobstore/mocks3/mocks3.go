@@ -0,0 +1,191 @@
+// Package mocks3 is an in-memory stand-in for an S3-compatible object store, implementing the subset of the
+// API blobstore.S3Store uses: object PUT, GET, HEAD and DELETE, ListObjectsV2 and presigned GETs.
+// Requests must be signed with the server's credentials. Buckets are created on first use.
+package mocks3
+
+import (
+	"PTS/blobstore/sigv4"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxListKeys = 1000
+
+// object is one stored blob
+type object struct {
+	data        []byte
+	contentType string
+	modifiedAt  time.Time
+}
+
+// Server serves the mock object store API
+type Server struct {
+	credentials sigv4.Credentials
+	mu          sync.Mutex
+	buckets     map[string]map[string]*object
+}
+
+// New creates an empty store accepting requests signed with the given credentials
+func New(accessKey, secretKey, region string) *Server {
+	return &Server{
+		credentials: sigv4.Credentials{AccessKey: accessKey, SecretKey: secretKey, Region: region, Service: "s3"},
+		buckets:     map[string]map[string]*object{},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.credentials.Verify(r, time.Now()); err != nil {
+		code := "SignatureDoesNotMatch"
+		if err == sigv4.ErrMissingAuth || err == sigv4.ErrExpired {
+			code = "AccessDenied"
+		}
+		writeError(w, http.StatusForbidden, code, err.Error())
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		writeError(w, http.StatusBadRequest, "InvalidBucketName", "bucket is required")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucket)
+	case key == "":
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "unsupported bucket operation")
+	case r.Method == http.MethodPut:
+		s.put(w, r, bucket, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.get(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.buckets[bucket], key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "unsupported object operation")
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != sigv4.UnsignedPayload && hash != sigv4.HashPayload(data) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match")
+		return
+	}
+
+	s.mu.Lock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = map[string]*object{}
+	}
+	s.buckets[bucket][key] = &object{data: data, contentType: r.Header.Get("Content-Type"), modifiedAt: time.Now().UTC()}
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.mu.Lock()
+	obj := s.buckets[bucket][key]
+	s.mu.Unlock()
+	if obj == nil {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+
+	contentType := obj.contentType
+	if override := r.URL.Query().Get("response-content-type"); override != "" {
+		contentType = override
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	w.Header().Set("Last-Modified", obj.modifiedAt.Format(http.TimeFormat))
+	if r.Method == http.MethodGet {
+		w.Write(obj.data)
+	}
+}
+
+// listResult mirrors the ListObjectsV2 response
+type listResult struct {
+	XMLName               xml.Name      `xml:"ListBucketResult"`
+	Name                  string        `xml:"Name"`
+	Prefix                string        `xml:"Prefix"`
+	KeyCount              int           `xml:"KeyCount"`
+	MaxKeys               int           `xml:"MaxKeys"`
+	IsTruncated           bool          `xml:"IsTruncated"`
+	NextContinuationToken string        `xml:"NextContinuationToken,omitempty"`
+	Contents              []listContent `xml:"Contents"`
+}
+
+type listContent struct {
+	Key          string `xml:"Key"`
+	Size         int    `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+// list returns keys in order, using the last returned key as the continuation token
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+		return
+	}
+	prefix, after := query.Get("prefix"), query.Get("continuation-token")
+	maxKeys := maxListKeys
+	if value := query.Get("max-keys"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 && n < maxListKeys {
+			maxKeys = n
+		}
+	}
+
+	s.mu.Lock()
+	var keys []string
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := listResult{Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+	for _, key := range keys {
+		if len(result.Contents) == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = result.Contents[len(result.Contents)-1].Key
+			break
+		}
+		obj := s.buckets[bucket][key]
+		result.Contents = append(result.Contents, listContent{Key: key, Size: len(obj.data), LastModified: obj.modifiedAt.Format(time.RFC3339)})
+	}
+	s.mu.Unlock()
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(result)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
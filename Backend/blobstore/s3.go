@@ -0,0 +1,199 @@
+package blobstore
+
+import (
+	"PTS/blobstore/sigv4"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Store keeps blobs in a bucket of an S3-compatible object store such as AWS S3 or MinIO.
+// Objects are addressed path-style (endpoint/bucket/key) and requests are signed with SigV4.
+type S3Store struct {
+	Endpoint    *url.URL
+	Bucket      string
+	Credentials sigv4.Credentials
+	Client      *http.Client
+}
+
+// NewS3Store creates a store for bucket at endpoint (e.g. "https://s3.eu-central-1.amazonaws.com")
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) (*S3Store, error) {
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	return &S3Store{
+		Endpoint:    parsed,
+		Bucket:      bucket,
+		Credentials: sigv4.Credentials{AccessKey: accessKey, SecretKey: secretKey, Region: region, Service: "s3"},
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// s3Error is the XML error body S3 returns
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// request builds a request for an object key, or for the bucket itself when key is empty
+func (s *S3Store) request(method, key string, query url.Values, body []byte) (*http.Request, error) {
+	if key != "" {
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	target := *s.Endpoint
+	target.Path = s.Endpoint.Path + "/" + s.Bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+	target.RawPath = sigv4.EncodePath(target.Path)
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawPath = target.RawPath
+	return req, nil
+}
+
+// do signs and sends req, turning S3 error responses into errors
+func (s *S3Store) do(req *http.Request, body []byte) (*http.Response, error) {
+	s.Credentials.Sign(req, sigv4.HashPayload(body), time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var failure s3Error
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	return nil, fmt.Errorf("S3 %s %s: %s %s %s", req.Method, req.URL.Path, resp.Status, failure.Code, failure.Message)
+}
+
+func (s *S3Store) Put(key, contentType string, content io.Reader) error {
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Open(key string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	req, err := s.request(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the ListObjectsV2 response body
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2 until all matching objects are returned
+func (s *S3Store) List(prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.request(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Contents {
+			objects = append(objects, Object{Key: item.Key, Size: item.Size, ModifiedAt: item.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// SignedURL presigns a GET for the object, asking the store to serve it with contentType
+func (s *S3Store) SignedURL(key, contentType string, ttl time.Duration) (string, error) {
+	if ttl > sigv4.MaxPresignExpiry {
+		ttl = sigv4.MaxPresignExpiry
+	}
+	query := url.Values{}
+	if contentType != "" {
+		query.Set("response-content-type", contentType)
+	}
+	req, err := s.request(http.MethodGet, key, query, nil)
+	if err != nil {
+		return "", err
+	}
+	return s.Credentials.Presign(req, ttl, time.Now()), nil
+}
@@ -0,0 +1,112 @@
+package blobstore
+
+import (
+	"PTS/blobstore/mocks3"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestS3Store(t *testing.T, secretKey string) *S3Store {
+	t.Helper()
+	server := httptest.NewServer(mocks3.New("test-access", "test-secret", "us-east-1"))
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(server.URL, "us-east-1", "pts-test", "test-access", secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	store := newTestS3Store(t, "test-secret")
+
+	for key, content := range map[string]string{
+		"pod/order-1/a-signature": "signature",
+		"pod/order-1/a-photo":     "photo",
+		"other/report.txt":        "report",
+	} {
+		if err := store.Put(key, "text/plain", strings.NewReader(content)); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	body, err := store.Open("pod/order-1/a-photo")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "photo" {
+		t.Errorf("Open returned %q, want %q", data, "photo")
+	}
+
+	objects, err := store.List("pod/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "pod/order-1/a-photo,pod/order-1/a-signature" {
+		t.Errorf("List(pod/) = %v", keys)
+	}
+
+	if err := store.Delete("pod/order-1/a-photo"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open("pod/order-1/a-photo"); err != ErrNotFound {
+		t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("pod/order-1/a-photo"); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+}
+
+func TestS3StoreSignedURL(t *testing.T) {
+	store := newTestS3Store(t, "test-secret")
+	if err := store.Put("pod/order-1/a-signature", "image/png", strings.NewReader("png")); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := store.SignedURL("pod/order-1/a-signature", "image/png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != "png" {
+		t.Errorf("GET signed URL = %d %q, want 200 %q", resp.StatusCode, data, "png")
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", contentType)
+	}
+
+	resp, err = http.Get(link + "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET tampered URL = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestS3StoreRejectsWrongCredentials(t *testing.T) {
+	store := newTestS3Store(t, "wrong-secret")
+	err := store.Put("pod/order-1/a-photo", "image/jpeg", strings.NewReader("photo"))
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put with the wrong secret = %v, want SignatureDoesNotMatch", err)
+	}
+}
@@ -0,0 +1,242 @@
+// Package sigv4 implements AWS Signature Version 4 for S3-compatible object stores: signing requests,
+// presigning download URLs and, for the local stand-in server, verifying them.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+
+	// UnsignedPayload is used as the payload hash when the body is not part of the signature
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// MaxPresignExpiry is the longest validity S3 accepts for a presigned URL
+	MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrMissingAuth is returned by Verify for requests that carry no signature
+	ErrMissingAuth = errors.New("request is not signed")
+	// ErrSignatureMismatch is returned by Verify when the signature or credentials are wrong
+	ErrSignatureMismatch = errors.New("signature does not match")
+	// ErrExpired is returned by Verify for presigned URLs past their expiry
+	ErrExpired = errors.New("presigned URL has expired")
+)
+
+// Credentials identify the signer and the region and service the requests are scoped to
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string
+}
+
+// HashPayload returns the hex SHA-256 of a request body, as sent in X-Amz-Content-Sha256
+func HashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers to req
+func (c Credentials) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signed = append(signed, "content-type")
+	}
+	sort.Strings(signed)
+
+	scope := c.scope(now)
+	signature := c.signature(now, scope, canonicalRequest(req, signed, payloadHash, nil))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.AccessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+// Presign returns req's URL with query-string authentication valid for expires
+func (c Credentials) Presign(req *http.Request, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	scope := c.scope(now)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", c.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	req.URL.RawQuery = canonicalQuery(query, "")
+
+	signature := c.signature(now, scope, canonicalRequest(req, []string{"host"}, UnsignedPayload, query))
+	return req.URL.String() + "&X-Amz-Signature=" + signature
+}
+
+// Verify checks a request signed with Sign or a presigned URL against these credentials
+func (c Credentials) Verify(req *http.Request, now time.Time) error {
+	query := req.URL.Query()
+	if query.Get("X-Amz-Signature") != "" {
+		return c.verifyPresigned(req, query, now)
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, algorithm+" ") {
+		return ErrMissingAuth
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, algorithm+" "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[name] = value
+	}
+
+	signedAt, err := time.Parse(timeFormat, req.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+	scope := c.scope(signedAt)
+	if fields["Credential"] != c.AccessKey+"/"+scope {
+		return ErrSignatureMismatch
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	expected := c.signature(signedAt, scope, canonicalRequest(req, signed, req.Header.Get("X-Amz-Content-Sha256"), nil))
+	if !hmac.Equal([]byte(expected), []byte(fields["Signature"])) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+func (c Credentials) verifyPresigned(req *http.Request, query map[string][]string, now time.Time) error {
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	signedAt, err := time.Parse(timeFormat, get("X-Amz-Date"))
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+	seconds, err := strconv.Atoi(get("X-Amz-Expires"))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxPresignExpiry {
+		return ErrSignatureMismatch
+	}
+	scope := c.scope(signedAt)
+	if get("X-Amz-Credential") != c.AccessKey+"/"+scope {
+		return ErrSignatureMismatch
+	}
+
+	signed := strings.Split(get("X-Amz-SignedHeaders"), ";")
+	expected := c.signature(signedAt, scope, canonicalRequest(req, signed, UnsignedPayload, query))
+	if !hmac.Equal([]byte(expected), []byte(get("X-Amz-Signature"))) {
+		return ErrSignatureMismatch
+	}
+	if now.After(signedAt.Add(time.Duration(seconds) * time.Second)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (c Credentials) scope(t time.Time) string {
+	return t.Format(dateFormat) + "/" + c.Region + "/" + c.Service + "/aws4_request"
+}
+
+// signature derives the signing key for the day and signs the canonical request
+func (c Credentials) signature(t time.Time, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := algorithm + "\n" + t.Format(timeFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), t.Format(dateFormat))
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, c.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalRequest builds the SigV4 canonical form of req. query overrides the URL's query when set.
+func canonicalRequest(req *http.Request, signedHeaders []string, payloadHash string, query map[string][]string) string {
+	if query == nil {
+		query = req.URL.Query()
+	}
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		EncodePath(req.URL.Path),
+		canonicalQuery(query, "X-Amz-Signature"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery encodes query parameters sorted by name, leaving out skip
+func canonicalQuery(query map[string][]string, skip string) string {
+	var pairs []string
+	for name, values := range query {
+		if name == skip {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, encode(name, true)+"="+encode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// EncodePath percent-encodes an object path the way SigV4 expects, keeping the slashes
+func EncodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return encode(path, false)
+}
+
+// encode percent-encodes everything except unreserved characters, and slashes unless encodeSlash is set
+func encode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
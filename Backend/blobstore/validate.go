@@ -0,0 +1,59 @@
+package blobstore
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Policy limits what may be uploaded for one kind of attachment
+type Policy struct {
+	MaxSize      int64
+	ContentTypes []string
+}
+
+// ImagePolicy accepts PNG and JPEG images up to 5 MB
+var ImagePolicy = Policy{MaxSize: 5 << 20, ContentTypes: []string{"image/png", "image/jpeg"}}
+
+// ValidationError describes an upload rejected by a policy
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Read reads an upload, rejecting it if it is larger than the policy allows or its content,
+// as sniffed from the bytes rather than the declared type, is not one of the allowed types.
+// It returns the content and its detected content type.
+func (p Policy) Read(content io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(content, p.MaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > p.MaxSize {
+		return nil, "", &ValidationError{fmt.Sprintf("file is larger than %s", formatSize(p.MaxSize))}
+	}
+	if len(data) == 0 {
+		return nil, "", &ValidationError{"file is empty"}
+	}
+
+	contentType := http.DetectContentType(data)
+	for _, allowed := range p.ContentTypes {
+		if contentType == allowed {
+			return data, contentType, nil
+		}
+	}
+	return nil, "", &ValidationError{"file type " + contentType + " is not allowed"}
+}
+
+func formatSize(size int64) string {
+	if size >= 1<<20 && size%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", size>>20)
+	}
+	if size >= 1<<10 && size%(1<<10) == 0 {
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
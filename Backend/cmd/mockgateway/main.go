@@ -0,0 +1,16 @@
+// Command mockgateway runs the in-memory card gateway used by the card payment provider in development.
+// Point PAYMENT_GATEWAY_URL at it (http://localhost:8090 by default).
+package main
+
+import (
+	"PTS/payments/mockgateway"
+	"PTS/utils"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := utils.GetEnv("MOCK_GATEWAY_ADDR", ":8090")
+	log.Println("Mock card gateway running on", addr)
+	log.Fatal(http.ListenAndServe(addr, mockgateway.New()))
+}
@@ -0,0 +1,21 @@
+// Command mocks3 runs the in-memory S3-compatible object store used for blob storage in development.
+// Start the API with BLOB_STORE=s3 and BLOB_S3_ENDPOINT pointing at it (http://localhost:9000 by default).
+package main
+
+import (
+	"PTS/blobstore/mocks3"
+	"PTS/utils"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := utils.GetEnv("MOCK_S3_ADDR", ":9000")
+	server := mocks3.New(
+		utils.GetEnv("BLOB_S3_ACCESS_KEY", "pts-dev"),
+		utils.GetEnv("BLOB_S3_SECRET_KEY", "pts-dev-secret"),
+		utils.GetEnv("BLOB_S3_REGION", "us-east-1"),
+	)
+	log.Println("Mock S3 object store running on", addr)
+	log.Fatal(http.ListenAndServe(addr, server))
+}
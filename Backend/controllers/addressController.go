@@ -0,0 +1,435 @@
+package controllers
+
+import (
+	"PTS/geo"
+	"PTS/geocode"
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxAddressLabelLength = 50
+	defaultGeocodeResults = 5
+	maxGeocodeResults     = 20
+)
+
+const savedAddressColumns = "id, user_id, label, line1, line2, district, city, region, postal_code, country, lat, lng, precision, created_at, updated_at"
+
+// AddressController manages customers' address books and looks up addresses
+type AddressController struct{}
+
+// addressRejection explains why an address cannot be used
+type addressRejection struct {
+	message string
+}
+
+func (r *addressRejection) Error() string {
+	return r.message
+}
+
+// ListAddresses godoc
+// @Summary List saved addresses
+// @Description List the logged-in customer's address book
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.SavedAddress "Saved addresses, newest first"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have an address book"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /addresses [get]
+func (ac *AddressController) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	rows, err := utils.DB.Query("SELECT "+savedAddressColumns+" FROM addresses WHERE user_id = $1 ORDER BY created_at DESC", identity.UserID)
+	if err != nil {
+		log.Println("Error retrieving addresses:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	addresses := []models.SavedAddress{}
+	for rows.Next() {
+		address, err := scanSavedAddress(rows)
+		if err != nil {
+			log.Println("Error scanning address:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		addresses = append(addresses, *address)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addresses)
+}
+
+// CreateAddress godoc
+// @Summary Save an address
+// @Description Add an address to the logged-in customer's address book. It is geocoded before it is saved: the city must be one PTS knows, and a pinned location must be near it.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param address body models.SavedAddressRequest true "Label and address"
+// @Success 201 {object} models.SavedAddress "The saved, geocoded address"
+// @Failure 400 {object} map[string]string "Missing fields or the address could not be found"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have an address book"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /addresses [post]
+func (ac *AddressController) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	resolved, label, ok := decodeSavedAddress(w, r)
+	if !ok {
+		return
+	}
+
+	query := `
+        INSERT INTO addresses (user_id, label, line1, line2, district, city, region, postal_code, country, lat, lng, precision, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
+        RETURNING ` + savedAddressColumns
+	address, err := scanSavedAddress(utils.DB.QueryRow(query, identity.UserID, label, resolved.Line1, resolved.Line2, resolved.District, resolved.City,
+		resolved.Region, resolved.PostalCode, resolved.Country, resolved.Location.Lat, resolved.Location.Lng, resolved.Precision, time.Now()))
+	if err != nil {
+		log.Println("Error saving address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(address)
+}
+
+// GetAddress godoc
+// @Summary Get a saved address
+// @Description Get one address from the logged-in customer's address book
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Address ID"
+// @Success 200 {object} models.SavedAddress "The saved address"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have an address book"
+// @Failure 404 {object} map[string]string "Address not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /addresses/{id} [get]
+func (ac *AddressController) GetAddress(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	address, err := loadSavedAddress(identity.UserID, mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error retrieving address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(address)
+}
+
+// UpdateAddress godoc
+// @Summary Change a saved address
+// @Description Replace a saved address and geocode it again. Orders already placed keep the address they were placed with.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Address ID"
+// @Param address body models.SavedAddressRequest true "Label and address"
+// @Success 200 {object} models.SavedAddress "The updated address"
+// @Failure 400 {object} map[string]string "Missing fields or the address could not be found"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have an address book"
+// @Failure 404 {object} map[string]string "Address not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /addresses/{id} [put]
+func (ac *AddressController) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	resolved, label, ok := decodeSavedAddress(w, r)
+	if !ok {
+		return
+	}
+
+	query := `
+        UPDATE addresses
+        SET label = $1, line1 = $2, line2 = $3, district = $4, city = $5, region = $6, postal_code = $7, country = $8,
+            lat = $9, lng = $10, precision = $11, updated_at = $12
+        WHERE id = $13 AND user_id = $14
+        RETURNING ` + savedAddressColumns
+	address, err := scanSavedAddress(utils.DB.QueryRow(query, label, resolved.Line1, resolved.Line2, resolved.District, resolved.City, resolved.Region,
+		resolved.PostalCode, resolved.Country, resolved.Location.Lat, resolved.Location.Lng, resolved.Precision, time.Now(), id, identity.UserID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error updating address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(address)
+}
+
+// DeleteAddress godoc
+// @Summary Delete a saved address
+// @Description Remove an address from the logged-in customer's address book. Orders placed with it keep their copy.
+// @Security BearerAuth
+// @Param id path string true "Address ID"
+// @Success 204 "Address deleted"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have an address book"
+// @Failure 404 {object} map[string]string "Address not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /addresses/{id} [delete]
+func (ac *AddressController) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := utils.DB.Exec("DELETE FROM addresses WHERE id = $1 AND user_id = $2", id, identity.UserID)
+	if err != nil {
+		log.Println("Error deleting address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchAddresses godoc
+// @Summary Look up an address
+// @Description Find the places best matching free text such as "12 Road 9, Maadi, Cairo", to fill in or check an address before saving it
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Address text"
+// @Param limit query int false "Maximum results (default 5, at most 20)"
+// @Success 200 {array} geocode.Address "Matching addresses, best first"
+// @Failure 400 {object} map[string]string "Missing q or invalid limit"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /geocode [get]
+func (ac *AddressController) SearchAddresses(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner); !ok {
+		return
+	}
+
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultGeocodeResults
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxGeocodeResults {
+			http.Error(w, "limit must be between 1 and 20", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	matches, err := geocode.Default.Search(text, limit)
+	if err != nil {
+		log.Println("Error searching addresses:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if matches == nil {
+		matches = []geocode.Address{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
+
+// decodeSavedAddress reads and geocodes a SavedAddressRequest, writing the error response if it is invalid
+func decodeSavedAddress(w http.ResponseWriter, r *http.Request) (geocode.Address, string, bool) {
+	var req models.SavedAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return geocode.Address{}, "", false
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if label == "" || len(label) > maxAddressLabelLength {
+		http.Error(w, "label is required and must be at most 50 characters", http.StatusBadRequest)
+		return geocode.Address{}, "", false
+	}
+
+	resolved, err := geocodeAddress(req.Address)
+	var rejection *addressRejection
+	if errors.As(err, &rejection) {
+		http.Error(w, rejection.Error(), http.StatusBadRequest)
+		return geocode.Address{}, "", false
+	}
+	if err != nil {
+		log.Println("Error geocoding address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return geocode.Address{}, "", false
+	}
+	return resolved, label, true
+}
+
+// geocodeAddress validates and resolves a structured address, returning an *addressRejection if it cannot be used
+func geocodeAddress(address geocode.Address) (geocode.Address, error) {
+	if err := address.Validate(); err != nil {
+		return geocode.Address{}, &addressRejection{err.Error()}
+	}
+
+	resolved, err := geocode.Default.Geocode(address)
+	switch err {
+	case nil:
+		return resolved, nil
+	case geocode.ErrNotFound:
+		return geocode.Address{}, &addressRejection{"could not find " + address.City + "; check the city name"}
+	case geocode.ErrLocationMismatch:
+		return geocode.Address{}, &addressRejection{"the pinned location is not in " + address.City}
+	default:
+		return geocode.Address{}, err
+	}
+}
+
+// resolveOrderAddresses turns an order's pickup and drop-off, given as saved address IDs, structured addresses
+// or free text, into the geocoded addresses copied onto the order. Each end must be given in one of those ways.
+func resolveOrderAddresses(userID, pickupText, dropOffText string, addresses models.OrderAddresses) (*geocode.Address, *geocode.Address, error) {
+	pickup, err := resolveOrderAddress(userID, "pickup", addresses.PickupAddressID, addresses.PickupAddress, pickupText)
+	if err != nil {
+		return nil, nil, err
+	}
+	dropOff, err := resolveOrderAddress(userID, "drop-off", addresses.DropOffAddressID, addresses.DropOffAddress, dropOffText)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pickup, dropOff, nil
+}
+
+func resolveOrderAddress(userID, name, addressID string, address *geocode.Address, text string) (*geocode.Address, error) {
+	switch {
+	case addressID != "":
+		saved, err := loadSavedAddress(userID, addressID)
+		if err == sql.ErrNoRows {
+			return nil, &addressRejection{"saved " + name + " address not found"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &saved.Address, nil
+
+	case address != nil:
+		resolved, err := geocodeAddress(*address)
+		var rejection *addressRejection
+		if errors.As(err, &rejection) {
+			return nil, &addressRejection{name + " address: " + rejection.message}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &resolved, nil
+
+	case strings.TrimSpace(text) != "":
+		matches, err := geocode.Default.Search(text, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, &addressRejection{"could not find the " + name + " location; include the district and city, or use a saved address"}
+		}
+		return &matches[0], nil
+	}
+	return nil, &addressRejection{"missing " + name + " address"}
+}
+
+// loadSavedAddress fetches one of the user's saved addresses, returning sql.ErrNoRows if it is not theirs
+func loadSavedAddress(userID, addressID string) (*models.SavedAddress, error) {
+	if _, err := uuid.Parse(addressID); err != nil {
+		return nil, sql.ErrNoRows
+	}
+	return scanSavedAddress(utils.DB.QueryRow("SELECT "+savedAddressColumns+" FROM addresses WHERE id = $1 AND user_id = $2", addressID, userID))
+}
+
+func scanSavedAddress(row rowScanner) (*models.SavedAddress, error) {
+	var saved models.SavedAddress
+	var location geo.Point
+	a := &saved.Address
+	err := row.Scan(&saved.ID, &saved.UserID, &saved.Label, &a.Line1, &a.Line2, &a.District, &a.City, &a.Region, &a.PostalCode, &a.Country,
+		&location.Lat, &location.Lng, &a.Precision, &saved.CreatedAt, &saved.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.Location = &location
+	return &saved, nil
+}
+
+// addressJSON encodes an address snapshot for a JSONB column, or NULL without one
+func addressJSON(address *geocode.Address) (interface{}, error) {
+	if address == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(address)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// unmarshalAddresses decodes the pickup and drop-off snapshots read from JSONB columns, leaving NULLs unset
+func unmarshalAddresses(pickup, dropOff []byte, pickupAddress, dropOffAddress **geocode.Address) error {
+	*pickupAddress, *dropOffAddress = nil, nil
+	if pickup != nil {
+		if err := json.Unmarshal(pickup, pickupAddress); err != nil {
+			return err
+		}
+	}
+	if dropOff != nil {
+		if err := json.Unmarshal(dropOff, dropOffAddress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
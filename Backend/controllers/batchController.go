@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/outbox"
+	"PTS/utils"
+	"PTS/vehicles"
+	"PTS/windows"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// maxBatchOrders is the most orders that can be assigned in one batch
+const maxBatchOrders = 50
+
+// carriedStatuses are the order statuses whose packages count towards a courier's load
+var carriedStatuses = []string{models.OrderAssigned, models.OrderPickedUp, models.OrderInTransit, models.OrderRescheduled, models.OrderReturning}
+
+// BatchController lets store staff put several orders on one courier without overloading its vehicle
+type BatchController struct{}
+
+// ListVehicleProfiles godoc
+// @Summary List vehicle profiles
+// @Description List the vehicle types couriers can register with and how much weight and volume each carries at once, and the longest package it takes
+// @Produce json
+// @Success 200 {array} vehicles.Profile "Vehicle profiles, smallest first"
+// @Router /vehicles [get]
+func (bc *BatchController) ListVehicleProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vehicles.Profiles)
+}
+
+// SuggestBatch godoc
+// @Summary Suggest a batch for a courier
+// @Description Suggest pending orders of the store to give one of its couriers. The orders share the earliest delivery date and window that has pending orders, and are picked lightest first so as many as possible fit in the courier's vehicle on top of what it already carries. Only the store's admins and owner can batch orders.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Success 200 {object} models.BatchSuggestion "Orders that fit, and the resulting load"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only admins and owners can batch orders"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/batch-suggestion [get]
+func (bc *BatchController) SuggestBatch(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	courierID := mux.Vars(r)["id"]
+	if !storeCourierExists(w, courierID, identity.StoreId) {
+		return
+	}
+
+	profile, load, err := courierLoad(utils.DB, courierID, nil)
+	if err != nil {
+		log.Println("Error retrieving courier load:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	candidates, err := nextPendingBatch(identity.StoreId)
+	if err != nil {
+		log.Println("Error retrieving pending orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	suggestion := models.BatchSuggestion{CourierID: courierID, Vehicle: profile, CurrentLoad: load, Orders: []models.Order{}, Load: load}
+	if len(candidates) > 0 {
+		suggestion.DeliveryDate, suggestion.DeliveryWindow = *candidates[0].DeliveryDate, candidates[0].DeliveryWindow
+	}
+	if profile == nil {
+		for i := range candidates {
+			suggestion.Orders = append(suggestion.Orders, candidates[i])
+			suggestion.Load = suggestion.Load.Add(candidates[i].Package)
+		}
+	} else {
+		packages := make([]*vehicles.Package, len(candidates))
+		for i := range candidates {
+			packages[i] = candidates[i].Package
+		}
+		var chosen []int
+		chosen, suggestion.Load = profile.Batch(load, packages)
+		for _, i := range chosen {
+			suggestion.Orders = append(suggestion.Orders, candidates[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestion)
+}
+
+// AssignBatch godoc
+// @Summary Assign a batch of orders to a courier
+// @Description Assign several of the store's pending or assigned orders to one of its couriers at once. The orders must share a delivery date and window, and their packages must fit in the courier's vehicle together with what it already carries; otherwise none are assigned. Only the store's admins and owner can batch orders.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param batch body models.AssignBatchRequest true "Courier and orders to assign"
+// @Success 200 {array} models.Order "The assigned orders"
+// @Failure 400 {object} map[string]string "Missing required fields, too many orders or orders with different delivery slots"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only admins and owners can batch orders"
+// @Failure 404 {object} map[string]string "Order or courier not found"
+// @Failure 409 {object} map[string]string "An order can no longer be assigned or the courier's vehicle cannot carry the batch"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/batches [post]
+func (bc *BatchController) AssignBatch(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.AssignBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CourierID == "" || len(req.OrderIDs) == 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if len(req.OrderIDs) > maxBatchOrders {
+		http.Error(w, fmt.Sprintf("A batch can have at most %d orders", maxBatchOrders), http.StatusBadRequest)
+		return
+	}
+	orderIDs := []string{}
+	seen := map[string]bool{}
+	for _, id := range req.OrderIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			orderIDs = append(orderIDs, id)
+		}
+	}
+	if !storeCourierExists(w, req.CourierID, identity.StoreId) {
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the courier first, as single assignments do, then the orders
+	profile, load, err := courierLoad(tx, req.CourierID, orderIDs)
+	if err != nil {
+		log.Println("Error retrieving courier load:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := tx.Query("SELECT "+orderColumns+" FROM orders WHERE id = ANY($1) ORDER BY created_at FOR UPDATE", pq.Array(orderIDs))
+	if err != nil {
+		log.Println("Error retrieving batch orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	orders, err := scanOrders(rows)
+	if err != nil {
+		log.Println("Error scanning batch orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if len(orders) != len(orderIDs) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		if order.StoreId != identity.StoreId {
+			http.Error(w, "Not allowed to assign order "+order.ID, http.StatusForbidden)
+			return
+		}
+		if !canTransition(order.Status, models.OrderAssigned) {
+			http.Error(w, "Order "+order.ID+" can no longer be assigned", http.StatusConflict)
+			return
+		}
+		if !sameSlot(order, &orders[0]) {
+			http.Error(w, "Batched orders must share a delivery date and window", http.StatusBadRequest)
+			return
+		}
+		if profile != nil {
+			if err := profile.Check(load, order.Package); err != nil {
+				http.Error(w, "Order "+order.ID+": "+err.Error(), http.StatusConflict)
+				return
+			}
+		}
+		load = load.Add(order.Package)
+	}
+
+	assigned := make([]models.Order, 0, len(orders))
+	for i := range orders {
+		updated, err := assignCourier(tx, &orders[i], req.CourierID)
+		if err != nil {
+			log.Println("Error assigning batch order:", err)
+			http.Error(w, "Could not assign orders", http.StatusInternalServerError)
+			return
+		}
+		assigned = append(assigned, updated)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing batch assignment:", err)
+		http.Error(w, "Could not assign orders", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assigned)
+}
+
+// loadQueryer is implemented by both *sql.DB and *sql.Tx
+type loadQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// courierLoad locks the courier's row when called in a transaction and returns its vehicle profile and what
+// it is carrying, leaving out the excluded orders. The profile is nil for vehicle types without one.
+func courierLoad(q loadQueryer, courierID string, excluding []string) (*vehicles.Profile, vehicles.Load, error) {
+	var load vehicles.Load
+	var vehicle string
+	if err := q.QueryRow("SELECT vehicle_type FROM couriers WHERE id = $1 FOR UPDATE", courierID).Scan(&vehicle); err != nil {
+		return nil, load, err
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders WHERE courier_id = $1 AND status = ANY($2) AND NOT (id = ANY($3))"
+	rows, err := q.Query(query, courierID, pq.Array(carriedStatuses), pq.Array(append([]string{}, excluding...)))
+	if err != nil {
+		return nil, load, err
+	}
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, load, err
+	}
+	for i := range orders {
+		load = load.Add(orders[i].Package)
+	}
+
+	profile, ok := vehicles.Lookup(vehicle)
+	if !ok {
+		return nil, load, nil
+	}
+	return &profile, load, nil
+}
+
+// nextPendingBatch returns the store's pending orders in the earliest delivery slot that has any
+func nextPendingBatch(storeID string) ([]models.Order, error) {
+	today := time.Now().In(windows.Location).Format(time.DateOnly)
+	query := "SELECT " + orderColumns + " FROM orders WHERE store_id = $1 AND status = $2 AND delivery_date >= $3 ORDER BY delivery_date, created_at"
+	rows, err := utils.DB.Query(query, storeID, models.OrderPending, today)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := scanOrders(rows)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+
+	schedule, err := windows.ForStore(storeID)
+	if err != nil {
+		return nil, err
+	}
+	startHour := func(order *models.Order) int {
+		day, err := time.ParseInLocation(time.DateOnly, *order.DeliveryDate, windows.Location)
+		if err != nil {
+			return 24
+		}
+		if window, ok := schedule.Find(order.DeliveryWindow, day); ok {
+			return window.StartHour
+		}
+		return 24
+	}
+
+	// Orders are sorted by date, so the earliest slot is on the first order's date
+	first := &orders[0]
+	for i := range orders {
+		if *orders[i].DeliveryDate != *first.DeliveryDate {
+			break
+		}
+		if startHour(&orders[i]) < startHour(first) {
+			first = &orders[i]
+		}
+	}
+
+	batch := []models.Order{}
+	for i := range orders {
+		if sameSlot(&orders[i], first) {
+			batch = append(batch, orders[i])
+		}
+	}
+	return batch, nil
+}
+
+// sameSlot reports whether two orders are booked into the same delivery window on the same day
+func sameSlot(a, b *models.Order) bool {
+	if a.DeliveryWindow != b.DeliveryWindow || (a.DeliveryDate == nil) != (b.DeliveryDate == nil) {
+		return false
+	}
+	return a.DeliveryDate == nil || *a.DeliveryDate == *b.DeliveryDate
+}
+
+// storeCourierExists writes a 404 unless the courier works for the store
+func storeCourierExists(w http.ResponseWriter, courierID, storeID string) bool {
+	if _, err := uuid.Parse(courierID); err != nil {
+		http.Error(w, "Courier not found", http.StatusNotFound)
+		return false
+	}
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM couriers WHERE id = $1 AND store_id = $2)"
+	if err := utils.DB.QueryRow(query, courierID, storeID).Scan(&exists); err != nil {
+		log.Println("Error checking courier existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, "Courier not found", http.StatusNotFound)
+	}
+	return exists
+}
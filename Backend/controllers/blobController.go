@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"PTS/blobstore"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// BlobController serves files through the signed links handed out by blobstore.SignedURL,
+// for stores that cannot serve links themselves
+type BlobController struct{}
+
+// DownloadBlob godoc
+// @Summary Download a file through a signed link
+// @Description Download an attachment such as a proof of delivery photo. The link comes from an API response and stops working when it expires; no token is needed.
+// @Produce octet-stream
+// @Param key path string true "Blob key"
+// @Param expires query int true "Expiry as a Unix timestamp"
+// @Param type query string false "Content type to serve the file with"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file "The file"
+// @Failure 403 {object} map[string]string "Invalid or expired link"
+// @Failure 404 {object} map[string]string "File not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /blobs/{key} [get]
+func (bc *BlobController) DownloadBlob(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	params := r.URL.Query()
+	contentType, expires := params.Get("type"), params.Get("expires")
+
+	if err := blobstore.VerifyURL(key, contentType, expires, params.Get("signature"), time.Now()); err != nil {
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	content, err := blobstore.Default.Open(key)
+	if err != nil {
+		if err == blobstore.ErrNotFound || err == blobstore.ErrInvalidKey {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error opening blob:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// The link may be cached until it expires, but not beyond
+	maxAge := int64(0)
+	if unix, err := strconv.ParseInt(expires, 10, 64); err == nil && unix > time.Now().Unix() {
+		maxAge = unix - time.Now().Unix()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(maxAge, 10))
+	io.Copy(w, content)
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/settlements"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// defaultReportPeriod is how far back ledgers and reports go when no from time is given
+const defaultReportPeriod = 7 * 24 * time.Hour
+
+// CashController handles couriers' cash-on-delivery ledgers, settlements and reports
+type CashController struct{}
+
+// GetCourierCash godoc
+// @Summary Get a courier's cash ledger
+// @Description List the cash a courier collected on delivery and handed in during a period, with what they still owe. Available to the courier and the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Param from query string false "Start of the period (RFC 3339, default 7 days ago)"
+// @Param to query string false "End of the period (RFC 3339, default now)"
+// @Success 200 {object} models.CourierCashResponse "Ledger entries, newest first, and the outstanding balance"
+// @Failure 400 {object} map[string]string "Invalid period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this courier's cash"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/cash [get]
+func (cc *CashController) GetCourierCash(w http.ResponseWriter, r *http.Request) {
+	_, courierID, _, ok := loadCourierAccess(w, r, false)
+	if !ok {
+		return
+	}
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := settlements.Ledger(courierID, from, to)
+	if err != nil {
+		log.Println("Error retrieving cash ledger:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	report, err := settlements.CourierReport(courierID, from, to)
+	if err != nil {
+		log.Println("Error totalling cash ledger:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CourierCashResponse{Outstanding: report.Outstanding, Entries: entries})
+}
+
+// SettleCourierCash godoc
+// @Summary Settle a courier's cash
+// @Description Record the cash a courier handed in at the end of their shift. All unsettled collections are closed against the settlement and any difference is kept as a discrepancy (negative when cash is short). Only the store's admins and owner can settle.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Param settlement body models.SettleCashRequest true "Cash handed in"
+// @Success 201 {object} models.CashSettlement "The settlement"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to settle this courier's cash"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/settlements [post]
+func (cc *CashController) SettleCourierCash(w http.ResponseWriter, r *http.Request) {
+	identity, courierID, storeID, ok := loadCourierAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req models.SettleCashRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.HandedIn == nil {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if *req.HandedIn < 0 {
+		http.Error(w, "Handed in amount must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	settlement, err := settlements.Settle(courierID, storeID, *req.HandedIn, req.Note, identity.UserID)
+	if err != nil {
+		log.Println("Error settling courier cash:", err)
+		http.Error(w, "Could not settle cash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// ListCourierSettlements godoc
+// @Summary List a courier's settlements
+// @Description List the cash settlements recorded for a courier during a period. Available to the courier and the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Param from query string false "Start of the period (RFC 3339, default 7 days ago)"
+// @Param to query string false "End of the period (RFC 3339, default now)"
+// @Success 200 {array} models.CashSettlement "Settlements, newest first"
+// @Failure 400 {object} map[string]string "Invalid period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this courier's cash"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/settlements [get]
+func (cc *CashController) ListCourierSettlements(w http.ResponseWriter, r *http.Request) {
+	_, courierID, _, ok := loadCourierAccess(w, r, false)
+	if !ok {
+		return
+	}
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := settlements.Settlements(courierID, from, to)
+	if err != nil {
+		log.Println("Error retrieving settlements:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetCourierCashReport godoc
+// @Summary Get a courier's cash report
+// @Description Total a courier's cash collected, handed in and discrepancies for a period. Available to the courier and the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Param from query string false "Start of the period (RFC 3339, default 7 days ago)"
+// @Param to query string false "End of the period (RFC 3339, default now)"
+// @Success 200 {object} models.CourierCashReport "The report"
+// @Failure 400 {object} map[string]string "Invalid period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this courier's cash"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/cash-report [get]
+func (cc *CashController) GetCourierCashReport(w http.ResponseWriter, r *http.Request) {
+	_, courierID, _, ok := loadCourierAccess(w, r, false)
+	if !ok {
+		return
+	}
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := settlements.CourierReport(courierID, from, to)
+	if err != nil {
+		log.Println("Error building courier cash report:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetStoreCashReport godoc
+// @Summary Get the store's cash report
+// @Description Total the cash collected, handed in and discrepancies of every courier of the admin's or owner's store for a period
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start of the period (RFC 3339, default 7 days ago)"
+// @Param to query string false "End of the period (RFC 3339, default now)"
+// @Success 200 {object} models.StoreCashReport "The report"
+// @Failure 400 {object} map[string]string "Invalid period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view cash reports"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/cash-report [get]
+func (cc *CashController) GetStoreCashReport(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := settlements.StoreReport(identity.StoreId, from, to)
+	if err != nil {
+		log.Println("Error building store cash report:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// loadCourierAccess resolves the courier named in the URL and checks the caller is that courier
+// (unless staffOnly) or an admin or owner of the courier's store, writing an error response if not
+func loadCourierAccess(w http.ResponseWriter, r *http.Request, staffOnly bool) (*models.Identity, string, string, bool) {
+	identity, ok := requireRole(w, r, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return nil, "", "", false
+	}
+
+	courierID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(courierID); err != nil {
+		http.Error(w, "Courier not found", http.StatusNotFound)
+		return nil, "", "", false
+	}
+
+	var storeID string
+	err := utils.DB.QueryRow("SELECT store_id FROM couriers WHERE id = $1", courierID).Scan(&storeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Courier not found", http.StatusNotFound)
+			return nil, "", "", false
+		}
+		log.Println("Error loading courier:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, "", "", false
+	}
+
+	allowed := storeID == identity.StoreId && identity.Role != models.RoleCourier
+	if !staffOnly && identity.Role == models.RoleCourier {
+		allowed = identity.CourierID == courierID
+	}
+	if !allowed {
+		http.Error(w, "Not allowed to access this courier", http.StatusForbidden)
+		return nil, "", "", false
+	}
+	return identity, courierID, storeID, true
+}
+
+// parseReportPeriod reads the from and to query parameters, writing an error response if they are invalid
+func parseReportPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	params := r.URL.Query()
+
+	to := time.Now()
+	if value := params.Get("to"); value != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			http.Error(w, "Invalid to time", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+	}
+
+	from := to.Add(-defaultReportPeriod)
+	if value := params.Get("from"); value != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			http.Error(w, "Invalid from time", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+	}
+
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"PTS/models"
 	"PTS/utils"
+	"PTS/vehicles"
 	"database/sql"
 	"encoding/json"
 	"log"
@@ -20,7 +21,7 @@ type CourierController struct{}
 
 // Register godoc
 // @Summary Register a new courier
-// @Description Register a new courier with details such as name, email, phone, password, location, vehicle type, and store ID. Returns a success message if registration is successful.
+// @Description Register a new courier with details such as name, email, phone, password, location, vehicle type, and store ID. The vehicle type (bike, motorcycle, car or van) decides how much the courier can carry. Returns a success message if registration is successful.
 // @Accept json
 // @Produce json
 // @Param courier body models.CourierRegisterRequest true "Courier registration data"
@@ -43,6 +44,12 @@ func (ac *CourierController) CourierRegister(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
+	profile, ok := vehicles.Lookup(req.VehicleType)
+	if !ok {
+		http.Error(w, "vehicle_type must be one of "+vehicles.Types(), http.StatusBadRequest)
+		return
+	}
+	req.VehicleType = profile.Type
 
 	// Check if the store exists
 	var storeExists bool
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"PTS/eta"
+	"PTS/geocode"
+	"PTS/models"
+	"PTS/routing"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const courierRouteColumns = "id, courier_id, status, stops, unrouted_orders, distance_km, late_stops, finish_at, created_at, accepted_at"
+
+// routedStatuses are the order statuses that still need the courier to visit a pickup, drop-off or the store
+var routedStatuses = []string{models.OrderAssigned, models.OrderPickedUp, models.OrderInTransit, models.OrderReturning}
+
+// SuggestRoute godoc
+// @Summary Suggest a route
+// @Description Work out an efficient sequence of pickups and drop-offs for the logged-in courier's current orders, starting from their last reported position. Every pickup comes before its drop-off, and drop-offs are kept within their delivery windows where possible; late_stops counts those that cannot be. Orders without geocoded addresses are listed in unrouted_orders. The suggestion replaces any earlier one until the courier accepts it.
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} models.CourierRoute "The suggested route"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can request routes"
+// @Failure 409 {object} map[string]string "The courier has no orders to route"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/routes [post]
+func (ac *CourierController) SuggestRoute(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	stops, unrouted, err := courierStops(identity.CourierID)
+	if err != nil {
+		log.Println("Error retrieving courier stops:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if len(stops) == 0 {
+		http.Error(w, "You have no orders to route", http.StatusConflict)
+		return
+	}
+
+	var vehicle string
+	if err := utils.DB.QueryRow("SELECT vehicle_type FROM couriers WHERE id = $1", identity.CourierID).Scan(&vehicle); err != nil {
+		log.Println("Error retrieving courier vehicle:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	speed, _, err := eta.Speed(identity.StoreId, vehicle)
+	if err != nil {
+		log.Println("Error retrieving courier speed:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	position, err := courierPosition(identity.CourierID)
+	if err != nil {
+		log.Println("Error retrieving courier position:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	route, err := routing.Solve(routing.Problem{
+		Start:    position,
+		StartAt:  now,
+		SpeedKmh: speed,
+		Dwell:    eta.StopDwell,
+		Router:   eta.DefaultRouter,
+		Stops:    stops,
+	})
+	if err != nil {
+		log.Println("Error solving courier route:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	stopsJSON, err := json.Marshal(route.Stops)
+	if err != nil {
+		log.Println("Error encoding route stops:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	unroutedJSON, err := json.Marshal(unrouted)
+	if err != nil {
+		log.Println("Error encoding unrouted orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	var saved *models.CourierRoute
+	err = utils.WithTx(func(tx *sql.Tx) error {
+		query := "UPDATE courier_routes SET status = $1 WHERE courier_id = $2 AND status = $3"
+		if _, err := tx.Exec(query, models.RouteSuperseded, identity.CourierID, models.RouteSuggested); err != nil {
+			return err
+		}
+
+		query = `
+            INSERT INTO courier_routes (courier_id, status, stops, unrouted_orders, distance_km, late_stops, finish_at, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING ` + courierRouteColumns
+		row := tx.QueryRow(query, identity.CourierID, models.RouteSuggested, stopsJSON, unroutedJSON, route.DistanceKm, route.LateStops, route.FinishAt, now)
+		var err error
+		saved, err = scanCourierRoute(row)
+		return err
+	})
+	if err != nil {
+		log.Println("Error saving courier route:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// AcceptRoute godoc
+// @Summary Accept a suggested route
+// @Description Accept the logged-in courier's latest suggested route, replacing the route they accepted before. The suggestion must still cover exactly the stops the courier has left; if orders were assigned, picked up or delivered since, request a new one.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Route ID"
+// @Success 200 {object} models.CourierRoute "The accepted route"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can accept routes"
+// @Failure 404 {object} map[string]string "Route not found"
+// @Failure 409 {object} map[string]string "The route is no longer the latest suggestion or the courier's orders have changed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/routes/{id}/accept [post]
+func (ac *CourierController) AcceptRoute(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Route not found", http.StatusNotFound)
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	query := "SELECT " + courierRouteColumns + " FROM courier_routes WHERE id = $1 AND courier_id = $2 FOR UPDATE"
+	route, err := scanCourierRoute(tx.QueryRow(query, id, identity.CourierID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Route not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error retrieving courier route:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if route.Status != models.RouteSuggested {
+		http.Error(w, "This route is no longer the latest suggestion; request a new one", http.StatusConflict)
+		return
+	}
+
+	stops, _, err := courierStops(identity.CourierID)
+	if err != nil {
+		log.Println("Error retrieving courier stops:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !sameStops(route.Stops, stops) {
+		http.Error(w, "Your orders have changed since this route was suggested; request a new one", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	query = "UPDATE courier_routes SET status = $1 WHERE courier_id = $2 AND status = $3"
+	if _, err = tx.Exec(query, models.RouteSuperseded, identity.CourierID, models.RouteAccepted); err != nil {
+		log.Println("Error superseding accepted route:", err)
+		http.Error(w, "Could not accept route", http.StatusInternalServerError)
+		return
+	}
+	query = "UPDATE courier_routes SET status = $1, accepted_at = $2 WHERE id = $3"
+	if _, err = tx.Exec(query, models.RouteAccepted, now, route.ID); err != nil {
+		log.Println("Error accepting route:", err)
+		http.Error(w, "Could not accept route", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing route acceptance:", err)
+		http.Error(w, "Could not accept route", http.StatusInternalServerError)
+		return
+	}
+
+	route.Status = models.RouteAccepted
+	route.AcceptedAt = &now
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(route)
+}
+
+// GetCurrentRoute godoc
+// @Summary Get the accepted route
+// @Description Retrieve the route the logged-in courier accepted last, with the stops they have already made marked completed
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierRoute "The accepted route"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers have routes"
+// @Failure 404 {object} map[string]string "No accepted route"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/routes/current [get]
+func (ac *CourierController) GetCurrentRoute(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	query := "SELECT " + courierRouteColumns + " FROM courier_routes WHERE courier_id = $1 AND status = $2"
+	route, err := scanCourierRoute(utils.DB.QueryRow(query, identity.CourierID, models.RouteAccepted))
+	if err == sql.ErrNoRows {
+		http.Error(w, "You have not accepted a route", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error retrieving courier route:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	stops, _, err := courierStops(identity.CourierID)
+	if err != nil {
+		log.Println("Error retrieving courier stops:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	remaining := map[string]bool{}
+	for _, stop := range stops {
+		remaining[stopKey(stop)] = true
+	}
+	for i := range route.Stops {
+		route.Stops[i].Completed = !remaining[stopKey(route.Stops[i])]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(route)
+}
+
+// courierStops returns the stops the courier still has to make for its orders, and the orders that
+// cannot be routed because they were placed without geocoded addresses
+func courierStops(courierID string) ([]routing.Stop, []string, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE courier_id = $1 AND status = ANY($2) ORDER BY created_at"
+	rows, err := utils.DB.Query(query, courierID, pq.Array(routedStatuses))
+	if err != nil {
+		return nil, nil, err
+	}
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stops := []routing.Stop{}
+	unrouted := []string{}
+	for i := range orders {
+		order := &orders[i]
+		if !locatable(order.PickupAddress) || !locatable(order.DropOffAddress) {
+			unrouted = append(unrouted, order.ID)
+			continue
+		}
+
+		pickup := routing.Stop{OrderID: order.ID, Kind: routing.StopPickup, Location: *order.PickupAddress.Location, Address: order.PickupAddress.String()}
+		dropOff := routing.Stop{OrderID: order.ID, Kind: routing.StopDropOff, Location: *order.DropOffAddress.Location, Address: order.DropOffAddress.String()}
+		slot, err := deliverySlot(order)
+		if err != nil {
+			return nil, nil, err
+		}
+		if slot != nil {
+			dropOff.Earliest, dropOff.Latest = &slot.Start, &slot.End
+		}
+
+		switch order.Status {
+		case models.OrderAssigned:
+			stops = append(stops, pickup, dropOff)
+		case models.OrderPickedUp, models.OrderInTransit:
+			stops = append(stops, dropOff)
+		case models.OrderReturning:
+			pickup.Kind = routing.StopReturn
+			stops = append(stops, pickup)
+		}
+	}
+	return stops, unrouted, nil
+}
+
+func locatable(address *geocode.Address) bool {
+	return address != nil && address.Location != nil
+}
+
+func stopKey(stop routing.Stop) string {
+	return stop.OrderID + "/" + stop.Kind
+}
+
+// sameStops reports whether two lists hold the same stops, in any order
+func sameStops(a, b []routing.Stop) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := map[string]bool{}
+	for _, stop := range a {
+		keys[stopKey(stop)] = true
+	}
+	for _, stop := range b {
+		if !keys[stopKey(stop)] {
+			return false
+		}
+	}
+	return true
+}
+
+func scanCourierRoute(row rowScanner) (*models.CourierRoute, error) {
+	var route models.CourierRoute
+	var stops, unrouted []byte
+	err := row.Scan(&route.ID, &route.CourierID, &route.Status, &stops, &unrouted, &route.DistanceKm, &route.LateStops,
+		&route.FinishAt, &route.CreatedAt, &route.AcceptedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stops, &route.Stops); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(unrouted, &route.UnroutedOrders); err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
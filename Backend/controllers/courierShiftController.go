@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// StartShift godoc
+// @Summary Start a courier shift
+// @Description Open a new shift for the logged-in courier and mark them available for orders
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} models.CourierStatusResponse "Courier status after starting the shift"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can start shifts"
+// @Failure 409 {object} map[string]string "A shift is already in progress"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/shifts/start [post]
+func (ac *CourierController) StartShift(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the courier row so concurrent requests cannot open two shifts
+	var status string
+	err = tx.QueryRow("SELECT status FROM couriers WHERE id = $1 FOR UPDATE", identity.CourierID).Scan(&status)
+	if err != nil {
+		log.Println("Error retrieving courier status:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if status != models.CourierOffShift {
+		http.Error(w, "A shift is already in progress", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	if _, err = tx.Exec("INSERT INTO courier_shifts (courier_id, started_at) VALUES ($1, $2)", identity.CourierID, now); err != nil {
+		log.Println("Error inserting shift:", err)
+		http.Error(w, "Could not start shift", http.StatusInternalServerError)
+		return
+	}
+
+	updateQuery := "UPDATE couriers SET status = $1, available = TRUE, last_active_at = $2 WHERE id = $3"
+	if _, err = tx.Exec(updateQuery, models.CourierOnShift, now, identity.CourierID); err != nil {
+		log.Println("Error updating courier status:", err)
+		http.Error(w, "Could not start shift", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing shift start:", err)
+		http.Error(w, "Could not start shift", http.StatusInternalServerError)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusCreated)
+}
+
+// EndShift godoc
+// @Summary End a courier shift
+// @Description Close the logged-in courier's current shift (and any open break) and mark them unavailable
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierStatusResponse "Courier status after ending the shift"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can end shifts"
+// @Failure 409 {object} map[string]string "No shift in progress"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/shifts/end [post]
+func (ac *CourierController) EndShift(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM couriers WHERE id = $1 FOR UPDATE", identity.CourierID).Scan(&status)
+	if err != nil {
+		log.Println("Error retrieving courier status:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if status == models.CourierOffShift {
+		http.Error(w, "No shift in progress", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+
+	// Close any break that is still open, then the shift itself
+	closeBreakQuery := `
+        UPDATE courier_breaks SET ended_at = $1
+        WHERE ended_at IS NULL AND shift_id IN (
+            SELECT id FROM courier_shifts WHERE courier_id = $2 AND ended_at IS NULL
+        )
+    `
+	if _, err = tx.Exec(closeBreakQuery, now, identity.CourierID); err != nil {
+		log.Println("Error closing break:", err)
+		http.Error(w, "Could not end shift", http.StatusInternalServerError)
+		return
+	}
+
+	closeShiftQuery := "UPDATE courier_shifts SET ended_at = $1 WHERE courier_id = $2 AND ended_at IS NULL"
+	if _, err = tx.Exec(closeShiftQuery, now, identity.CourierID); err != nil {
+		log.Println("Error closing shift:", err)
+		http.Error(w, "Could not end shift", http.StatusInternalServerError)
+		return
+	}
+
+	updateQuery := "UPDATE couriers SET status = $1, available = FALSE, last_active_at = $2 WHERE id = $3"
+	if _, err = tx.Exec(updateQuery, models.CourierOffShift, now, identity.CourierID); err != nil {
+		log.Println("Error updating courier status:", err)
+		http.Error(w, "Could not end shift", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing shift end:", err)
+		http.Error(w, "Could not end shift", http.StatusInternalServerError)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// StartBreak godoc
+// @Summary Start a break
+// @Description Start a break during the logged-in courier's shift. The courier is unavailable for orders until the break ends.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierStatusResponse "Courier status after starting the break"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can take breaks"
+// @Failure 409 {object} map[string]string "Courier is not on shift or already on a break"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/breaks/start [post]
+func (ac *CourierController) StartBreak(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM couriers WHERE id = $1 FOR UPDATE", identity.CourierID).Scan(&status)
+	if err != nil {
+		log.Println("Error retrieving courier status:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if status != models.CourierOnShift {
+		http.Error(w, "Courier is not on shift or already on a break", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	breakQuery := `
+        INSERT INTO courier_breaks (shift_id, started_at)
+        SELECT id, $1::TIMESTAMP FROM courier_shifts WHERE courier_id = $2 AND ended_at IS NULL
+    `
+	if _, err = tx.Exec(breakQuery, now, identity.CourierID); err != nil {
+		log.Println("Error inserting break:", err)
+		http.Error(w, "Could not start break", http.StatusInternalServerError)
+		return
+	}
+
+	updateQuery := "UPDATE couriers SET status = $1, available = FALSE, last_active_at = $2 WHERE id = $3"
+	if _, err = tx.Exec(updateQuery, models.CourierOnBreak, now, identity.CourierID); err != nil {
+		log.Println("Error updating courier status:", err)
+		http.Error(w, "Could not start break", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing break start:", err)
+		http.Error(w, "Could not start break", http.StatusInternalServerError)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// EndBreak godoc
+// @Summary End a break
+// @Description End the logged-in courier's current break and mark them available again
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierStatusResponse "Courier status after ending the break"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can end breaks"
+// @Failure 409 {object} map[string]string "Courier is not on a break"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/breaks/end [post]
+func (ac *CourierController) EndBreak(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM couriers WHERE id = $1 FOR UPDATE", identity.CourierID).Scan(&status)
+	if err != nil {
+		log.Println("Error retrieving courier status:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if status != models.CourierOnBreak {
+		http.Error(w, "Courier is not on a break", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	closeBreakQuery := `
+        UPDATE courier_breaks SET ended_at = $1
+        WHERE ended_at IS NULL AND shift_id IN (
+            SELECT id FROM courier_shifts WHERE courier_id = $2 AND ended_at IS NULL
+        )
+    `
+	if _, err = tx.Exec(closeBreakQuery, now, identity.CourierID); err != nil {
+		log.Println("Error closing break:", err)
+		http.Error(w, "Could not end break", http.StatusInternalServerError)
+		return
+	}
+
+	updateQuery := "UPDATE couriers SET status = $1, available = TRUE, last_active_at = $2 WHERE id = $3"
+	if _, err = tx.Exec(updateQuery, models.CourierOnShift, now, identity.CourierID); err != nil {
+		log.Println("Error updating courier status:", err)
+		http.Error(w, "Could not end break", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing break end:", err)
+		http.Error(w, "Could not end break", http.StatusInternalServerError)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// SetAvailability godoc
+// @Summary Go online or offline
+// @Description Toggle whether the logged-in courier accepts new orders. Going online requires an active shift that is not on a break.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param availability body models.CourierAvailabilityRequest true "Desired availability"
+// @Success 200 {object} models.CourierStatusResponse "Courier status after the change"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can change availability"
+// @Failure 409 {object} map[string]string "Courier must be on shift to go online"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/availability [put]
+func (ac *CourierController) SetAvailability(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	var req models.CourierAvailabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Available == nil {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	// Only couriers on an active shift can go online; going offline is always allowed
+	query := "UPDATE couriers SET available = $1, last_active_at = $2 WHERE id = $3 AND (NOT $1 OR status = $4)"
+	result, err := utils.DB.Exec(query, *req.Available, time.Now(), identity.CourierID, models.CourierOnShift)
+	if err != nil {
+		log.Println("Error updating courier availability:", err)
+		http.Error(w, "Could not update availability", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Courier must be on shift to go online", http.StatusConflict)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// Heartbeat godoc
+// @Summary Courier heartbeat
+// @Description Record that the logged-in courier's app is still active. Couriers that stop sending heartbeats are marked unavailable after the idle timeout.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierStatusResponse "Current courier status"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can send heartbeats"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/heartbeat [post]
+func (ac *CourierController) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	if _, err := utils.DB.Exec("UPDATE couriers SET last_active_at = $1 WHERE id = $2", time.Now(), identity.CourierID); err != nil {
+		log.Println("Error updating courier heartbeat:", err)
+		http.Error(w, "Could not record heartbeat", http.StatusInternalServerError)
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// GetCourierStatus godoc
+// @Summary Get courier status
+// @Description Get the logged-in courier's availability, last activity and current shift with its breaks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.CourierStatusResponse "Current courier status"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers have a status"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/status [get]
+func (ac *CourierController) GetCourierStatus(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	writeCourierStatus(w, identity.CourierID, http.StatusOK)
+}
+
+// writeCourierStatus loads the courier's status and open shift and writes them as the response
+func writeCourierStatus(w http.ResponseWriter, courierID string, statusCode int) {
+	var response models.CourierStatusResponse
+
+	query := "SELECT status, available, last_active_at FROM couriers WHERE id = $1"
+	err := utils.DB.QueryRow(query, courierID).Scan(&response.Status, &response.Available, &response.LastActiveAt)
+	if err != nil {
+		log.Println("Error retrieving courier status:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	var shift models.CourierShift
+	shiftQuery := "SELECT id, courier_id, started_at FROM courier_shifts WHERE courier_id = $1 AND ended_at IS NULL"
+	err = utils.DB.QueryRow(shiftQuery, courierID).Scan(&shift.ID, &shift.CourierID, &shift.StartedAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("Error retrieving courier shift:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil {
+		rows, err := utils.DB.Query("SELECT id, started_at, ended_at FROM courier_breaks WHERE shift_id = $1 ORDER BY started_at", shift.ID)
+		if err != nil {
+			log.Println("Error retrieving courier breaks:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		shift.Breaks = []models.CourierBreak{}
+		for rows.Next() {
+			var courierBreak models.CourierBreak
+			if err := rows.Scan(&courierBreak.ID, &courierBreak.StartedAt, &courierBreak.EndedAt); err != nil {
+				log.Println("Error scanning courier break:", err)
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+			shift.Breaks = append(shift.Breaks, courierBreak)
+		}
+		response.Shift = &shift
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"PTS/geo"
+	"PTS/hub"
+	"PTS/models"
+	"PTS/outbox"
+	"PTS/utils"
+	"PTS/windows"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxDeliveryAttempts = 3
+	maxAttemptNoteLength       = 500
+)
+
+// MaxDeliveryAttempts is how many failed attempts an order gets before it is returned to the store
+var MaxDeliveryAttempts = utils.GetEnvInt("DELIVERY_MAX_ATTEMPTS", defaultMaxDeliveryAttempts)
+
+// attemptReasons are the reason codes couriers can give for a failed attempt
+var attemptReasons = map[string]bool{
+	models.AttemptCustomerAbsent: true,
+	models.AttemptWrongAddress:   true,
+	models.AttemptRefused:        true,
+}
+
+// DeliveryAttemptController handles deliveries that could not be completed
+type DeliveryAttemptController struct{}
+
+// RecordFailedAttempt godoc
+// @Summary Record a failed delivery attempt
+// @Description Record that the package could not be handed over, with a reason code (customer_absent, wrong_address or refused). The order is rescheduled into the store's next delivery window, or sent back to the store (returning) if the customer refused it, the attempt limit is reached or the store has no window in the next two weeks. Only the assigned courier can record attempts.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param attempt body models.FailedAttemptRequest true "Reason, optional note and position"
+// @Success 201 {object} models.FailedAttemptResponse "The attempt and the updated order"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only the assigned courier can record attempts"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Order is not out for delivery"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/failed-attempts [post]
+func (ac *DeliveryAttemptController) RecordFailedAttempt(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleCourier {
+		http.Error(w, "Only the assigned courier can record attempts", http.StatusForbidden)
+		return
+	}
+	if !canTransition(order.Status, models.OrderRescheduled) {
+		http.Error(w, "Order is not out for delivery", http.StatusConflict)
+		return
+	}
+
+	var req models.FailedAttemptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !attemptReasons[req.Reason] {
+		http.Error(w, "Invalid reason", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > maxAttemptNoteLength {
+		http.Error(w, "Note is too long", http.StatusBadRequest)
+		return
+	}
+	if (req.Lat == nil) != (req.Lng == nil) || (req.Lat != nil && !(geo.Point{Lat: *req.Lat, Lng: *req.Lng}).Valid()) {
+		http.Error(w, "Invalid coordinates", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := windows.ForStore(order.StoreId)
+	if err != nil {
+		log.Println("Error loading delivery schedule:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Refusals go straight back; otherwise try again in the next window until the attempts run out
+	now := time.Now()
+	attempt := models.DeliveryAttempt{OrderID: order.ID, CourierID: identity.CourierID, Reason: req.Reason,
+		Note: nullIfEmpty(req.Note), Lat: req.Lat, Lng: req.Lng, AttemptNumber: order.Attempts + 1}
+	slot, _, hasSlot := schedule.NextAfter(now)
+	status := models.OrderRescheduled
+	attempt.Outcome = models.AttemptRescheduled
+	if req.Reason == models.AttemptRefused || attempt.AttemptNumber >= MaxDeliveryAttempts || !hasSlot {
+		status = models.OrderReturning
+		attempt.Outcome = models.AttemptReturning
+	} else {
+		attempt.RescheduledWindow, attempt.RescheduledDate = &slot.Window, &slot.Date
+	}
+
+	var updated models.Order
+	err = utils.WithTx(func(tx *sql.Tx) error {
+		// A redelivery always gets its slot, even if the window has filled up since
+		if attempt.Outcome == models.AttemptRescheduled {
+			if err := windows.Reserve(tx, order.StoreId, slot, nil); err != nil {
+				return err
+			}
+		}
+
+		query := `
+            UPDATE orders SET status = $1, attempts = $2, delivery_window = COALESCE($3, delivery_window),
+                delivery_date = COALESCE($4::DATE, delivery_date), updated_at = $5
+            WHERE id = $6 AND status = $7 AND attempts = $8
+            RETURNING ` + orderColumns
+		err := scanOrder(tx.QueryRow(query, status, attempt.AttemptNumber, attempt.RescheduledWindow, attempt.RescheduledDate,
+			now, order.ID, order.Status, order.Attempts), &updated)
+		if err != nil {
+			return err
+		}
+
+		query = `
+            INSERT INTO delivery_attempts (order_id, courier_id, attempt_number, reason, note, lat, lng, outcome, rescheduled_window, rescheduled_date, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+            RETURNING id, created_at
+        `
+		err = tx.QueryRow(query, attempt.OrderID, attempt.CourierID, attempt.AttemptNumber, attempt.Reason, attempt.Note, attempt.Lat,
+			attempt.Lng, attempt.Outcome, attempt.RescheduledWindow, attempt.RescheduledDate, now).Scan(&attempt.ID, &attempt.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		eventData := models.OrderEventData{Order: updated, PreviousStatus: order.Status}
+		return recordOrderEvent(tx, hub.OrderStatusChanged, eventData)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order was changed by another request", http.StatusConflict)
+			return
+		}
+		log.Println("Error recording failed delivery attempt:", err)
+		http.Error(w, "Could not record attempt", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.FailedAttemptResponse{Attempt: attempt, Order: updated})
+}
+
+// ListFailedAttempts godoc
+// @Summary List an order's failed delivery attempts
+// @Description List the failed delivery attempts recorded for an order, oldest first. Available to the customer, the assigned courier and the store's staff.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {array} models.DeliveryAttempt "Failed attempts, oldest first"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/failed-attempts [get]
+func (ac *DeliveryAttemptController) ListFailedAttempts(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	query := `
+        SELECT id, order_id, courier_id, attempt_number, reason, note, lat, lng, outcome,
+            rescheduled_window, to_char(rescheduled_date, 'YYYY-MM-DD'), created_at
+        FROM delivery_attempts WHERE order_id = $1
+        ORDER BY attempt_number
+    `
+	rows, err := utils.DB.Query(query, order.ID)
+	if err != nil {
+		log.Println("Error retrieving delivery attempts:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	attempts := []models.DeliveryAttempt{}
+	for rows.Next() {
+		var attempt models.DeliveryAttempt
+		err := rows.Scan(&attempt.ID, &attempt.OrderID, &attempt.CourierID, &attempt.AttemptNumber, &attempt.Reason, &attempt.Note,
+			&attempt.Lat, &attempt.Lng, &attempt.Outcome, &attempt.RescheduledWindow, &attempt.RescheduledDate, &attempt.CreatedAt)
+		if err != nil {
+			log.Println("Error scanning delivery attempt:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
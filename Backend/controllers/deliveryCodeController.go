@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"PTS/handoff"
+	"PTS/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const maxOverrideReasonLength = 500
+
+// DeliveryCodeController shows customers their delivery code and lets store staff override it
+type DeliveryCodeController struct{}
+
+// GetDeliveryCode godoc
+// @Summary Get an order's delivery code
+// @Description Get the code the customer gives the courier at handoff, with the number of wrong attempts and any override. Only the customer sees the code itself; store staff see its status. Couriers cannot view it.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.DeliveryCode "The delivery code"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order's delivery code"
+// @Failure 404 {object} map[string]string "Order or delivery code not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/delivery-code [get]
+func (dc *DeliveryCodeController) GetDeliveryCode(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role == models.RoleCourier {
+		http.Error(w, "Not allowed to view this order's delivery code", http.StatusForbidden)
+		return
+	}
+
+	writeDeliveryCode(w, order.ID, identity.Role == models.RoleUser)
+}
+
+// OverrideDeliveryCode godoc
+// @Summary Override an order's delivery code
+// @Description Let the order be delivered without its delivery code, for example when the customer cannot show it or the code is locked after too many wrong attempts. The reason is recorded with the admin or owner who gave it.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param request body models.DeliveryCodeOverrideRequest true "Reason for the override"
+// @Success 200 {object} models.DeliveryCode "The delivery code status after the override"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only the store's admins and owner can override delivery codes"
+// @Failure 404 {object} map[string]string "Order or delivery code not found"
+// @Failure 409 {object} map[string]string "Order is already finished"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/delivery-code/override [post]
+func (dc *DeliveryCodeController) OverrideDeliveryCode(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleAdmin && identity.Role != models.RoleOwner {
+		http.Error(w, "Only the store's admins and owner can override delivery codes", http.StatusForbidden)
+		return
+	}
+	if isTerminal(order.Status) || order.Status == models.OrderReturning {
+		http.Error(w, "Order is already finished", http.StatusConflict)
+		return
+	}
+
+	var req models.DeliveryCodeOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if len(req.Reason) > maxOverrideReasonLength {
+		http.Error(w, "Reason is too long", http.StatusBadRequest)
+		return
+	}
+
+	if err := handoff.Override(order.ID, identity, req.Reason); err != nil {
+		if err == handoff.ErrNoCode {
+			http.Error(w, "Order has no delivery code", http.StatusNotFound)
+			return
+		}
+		log.Println("Error overriding delivery code:", err)
+		http.Error(w, "Could not override delivery code", http.StatusInternalServerError)
+		return
+	}
+
+	writeDeliveryCode(w, order.ID, false)
+}
+
+// writeDeliveryCode writes the order's delivery code status as the response, with the code itself if withCode is set
+func writeDeliveryCode(w http.ResponseWriter, orderID string, withCode bool) {
+	code, err := handoff.Get(orderID, withCode)
+	if err != nil {
+		if err == handoff.ErrNoCode {
+			http.Error(w, "Order has no delivery code", http.StatusNotFound)
+			return
+		}
+		log.Println("Error retrieving delivery code:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(code)
+}
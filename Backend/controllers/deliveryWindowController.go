@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/windows"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAvailabilityDays = 7
+	maxAvailabilityDays     = 31
+)
+
+// DeliveryWindowController lets owners set when their store delivers and how many orders each window takes
+type DeliveryWindowController struct{}
+
+// GetDeliverySchedule godoc
+// @Summary Get the store's delivery windows
+// @Description Get the delivery windows the store offers on each weekday, with their capacity. Stores that have not set a schedule offer morning, midDay and night every day without a limit. Available to the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} windows.Schedule "Windows per weekday"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view delivery windows"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /delivery-windows [get]
+func (dc *DeliveryWindowController) GetDeliverySchedule(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	schedule, err := windows.ForStore(identity.StoreId)
+	if err != nil {
+		log.Println("Error loading delivery schedule:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// UpdateDeliverySchedule godoc
+// @Summary Set the store's delivery windows
+// @Description Replace the delivery windows the owner's store offers on each weekday (sunday to saturday). A window's capacity is max_orders, or orders_per_courier times the store's couriers, whichever is lower; without either it is unlimited. Orders already booked keep their slots.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param schedule body windows.Schedule true "Windows per weekday (hours in the delivery timezone)"
+// @Success 200 {object} windows.Schedule "The saved schedule"
+// @Failure 400 {object} map[string]string "Invalid schedule"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can set delivery windows"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /delivery-windows [put]
+func (dc *DeliveryWindowController) UpdateDeliverySchedule(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var schedule windows.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := windows.SaveSchedule(identity.StoreId, identity.UserID, schedule)
+	if err != nil {
+		log.Println("Error saving delivery schedule:", err)
+		http.Error(w, "Could not save delivery windows", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// GetWindowAvailability godoc
+// @Summary Get delivery window availability
+// @Description List a store's delivery windows day by day with how many orders each can still take, for picking a window when placing an order.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param from query string false "First day (YYYY-MM-DD, default today)"
+// @Param days query int false "Number of days (default 7, max 31)"
+// @Success 200 {array} windows.Availability "Slots in time order"
+// @Failure 400 {object} map[string]string "Invalid query parameters"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/{id}/delivery-windows/availability [get]
+func (dc *DeliveryWindowController) GetWindowAvailability(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := requireStore(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	now := time.Now()
+	from := now
+	if value := params.Get("from"); value != "" {
+		var err error
+		from, err = time.ParseInLocation(time.DateOnly, value, windows.Location)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+	}
+	days := defaultAvailabilityDays
+	if value := params.Get("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxAvailabilityDays {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	slots, err := windows.StoreAvailability(storeID, from, days, now)
+	if err != nil {
+		log.Println("Error retrieving window availability:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slots)
+}
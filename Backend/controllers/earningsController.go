@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"PTS/earnings"
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultEarningsDays is how many days earnings summaries and statements cover when no from day is given
+const defaultEarningsDays = 30
+
+// EarningsController handles courier pay rules, earnings and payout statements
+type EarningsController struct{}
+
+// CreatePayRules godoc
+// @Summary Publish courier pay rules
+// @Description Save a new version of how the owner's store pays its couriers: per delivery, per km and bonuses. Deliveries completed from now on are paid under it.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rules body earnings.PayRules true "Pay rules (amounts in minor currency units)"
+// @Success 201 {object} earnings.PayRules "The saved pay rules with their version"
+// @Failure 400 {object} map[string]string "Invalid pay rules"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage pay rules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /courier-pay-rules [post]
+func (ec *EarningsController) CreatePayRules(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var rules earnings.PayRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		saved, err := earnings.SaveRules(tx, identity.StoreId, identity.UserID, rules)
+		rules = saved
+		return err
+	})
+	if err != nil {
+		log.Println("Error saving pay rules:", err)
+		http.Error(w, "Could not save pay rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rules)
+}
+
+// ListPayRules godoc
+// @Summary List courier pay rule versions
+// @Description List every version of the store's courier pay rules, newest first. Available to the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} earnings.PayRules "Pay rule versions"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view pay rule history"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /courier-pay-rules [get]
+func (ec *EarningsController) ListPayRules(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	list, err := earnings.ListRules(identity.StoreId)
+	if err != nil {
+		log.Println("Error retrieving pay rules:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetCurrentPayRules godoc
+// @Summary Get the current courier pay rules
+// @Description Get the pay rules deliveries of the caller's store are currently paid under. Available to the store's couriers, admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} earnings.PayRules "The current pay rules"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store couriers and staff can view pay rules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /courier-pay-rules/current [get]
+func (ec *EarningsController) GetCurrentPayRules(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rules, err := earnings.CurrentRules(identity.StoreId)
+	if err != nil {
+		log.Println("Error loading pay rules:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// GetCourierEarnings godoc
+// @Summary Get a courier's earnings
+// @Description Total a courier's earnings per day or per week (starting Monday) between two days. Available to the courier and the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Courier ID"
+// @Param group query string false "day (default) or week"
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Success 200 {object} models.EarningsSummary "Earnings per day or week"
+// @Failure 400 {object} map[string]string "Invalid grouping or period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this courier's earnings"
+// @Failure 404 {object} map[string]string "Courier not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/{id}/earnings [get]
+func (ec *EarningsController) GetCourierEarnings(w http.ResponseWriter, r *http.Request) {
+	_, courierID, _, ok := loadCourierAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	switch group {
+	case "":
+		group = "day"
+	case "day", "week":
+	default:
+		http.Error(w, "Invalid group", http.StatusBadRequest)
+		return
+	}
+
+	from, to, ok := parseDayRange(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := earnings.Summary(courierID, group, from, to)
+	if err != nil {
+		log.Println("Error building earnings summary:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GetPayoutStatement godoc
+// @Summary Export a payout statement
+// @Description Export every courier earning of the owner's store between two days, totalled per courier, as JSON or CSV
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} models.PayoutStatement "The payout statement"
+// @Failure 400 {object} map[string]string "Invalid format or period"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can export payout statements"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/payout-statement [get]
+func (ec *EarningsController) GetPayoutStatement(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	from, to, ok := parseDayRange(w, r)
+	if !ok {
+		return
+	}
+
+	statement, err := earnings.Statement(identity.StoreId, from, to)
+	if err != nil {
+		log.Println("Error building payout statement:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		writePayoutCSV(w, statement)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+// writePayoutCSV writes one row per earning followed by a total row per courier
+func writePayoutCSV(w http.ResponseWriter, statement *models.PayoutStatement) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="payout-`+statement.From+`-to-`+statement.To+`.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"courier_id", "earned_on", "order_id", "kind", "description", "amount", "currency"})
+	for _, payout := range statement.Couriers {
+		for _, entry := range payout.Entries {
+			orderID := ""
+			if entry.OrderID != nil {
+				orderID = *entry.OrderID
+			}
+			writer.Write([]string{payout.CourierID, entry.EarnedOn, orderID, entry.Kind, entry.Description,
+				strconv.FormatInt(entry.Amount, 10), entry.Currency})
+		}
+		writer.Write([]string{payout.CourierID, "", "", "total", strconv.Itoa(payout.Deliveries) + " deliveries",
+			strconv.FormatInt(payout.Total, 10), payout.Currency})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Println("Error writing payout statement:", err)
+	}
+}
+
+// parseDayRange reads the from and to day query parameters, writing an error response if they are invalid
+func parseDayRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	params := r.URL.Query()
+
+	to := time.Now()
+	if value := params.Get("to"); value != "" {
+		var err error
+		to, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid to day", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+	}
+
+	from := to.AddDate(0, 0, -defaultEarningsDays)
+	if value := params.Get("from"); value != "" {
+		var err error
+		from, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid from day", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+	}
+
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
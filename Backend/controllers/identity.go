@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+)
+
+var errNotAuthenticated = errors.New("request is not authenticated")
+
+// resolveIdentity loads the account and role of the user behind the request's JWT
+func resolveIdentity(r *http.Request) (*models.Identity, error) {
+	email, ok := utils.EmailFromContext(r.Context())
+	if !ok {
+		return nil, errNotAuthenticated
+	}
+
+	var courierID, courierStore, adminStore, ownerStore sql.NullString
+	identity := &models.Identity{Email: email}
+
+	query := `
+        SELECT
+            u.id, c.id, c.store_id, a.store_id, o.store_id
+        FROM users u
+        LEFT JOIN couriers c ON u.id = c.user_id
+        LEFT JOIN admins a ON u.id = a.user_id
+        LEFT JOIN owners o ON u.id = o.user_id
+        WHERE u.email = $1
+    `
+	err := utils.DB.QueryRow(query, email).Scan(&identity.UserID, &courierID, &courierStore, &adminStore, &ownerStore)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNotAuthenticated
+		}
+		return nil, err
+	}
+
+	switch {
+	case courierID.Valid:
+		identity.Role = models.RoleCourier
+		identity.CourierID = courierID.String
+		identity.StoreId = courierStore.String
+	case adminStore.Valid:
+		identity.Role = models.RoleAdmin
+		identity.StoreId = adminStore.String
+	case ownerStore.Valid:
+		identity.Role = models.RoleOwner
+		identity.StoreId = ownerStore.String
+	default:
+		identity.Role = models.RoleUser
+	}
+
+	return identity, nil
+}
+
+// requireRole resolves the caller's identity and writes an error response unless it has one of the given roles
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (*models.Identity, bool) {
+	identity, err := resolveIdentity(r)
+	if err != nil {
+		if err == errNotAuthenticated {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		} else {
+			log.Println("Error resolving identity:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	for _, role := range roles {
+		if identity.Role == role {
+			return identity, true
+		}
+	}
+
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return nil, false
+}
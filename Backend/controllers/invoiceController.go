@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"PTS/invoices"
+	"PTS/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// InvoiceController serves order invoices and store statements
+type InvoiceController struct{}
+
+// GetOrderInvoice godoc
+// @Summary Download an order's invoice
+// @Description Get the numbered invoice issued when the order was delivered, as JSON or PDF. Once the payment is captured the PDF is titled as a receipt. Available to the customer and the store's admins and owner.
+// @Produce json
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param format query string false "json (default) or pdf"
+// @Success 200 {object} models.Invoice "The invoice"
+// @Failure 400 {object} map[string]string "Invalid format"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this invoice"
+// @Failure 404 {object} map[string]string "Order or invoice not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/invoice [get]
+func (ic *InvoiceController) GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role == models.RoleCourier {
+		http.Error(w, "Not allowed to view this invoice", http.StatusForbidden)
+		return
+	}
+
+	format, ok := documentFormat(w, r)
+	if !ok {
+		return
+	}
+
+	invoice, err := invoices.ForOrder(order.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invoice not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error loading invoice:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "pdf" {
+		writePDF(w, invoice.InvoiceNumber+".pdf", invoices.InvoicePDF(invoice))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoice)
+}
+
+// GetStoreStatement godoc
+// @Summary Download a monthly statement
+// @Description Total the invoices the admin's or owner's store issued in a month, as JSON or PDF
+// @Produce json
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param month path string true "Month, YYYY-MM"
+// @Param format query string false "json (default) or pdf"
+// @Success 200 {object} models.StoreStatement "The statement"
+// @Failure 400 {object} map[string]string "Invalid month or format"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view statements"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/statements/{month} [get]
+func (ic *InvoiceController) GetStoreStatement(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	month, err := time.Parse("2006-01", mux.Vars(r)["month"])
+	if err != nil {
+		http.Error(w, "Invalid month", http.StatusBadRequest)
+		return
+	}
+	format, ok := documentFormat(w, r)
+	if !ok {
+		return
+	}
+
+	statement, err := invoices.Statement(identity.StoreId, month)
+	if err != nil {
+		log.Println("Error building statement:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "pdf" {
+		writePDF(w, "statement-"+statement.Month+".pdf", invoices.StatementPDF(statement))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+// documentFormat reads the format query parameter, writing an error response if it is not json or pdf
+func documentFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		return "json", true
+	case "pdf":
+		return "pdf", true
+	}
+	http.Error(w, "Invalid format", http.StatusBadRequest)
+	return "", false
+}
+
+// writePDF sends a PDF document as a download
+func writePDF(w http.ResponseWriter, filename string, document []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Write(document)
+}
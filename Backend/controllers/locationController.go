@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	maxPingsPerBatch     = 500
+	defaultTrailLength   = 200
+	maxTrailLength       = 1000
+	maxPingClockSkew     = 5 * time.Minute
+	defaultPingRetention = 7 * 24 * time.Hour
+)
+
+// LocationPingRetention is how long courier location pings are kept before being purged
+var LocationPingRetention = utils.GetEnvDuration("COURIER_LOCATION_RETENTION", defaultPingRetention)
+
+// LocationController handles courier location ingestion and order tracking
+type LocationController struct{}
+
+// IngestLocations godoc
+// @Summary Upload courier location pings
+// @Description Upload a batch of GPS pings recorded by the logged-in courier's device. Pings older than the retention period are dropped. Uploading pings also counts as a heartbeat.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param pings body models.LocationBatchRequest true "Batch of location pings"
+// @Success 201 {object} map[string]int "Number of pings stored"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only couriers can upload locations"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /couriers/locations [post]
+func (lc *LocationController) IngestLocations(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleCourier)
+	if !ok {
+		return
+	}
+
+	var req models.LocationBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Basic validation
+	if len(req.Pings) == 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if len(req.Pings) > maxPingsPerBatch {
+		http.Error(w, "Too many pings in one batch (max "+strconv.Itoa(maxPingsPerBatch)+")", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	oldest := now.Add(-LocationPingRetention)
+	pings := make([]models.LocationPing, 0, len(req.Pings))
+	for _, ping := range req.Pings {
+		if ping.Latitude < -90 || ping.Latitude > 90 || ping.Longitude < -180 || ping.Longitude > 180 {
+			http.Error(w, "Invalid coordinates", http.StatusBadRequest)
+			return
+		}
+		if ping.RecordedAt.IsZero() || ping.RecordedAt.After(now.Add(maxPingClockSkew)) {
+			http.Error(w, "Invalid ping timestamp", http.StatusBadRequest)
+			return
+		}
+		if ping.RecordedAt.Before(oldest) {
+			continue
+		}
+		pings = append(pings, ping)
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// COPY the whole batch in one round trip instead of one INSERT per ping
+	stmt, err := tx.Prepare(pq.CopyIn("courier_locations", "courier_id", "lat", "lng", "accuracy", "speed", "recorded_at"))
+	if err != nil {
+		log.Println("Error preparing location copy:", err)
+		http.Error(w, "Could not store locations", http.StatusInternalServerError)
+		return
+	}
+	for _, ping := range pings {
+		if _, err = stmt.Exec(identity.CourierID, ping.Latitude, ping.Longitude, ping.Accuracy, ping.Speed, ping.RecordedAt); err != nil {
+			stmt.Close()
+			log.Println("Error copying location ping:", err)
+			http.Error(w, "Could not store locations", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		log.Println("Error flushing location copy:", err)
+		http.Error(w, "Could not store locations", http.StatusInternalServerError)
+		return
+	}
+	stmt.Close()
+
+	if _, err = tx.Exec("UPDATE couriers SET last_active_at = $1 WHERE id = $2", now, identity.CourierID); err != nil {
+		log.Println("Error updating courier activity:", err)
+		http.Error(w, "Could not store locations", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing locations:", err)
+		http.Error(w, "Could not store locations", http.StatusInternalServerError)
+		return
+	}
+
+	// Live trackers only need the most recent position from the batch
+	if len(pings) > 0 {
+		latest := pings[0]
+		for _, ping := range pings[1:] {
+			if ping.RecordedAt.After(latest.RecordedAt) {
+				latest = ping
+			}
+		}
+		publishCourierLocation(identity.CourierID, latest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"stored": len(pings)})
+}
+
+// GetOrderTracking godoc
+// @Summary Track an order's courier
+// @Description Get the latest position and breadcrumb trail of the courier handling an order. The trail only covers the time since the courier set off with the package and is empty unless the order is picked_up or in_transit. Available to the customer, the assigned courier and the store's staff.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param limit query int false "Maximum number of trail points (default 200, max 1000)"
+// @Success 200 {object} models.OrderTrackingResponse "Latest position and trail"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to track this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/tracking [get]
+func (lc *LocationController) GetOrderTracking(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultTrailLength
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTrailLength {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := models.OrderTrackingResponse{
+		OrderID:   order.ID,
+		Status:    order.Status,
+		CourierID: order.CourierID,
+		Trail:     []models.LocationPing{},
+	}
+
+	// The courier's position is only shown while they are carrying this order, and only from when they set off
+	// with it, so customers do not see where the courier was before
+	var since *time.Time
+	if order.CourierID != nil && (order.Status == models.OrderPickedUp || order.Status == models.OrderInTransit) {
+		if err := utils.DB.QueryRow("SELECT tracking_since FROM orders WHERE id = $1", order.ID).Scan(&since); err != nil {
+			log.Println("Error retrieving order tracking start:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if since != nil {
+		query := `
+            SELECT lat, lng, accuracy, speed, recorded_at
+            FROM courier_locations
+            WHERE courier_id = $1 AND recorded_at >= $2
+            ORDER BY recorded_at DESC
+            LIMIT $3
+        `
+		rows, err := utils.DB.Query(query, *order.CourierID, *since, limit)
+		if err != nil {
+			log.Println("Error retrieving courier locations:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var ping models.LocationPing
+			if err := rows.Scan(&ping.Latitude, &ping.Longitude, &ping.Accuracy, &ping.Speed, &ping.RecordedAt); err != nil {
+				log.Println("Error scanning courier location:", err)
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+			response.Trail = append(response.Trail, ping)
+		}
+
+		// Rows come newest first; return the trail in chronological order
+		for i, j := 0, len(response.Trail)-1; i < j; i, j = i+1, j-1 {
+			response.Trail[i], response.Trail[j] = response.Trail[j], response.Trail[i]
+		}
+		if len(response.Trail) > 0 {
+			latest := response.Trail[len(response.Trail)-1]
+			response.Latest = &latest
+		}
+	}
+
+	estimate, err := estimateOrder(order, nil)
+	if err != nil {
+		log.Println("Error estimating order ETA:", err)
+	}
+	response.ETA = estimate
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
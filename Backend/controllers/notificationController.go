@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/notifications"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultNotificationPage = 50
+	maxNotificationPage     = 200
+)
+
+// NotificationController manages users' notification settings and in-app inbox
+type NotificationController struct{}
+
+// GetNotificationPreferences godoc
+// @Summary Get notification preferences
+// @Description Get which channels (email, sms, in_app) the logged-in user receives order notifications on
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.NotificationPreferences "Enabled flag per channel"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /notifications/preferences [get]
+func (nc *NotificationController) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	writeNotificationPreferences(w, identity.UserID)
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary Update notification preferences
+// @Description Enable or disable notification channels for the logged-in user. Channels left out keep their current setting.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param preferences body models.NotificationPreferences true "Enabled flag per channel"
+// @Success 200 {object} models.NotificationPreferences "Enabled flag per channel after the update"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /notifications/preferences [put]
+func (nc *NotificationController) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Channels) == 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	for channel := range req.Channels {
+		if !notifications.IsValidChannel(channel) {
+			http.Error(w, "Unknown channel: "+channel, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		for channel, enabled := range req.Channels {
+			if err := notifications.SetChannel(tx, identity.UserID, channel, enabled); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error saving notification preferences:", err)
+		http.Error(w, "Could not update preferences", http.StatusInternalServerError)
+		return
+	}
+
+	writeNotificationPreferences(w, identity.UserID)
+}
+
+// ListNotifications godoc
+// @Summary List notifications
+// @Description List the logged-in user's in-app notifications, newest first. Works for customers, couriers, admins and owners.
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "unread, read or all (default all)"
+// @Param limit query int false "Maximum number of notifications (default 50, max 200)"
+// @Param before query string false "Only notifications created before this RFC 3339 time, for paging"
+// @Success 200 {array} models.Notification "Notifications, newest first"
+// @Failure 400 {object} map[string]string "Invalid query parameters"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /notifications [get]
+func (nc *NotificationController) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+
+	var statusFilter string
+	switch params.Get("status") {
+	case "", "all":
+		statusFilter = ""
+	case "unread":
+		statusFilter = " AND read_at IS NULL"
+	case "read":
+		statusFilter = " AND read_at IS NOT NULL"
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultNotificationPage
+	if value := params.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxNotificationPage {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	before := time.Now()
+	if value := params.Get("before"); value != "" {
+		var err error
+		before, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			http.Error(w, "Invalid before time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := `
+        SELECT id, event_type, order_id, title, body, created_at, read_at
+        FROM notifications
+        WHERE user_id = $1 AND created_at < $2` + statusFilter + `
+        ORDER BY created_at DESC
+        LIMIT $3
+    `
+	rows, err := utils.DB.Query(query, identity.UserID, before, limit)
+	if err != nil {
+		log.Println("Error retrieving notifications:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		err := rows.Scan(&notification.ID, &notification.EventType, &notification.OrderID, &notification.Title,
+			&notification.Body, &notification.CreatedAt, &notification.ReadAt)
+		if err != nil {
+			log.Println("Error scanning notification:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		list = append(list, notification)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetUnreadCount godoc
+// @Summary Get unread notification count
+// @Description Get the number of unread in-app notifications, for the notification badge
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.UnreadCountResponse "Unread count"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /notifications/unread-count [get]
+func (nc *NotificationController) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	writeUnreadCount(w, identity.UserID)
+}
+
+// MarkNotificationsRead godoc
+// @Summary Mark notifications as read
+// @Description Mark the given notifications, or all of them, as read. IDs that do not belong to the user are ignored.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MarkNotificationsReadRequest true "Notification IDs, or all"
+// @Success 200 {object} models.UnreadCountResponse "Unread count after the update"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /notifications/read [post]
+func (nc *NotificationController) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.MarkNotificationsReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.All && len(req.IDs) == 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	for _, id := range req.IDs {
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, "Invalid notification ID: "+id, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	if req.All {
+		_, err = utils.DB.Exec("UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL", time.Now(), identity.UserID)
+	} else {
+		_, err = utils.DB.Exec("UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL AND id = ANY($3)",
+			time.Now(), identity.UserID, pq.Array(req.IDs))
+	}
+	if err != nil {
+		log.Println("Error marking notifications read:", err)
+		http.Error(w, "Could not update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	writeUnreadCount(w, identity.UserID)
+}
+
+// writeUnreadCount writes the user's unread notification count as the response
+func writeUnreadCount(w http.ResponseWriter, userID string) {
+	var response models.UnreadCountResponse
+	err := utils.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&response.Unread)
+	if err != nil {
+		log.Println("Error counting unread notifications:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeNotificationPreferences writes the user's effective channel settings as the response
+func writeNotificationPreferences(w http.ResponseWriter, userID string) {
+	channels, err := notifications.EnabledChannels(userID)
+	if err != nil {
+		log.Println("Error retrieving notification preferences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationPreferences{Channels: channels})
+}
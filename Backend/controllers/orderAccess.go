@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const orderColumns = "id, user_id, store_id, courier_id, pickup_location, drop_off_location, pickup_address, drop_off_address, delivery_window, to_char(delivery_date, 'YYYY-MM-DD') AS delivery_date, attempts, package_details, package_size, status, quote_id, price_total, price_currency, rate_card_id, promo_code_id, discount_total, payment_status, created_at, updated_at"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder reads a row selected with orderColumns into an order
+func scanOrder(row rowScanner, order *models.Order) error {
+	var pickup, dropOff, packageSize []byte
+	err := row.Scan(
+		&order.ID, &order.UserID, &order.StoreId, &order.CourierID, &order.PickupLocation, &order.DropOffLocation, &pickup, &dropOff,
+		&order.DeliveryWindow, &order.DeliveryDate, &order.Attempts, &order.PackageDetails, &packageSize, &order.Status, &order.QuoteID, &order.PriceTotal, &order.PriceCurrency,
+		&order.RateCardID, &order.PromoCodeID, &order.DiscountTotal, &order.PaymentStatus, &order.CreatedAt, &order.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	order.Package = nil
+	if packageSize != nil {
+		if err := json.Unmarshal(packageSize, &order.Package); err != nil {
+			return err
+		}
+	}
+	return unmarshalAddresses(pickup, dropOff, &order.PickupAddress, &order.DropOffAddress)
+}
+
+// scanOrders reads and closes rows selected with orderColumns
+func scanOrders(rows *sql.Rows) ([]models.Order, error) {
+	defer rows.Close()
+	orders := []models.Order{}
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// loadOrder fetches an order by ID, returning sql.ErrNoRows if it does not exist
+func loadOrder(orderID string) (*models.Order, error) {
+	if _, err := uuid.Parse(orderID); err != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	var order models.Order
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = $1"
+	if err := scanOrder(utils.DB.QueryRow(query, orderID), &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// canViewOrder reports whether the caller is the customer, the assigned courier, or staff of the order's store
+func canViewOrder(identity *models.Identity, order *models.Order) bool {
+	switch identity.Role {
+	case models.RoleCourier:
+		return order.CourierID != nil && *order.CourierID == identity.CourierID
+	case models.RoleAdmin, models.RoleOwner:
+		return order.StoreId == identity.StoreId
+	default:
+		return order.UserID == identity.UserID
+	}
+}
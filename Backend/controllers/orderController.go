@@ -0,0 +1,694 @@
+package controllers
+
+import (
+	"PTS/earnings"
+	"PTS/geocode"
+	"PTS/handoff"
+	"PTS/hub"
+	"PTS/invoices"
+	"PTS/models"
+	"PTS/outbox"
+	"PTS/payments"
+	"PTS/promos"
+	"PTS/utils"
+	"PTS/windows"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// OrderController handles placing, viewing and progressing orders
+type OrderController struct{}
+
+// orderTransitions lists the statuses an order may move to from each status
+var orderTransitions = map[string][]string{
+	models.OrderPending:     {models.OrderAssigned, models.OrderCancelled},
+	models.OrderAssigned:    {models.OrderAssigned, models.OrderPickedUp, models.OrderCancelled},
+	models.OrderPickedUp:    {models.OrderInTransit, models.OrderDelivered, models.OrderRescheduled, models.OrderReturning},
+	models.OrderInTransit:   {models.OrderDelivered, models.OrderRescheduled, models.OrderReturning},
+	models.OrderRescheduled: {models.OrderInTransit, models.OrderReturning},
+	models.OrderReturning:   {models.OrderReturned},
+}
+
+// isTerminal reports whether an order has reached a status it can no longer leave
+func isTerminal(status string) bool {
+	return status == models.OrderDelivered || status == models.OrderCancelled || status == models.OrderReturned
+}
+
+// canTransition reports whether an order may move from one status to another
+func canTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PlaceOrder godoc
+// @Summary Place an order
+// @Description Place a new delivery order with a store as the logged-in customer. Give the pickup and drop-off as saved address IDs, structured addresses or free text; they are geocoded and copied onto the order. The delivery window (and optional delivery_date) must be one the store offers and is booked against its capacity. Pass a quote_id from POST /quotes to lock in its price, and optionally a promo_code to discount it; the quote must be for the same pickup, drop-off, window and zone, and for a package at least as big and heavy as the one given. The package's weight and dimensions are required with a quote and optional otherwise; when given, the order is only assigned to couriers whose vehicle can carry it.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body models.PlaceOrderRequest true "Order data"
+// @Success 201 {object} models.Order "The created order"
+// @Failure 400 {object} map[string]string "Missing required fields, invalid input, an address could not be found, the drop-off is outside the store's service zones or promo code cannot be applied"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers can place orders"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 409 {object} map[string]string "Quote is expired, already used or does not match this order, or the delivery window is full"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders [post]
+func (oc *OrderController) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	var req models.PlaceOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Basic validation
+	if req.StoreId == "" || req.Delivery == "" || req.PackageDetails == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if req.PromoCode != "" && req.QuoteID == "" {
+		http.Error(w, "Promo codes can only be applied to a quoted price", http.StatusBadRequest)
+		return
+	}
+	if req.QuoteID != "" && req.Package == nil {
+		http.Error(w, "Orders placed with a quote must give the package's weight and dimensions", http.StatusBadRequest)
+		return
+	}
+	if req.Package != nil {
+		if err := req.Package.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if _, err := uuid.Parse(req.StoreId); err != nil {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	// Check if the store exists
+	var storeExists bool
+	err := utils.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM stores WHERE id = $1)", req.StoreId).Scan(&storeExists)
+	if err != nil {
+		log.Println("Error checking store existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !storeExists {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	// Geocode both ends; the order keeps a copy of the resolved addresses
+	pickup, dropOff, err := resolveOrderAddresses(identity.UserID, req.Pickup, req.DropOff, req.OrderAddresses)
+	var addressRejection *addressRejection
+	if errors.As(err, &addressRejection) {
+		http.Error(w, addressRejection.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error resolving order addresses:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	zone, err := checkServiceArea(req.StoreId, dropOff)
+	if err != nil {
+		if errors.As(err, &addressRejection) {
+			http.Error(w, addressRejection.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Println("Error checking service area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	var zoneName string
+	if zone != nil {
+		zoneName = zone.Name
+	}
+
+	// Find the delivery slot; it is booked in the same transaction that creates the order
+	slot, capacity, err := windows.Resolve(req.StoreId, req.Delivery, req.DeliveryDate, time.Now())
+	var windowRejection *windows.Rejection
+	if errors.As(err, &windowRejection) {
+		http.Error(w, windowRejection.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error resolving delivery window:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	var order models.Order
+	err = utils.WithTx(func(tx *sql.Tx) error {
+		var quoted *quotedPrice
+		if req.QuoteID != "" {
+			var err error
+			if quoted, err = redeemQuote(tx, req.QuoteID, identity.UserID, req.StoreId, quotedOrder{
+				Pickup:       pickup,
+				DropOff:      dropOff,
+				Delivery:     req.Delivery,
+				DeliveryDate: slot.Date,
+				Package:      req.Package,
+				Zone:         zoneName,
+			}); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if order, err = insertOrder(tx, identity.UserID, req, pickup, dropOff, slot, capacity, quoted); err != nil {
+			return err
+		}
+
+		if quoted != nil {
+			if _, err := tx.Exec("UPDATE price_quotes SET order_id = $1 WHERE id = $2", order.ID, req.QuoteID); err != nil {
+				return err
+			}
+		}
+
+		if req.PromoCode != "" {
+			redemption, err := promos.Redeem(tx, req.StoreId, identity.UserID, order.ID, req.PromoCode, quoted.Total)
+			if err != nil {
+				return err
+			}
+			query := "UPDATE orders SET promo_code_id = $1, discount_total = $2 WHERE id = $3 RETURNING " + orderColumns
+			if err := scanOrder(tx.QueryRow(query, redemption.PromoID, redemption.Discount, order.ID), &order); err != nil {
+				return err
+			}
+		}
+
+		return recordOrderEvent(tx, hub.OrderCreated, models.OrderEventData{Order: order})
+	})
+	if err == errQuoteUnavailable {
+		http.Error(w, "Quote is expired, already used or does not match this order", http.StatusConflict)
+		return
+	}
+	if err == windows.ErrFull {
+		http.Error(w, "The "+slot.Window+" window on "+slot.Date+" is full; pick another window", http.StatusConflict)
+		return
+	}
+	var rejection *promos.Rejection
+	if errors.As(err, &rejection) {
+		http.Error(w, rejection.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error inserting order:", err)
+		http.Error(w, "Could not place order", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+// insertOrder creates a pending order within tx, booking its delivery slot and generating its delivery code.
+// The pickup and drop-off snapshots replace the request's free-text locations. quoted, if set, is the price
+// locked in from a quote.
+func insertOrder(tx *sql.Tx, userID string, req models.PlaceOrderRequest, pickup, dropOff *geocode.Address, slot windows.Slot, capacity *int, quoted *quotedPrice) (models.Order, error) {
+	var order models.Order
+	if err := windows.Reserve(tx, req.StoreId, slot, capacity); err != nil {
+		return order, err
+	}
+
+	pickupJSON, err := addressJSON(pickup)
+	if err != nil {
+		return order, err
+	}
+	dropOffJSON, err := addressJSON(dropOff)
+	if err != nil {
+		return order, err
+	}
+	if pickup != nil {
+		req.Pickup = pickup.String()
+	}
+	if dropOff != nil {
+		req.DropOff = dropOff.String()
+	}
+
+	var packageSize interface{}
+	if req.Package != nil {
+		data, err := json.Marshal(req.Package)
+		if err != nil {
+			return order, err
+		}
+		packageSize = string(data)
+	}
+
+	var quoteID *string
+	var priceTotal *int64
+	var priceCurrency, rateCardID *string
+	if quoted != nil {
+		quoteID, priceTotal, priceCurrency, rateCardID = &req.QuoteID, &quoted.Total, &quoted.Currency, quoted.RateCardID
+	}
+
+	query := `
+        INSERT INTO orders (user_id, store_id, pickup_location, drop_off_location, pickup_address, drop_off_address, delivery_window, delivery_date, package_details, package_size, status, quote_id, price_total, price_currency, rate_card_id, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $16)
+        RETURNING ` + orderColumns
+	err = scanOrder(tx.QueryRow(query, userID, req.StoreId, req.Pickup, req.DropOff, pickupJSON, dropOffJSON, req.Delivery, slot.Date, req.PackageDetails, packageSize, models.OrderPending, quoteID, priceTotal, priceCurrency, rateCardID, time.Now()), &order)
+	if err != nil {
+		return order, err
+	}
+
+	return order, handoff.Generate(tx, order.ID)
+}
+
+// GetOrder godoc
+// @Summary Get an order
+// @Description Get an order's details. Available to the customer, the assigned courier and the store's staff. While the order is on its way it includes an ETA for the pickup and delivery.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Order "The order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id} [get]
+func (oc *OrderController) GetOrder(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	// An ETA is a nice-to-have, so a failure to compute one does not fail the request
+	estimate, err := estimateOrder(order, nil)
+	if err != nil {
+		log.Println("Error estimating order ETA:", err)
+	}
+	order.ETA = estimate
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// ListOrders godoc
+// @Summary List orders
+// @Description List the logged-in account's orders: a customer's own orders, a courier's assigned orders, or all orders of an admin's or owner's store
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Order "Orders, newest first"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders [get]
+func (oc *OrderController) ListOrders(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var filter, value string
+	switch identity.Role {
+	case models.RoleCourier:
+		filter, value = "courier_id", identity.CourierID
+	case models.RoleAdmin, models.RoleOwner:
+		filter, value = "store_id", identity.StoreId
+	default:
+		filter, value = "user_id", identity.UserID
+	}
+
+	rows, err := utils.DB.Query("SELECT "+orderColumns+" FROM orders WHERE "+filter+" = $1 ORDER BY created_at DESC", value)
+	if err != nil {
+		log.Println("Error retrieving orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			log.Println("Error scanning order:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		orders = append(orders, order)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+// AssignOrder godoc
+// @Summary Assign an order to a courier
+// @Description Assign (or reassign) a pending or assigned order to one of the store's couriers. Only the store's admins and owner can assign orders. The order's package must fit in the courier's vehicle together with the orders it already carries.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param assignment body models.AssignOrderRequest true "Courier to assign"
+// @Success 200 {object} models.Order "The updated order"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to assign this order"
+// @Failure 404 {object} map[string]string "Order or courier not found"
+// @Failure 409 {object} map[string]string "Order can no longer be assigned or the courier's vehicle cannot carry it"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/assign [put]
+func (oc *OrderController) AssignOrder(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleAdmin && identity.Role != models.RoleOwner {
+		http.Error(w, "Not allowed to assign this order", http.StatusForbidden)
+		return
+	}
+
+	var req models.AssignOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CourierID == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(req.CourierID); err != nil {
+		http.Error(w, "Courier not found", http.StatusNotFound)
+		return
+	}
+	if !canTransition(order.Status, models.OrderAssigned) {
+		http.Error(w, "Order can no longer be assigned", http.StatusConflict)
+		return
+	}
+
+	// The courier must work for the order's store
+	var courierExists bool
+	checkCourierQuery := "SELECT EXISTS (SELECT 1 FROM couriers WHERE id = $1 AND store_id = $2)"
+	if err := utils.DB.QueryRow(checkCourierQuery, req.CourierID, order.StoreId).Scan(&courierExists); err != nil {
+		log.Println("Error checking courier existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !courierExists {
+		http.Error(w, "Courier not found", http.StatusNotFound)
+		return
+	}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting transaction:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The package has to fit in the courier's vehicle alongside what it already carries
+	profile, load, err := courierLoad(tx, req.CourierID, []string{order.ID})
+	if err != nil {
+		log.Println("Error retrieving courier load:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if profile != nil {
+		if err := profile.Check(load, order.Package); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	updated, err := assignCourier(tx, order, req.CourierID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order was changed by another request", http.StatusConflict)
+			return
+		}
+		log.Println("Error assigning order:", err)
+		http.Error(w, "Could not assign order", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("Error committing order assignment:", err)
+		http.Error(w, "Could not assign order", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// assignCourier assigns the order to the courier within tx, keeping the couriers' order lists in sync, and
+// records the event. It returns sql.ErrNoRows if the order's status changed since it was loaded.
+func assignCourier(tx *sql.Tx, order *models.Order, courierID string) (models.Order, error) {
+	var updated models.Order
+	updateQuery := `
+        UPDATE orders SET courier_id = $1, status = $2, updated_at = $3
+        WHERE id = $4 AND status = $5
+        RETURNING ` + orderColumns
+	if err := scanOrder(tx.QueryRow(updateQuery, courierID, models.OrderAssigned, time.Now(), order.ID, order.Status), &updated); err != nil {
+		return updated, err
+	}
+
+	if order.CourierID != nil {
+		if _, err := tx.Exec("UPDATE couriers SET orders = array_remove(orders, $1) WHERE id = $2", order.ID, *order.CourierID); err != nil {
+			return updated, err
+		}
+	}
+	if _, err := tx.Exec("UPDATE couriers SET orders = array_append(orders, $1) WHERE id = $2", order.ID, courierID); err != nil {
+		return updated, err
+	}
+
+	eventData := models.OrderEventData{Order: updated, PreviousStatus: order.Status, PreviousCourierID: order.CourierID}
+	return updated, recordOrderEvent(tx, hub.OrderAssigned, eventData)
+}
+
+// UpdateOrderStatus godoc
+// @Summary Update an order's status
+// @Description Move an order to picked_up, in_transit, delivered, returning or returned. Available to the assigned courier and the store's admins and owner; couriers mark orders delivered by submitting proof of delivery, and send them back by recording failed attempts. A rescheduled order goes back to in_transit when the courier sets out again, and a returning order becomes returned once it is back at the store. Orders with a delivery code can only be marked delivered once the code was verified or overridden.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param status body models.UpdateOrderStatusRequest true "New status"
+// @Success 200 {object} models.Order "The updated order"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to update this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Invalid status transition or delivery code not verified"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/status [put]
+func (oc *OrderController) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role == models.RoleUser {
+		http.Error(w, "Not allowed to update this order", http.StatusForbidden)
+		return
+	}
+
+	var req models.UpdateOrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Assignment, cancellation and failed attempts have their own endpoints
+	switch req.Status {
+	case models.OrderPickedUp, models.OrderInTransit, models.OrderDelivered, models.OrderReturning, models.OrderReturned:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if !canTransition(order.Status, req.Status) {
+		http.Error(w, "Invalid status transition from "+order.Status+" to "+req.Status, http.StatusConflict)
+		return
+	}
+
+	if req.Status == models.OrderDelivered && identity.Role == models.RoleCourier {
+		http.Error(w, "Couriers complete deliveries by submitting proof of delivery", http.StatusConflict)
+		return
+	}
+	if req.Status == models.OrderReturning && identity.Role == models.RoleCourier {
+		http.Error(w, "Couriers return orders by recording failed delivery attempts", http.StatusConflict)
+		return
+	}
+
+	var updated *models.Order
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		var err error
+		if req.Status == models.OrderDelivered {
+			updated, err = completeDelivery(tx, order)
+		} else {
+			updated, err = transitionOrder(tx, order, req.Status)
+		}
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order was changed by another request", http.StatusConflict)
+			return
+		}
+		if err == handoff.ErrNotCleared {
+			http.Error(w, "Delivery code has not been verified; override it before marking the order delivered", http.StatusConflict)
+			return
+		}
+		log.Println("Error updating order status:", err)
+		http.Error(w, "Could not update order", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// CancelOrder godoc
+// @Summary Cancel an order
+// @Description Cancel an order before it is picked up. Customers can cancel their own pending orders; store admins and owners can cancel pending or assigned orders. The order's payment is released with it: an authorized card is voided, cash not yet collected is dropped and anything already captured is refunded. If the payment provider cannot be reached, payment_status stays voiding or refunding until a retry succeeds.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Order "The cancelled order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to cancel this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Order can no longer be cancelled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id} [delete]
+func (oc *OrderController) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role == models.RoleCourier {
+		http.Error(w, "Not allowed to cancel this order", http.StatusForbidden)
+		return
+	}
+	if !canTransition(order.Status, models.OrderCancelled) || (identity.Role == models.RoleUser && order.Status != models.OrderPending) {
+		http.Error(w, "Order can no longer be cancelled", http.StatusConflict)
+		return
+	}
+
+	var updated *models.Order
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		var err error
+		updated, err = transitionOrder(tx, order, models.OrderCancelled)
+		if err != nil {
+			return err
+		}
+		if order.DeliveryDate != nil {
+			if err := windows.Release(tx, order.StoreId, *order.DeliveryDate, order.DeliveryWindow); err != nil {
+				return err
+			}
+		}
+		if err := promos.Release(tx, order.ID); err != nil {
+			return err
+		}
+		return payments.Cancel(tx, order.ID, identity.UserID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order was changed by another request", http.StatusConflict)
+			return
+		}
+		log.Println("Error cancelling order:", err)
+		http.Error(w, "Could not cancel order", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	// Money only moves once the cancellation is committed; if the provider call fails it is retried in the background
+	if err := payments.SettleCancellation(order.ID); err != nil {
+		log.Println("Error releasing payment of cancelled order:", err)
+	} else if reloaded, err := loadOrder(order.ID); err != nil {
+		log.Println("Error reloading cancelled order:", err)
+	} else {
+		updated = reloaded
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// transitionOrder moves an order to a new status within tx if it is still in the status it was loaded with,
+// and records the change in the outbox. It returns sql.ErrNoRows if the order changed in the meantime.
+func transitionOrder(tx *sql.Tx, order *models.Order, status string) (*models.Order, error) {
+	var updated models.Order
+	query := `
+        UPDATE orders SET status = $1, updated_at = $2, picked_up_at = CASE WHEN $1 = 'picked_up' THEN $2 ELSE picked_up_at END,
+            tracking_since = CASE WHEN $1 IN ('picked_up', 'in_transit') AND status NOT IN ('picked_up', 'in_transit') THEN $2 ELSE tracking_since END
+        WHERE id = $3 AND status = $4
+        RETURNING ` + orderColumns
+	if err := scanOrder(tx.QueryRow(query, status, time.Now(), order.ID, order.Status), &updated); err != nil {
+		return nil, err
+	}
+
+	eventData := models.OrderEventData{Order: updated, PreviousStatus: order.Status}
+	if err := recordOrderEvent(tx, hub.OrderStatusChanged, eventData); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// completeDelivery marks an order delivered within tx, credits the courier's earnings and issues the invoice
+func completeDelivery(tx *sql.Tx, order *models.Order) (*models.Order, error) {
+	if err := handoff.RequireCleared(tx, order.ID); err != nil {
+		return nil, err
+	}
+	updated, err := transitionOrder(tx, order, models.OrderDelivered)
+	if err != nil {
+		return nil, err
+	}
+	if err := earnings.RecordDelivery(tx, updated); err != nil {
+		return nil, err
+	}
+	if err := recordDeliveryLeg(tx, updated); err != nil {
+		return nil, err
+	}
+	if err := invoices.Issue(tx, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// loadAuthorizedOrder loads the order named in the URL and checks the caller may view it,
+// writing an error response if not
+func loadAuthorizedOrder(w http.ResponseWriter, r *http.Request) (*models.Identity, *models.Order, bool) {
+	identity, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return nil, nil, false
+	}
+
+	order, err := loadOrder(mux.Vars(r)["id"])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return nil, nil, false
+		}
+		log.Println("Error retrieving order:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if !canViewOrder(identity, order) {
+		http.Error(w, "Not allowed to access this order", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return identity, order, true
+}
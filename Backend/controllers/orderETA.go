@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"PTS/eta"
+	"PTS/geo"
+	"PTS/models"
+	"PTS/utils"
+	"PTS/windows"
+	"database/sql"
+	"time"
+)
+
+// CourierPositionMaxAge is how old a courier's last ping can be and still be used as its position
+var CourierPositionMaxAge = utils.GetEnvDuration("ETA_POSITION_MAX_AGE", 10*time.Minute)
+
+// estimateOrder computes the ETA of an order that is waiting for or on its way with a courier. position is
+// the courier's latest ping if the caller already has it; otherwise it is looked up. It returns nil for
+// orders that are not on their way or were placed without geocoded addresses.
+func estimateOrder(order *models.Order, position *geo.Point) (*eta.Estimate, error) {
+	var pickedUp bool
+	switch order.Status {
+	case models.OrderPending, models.OrderAssigned:
+	case models.OrderPickedUp, models.OrderInTransit:
+		pickedUp = true
+	default:
+		return nil, nil
+	}
+	if order.PickupAddress == nil || order.PickupAddress.Location == nil || order.DropOffAddress == nil || order.DropOffAddress.Location == nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	in := eta.Input{
+		StoreID:  order.StoreId,
+		Pickup:   *order.PickupAddress.Location,
+		DropOff:  *order.DropOffAddress.Location,
+		PickedUp: pickedUp,
+		Now:      now,
+	}
+
+	var err error
+	if order.CourierID != nil {
+		if err = utils.DB.QueryRow("SELECT vehicle_type FROM couriers WHERE id = $1", *order.CourierID).Scan(&in.Vehicle); err != nil {
+			return nil, err
+		}
+		if position == nil {
+			if position, err = courierPosition(*order.CourierID); err != nil {
+				return nil, err
+			}
+		}
+		in.Courier = position
+	}
+
+	slot, err := deliverySlot(order)
+	if err != nil {
+		return nil, err
+	}
+	if slot != nil {
+		in.NotBefore = &slot.Start
+	}
+
+	estimate, err := eta.Compute(in)
+	if err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+// courierPosition returns the courier's last reported position, or nil if it has not reported one recently
+func courierPosition(courierID string) (*geo.Point, error) {
+	var position geo.Point
+	var recordedAt time.Time
+	query := "SELECT lat, lng, recorded_at FROM courier_locations WHERE courier_id = $1 ORDER BY recorded_at DESC LIMIT 1"
+	err := utils.DB.QueryRow(query, courierID).Scan(&position.Lat, &position.Lng, &recordedAt)
+	if err == sql.ErrNoRows || (err == nil && time.Since(recordedAt) > CourierPositionMaxAge) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// deliverySlot returns the delivery window the order is booked into, or nil if it has no date or the store
+// no longer offers the window
+func deliverySlot(order *models.Order) (*windows.Slot, error) {
+	if order.DeliveryDate == nil {
+		return nil, nil
+	}
+	schedule, err := windows.ForStore(order.StoreId)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.ParseInLocation(time.DateOnly, *order.DeliveryDate, windows.Location)
+	if err != nil {
+		return nil, err
+	}
+	window, ok := schedule.Find(order.DeliveryWindow, day)
+	if !ok {
+		return nil, nil
+	}
+	slot := window.On(day)
+	return &slot, nil
+}
+
+// recordDeliveryLeg adds how long the courier took from pickup to drop-off to the store's ETA history.
+// Orders that needed more than one attempt are left out, as their time includes the wait for a new window.
+func recordDeliveryLeg(tx *sql.Tx, order *models.Order) error {
+	if order.CourierID == nil || order.Attempts > 0 ||
+		order.PickupAddress == nil || order.PickupAddress.Location == nil || order.DropOffAddress == nil || order.DropOffAddress.Location == nil {
+		return nil
+	}
+
+	var pickedUpAt sql.NullTime
+	var vehicle string
+	query := "SELECT o.picked_up_at, c.vehicle_type FROM orders o JOIN couriers c ON c.id = o.courier_id WHERE o.id = $1"
+	if err := tx.QueryRow(query, order.ID).Scan(&pickedUpAt, &vehicle); err != nil {
+		return err
+	}
+	if !pickedUpAt.Valid {
+		return nil
+	}
+
+	distance, err := eta.DefaultRouter.DistanceKm(*order.PickupAddress.Location, *order.DropOffAddress.Location)
+	if err != nil {
+		return nil
+	}
+	return eta.Record(tx, order.StoreId, order.ID, vehicle, distance, order.UpdatedAt.Sub(pickedUpAt.Time))
+}
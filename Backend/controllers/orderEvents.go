@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"PTS/geo"
+	"PTS/hub"
+	"PTS/models"
+	"PTS/outbox"
+	"PTS/utils"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// recordOrderEvent writes an order event to the outbox in the transaction that changed the order.
+// Call outbox.Notify once the transaction commits so the relay picks it up right away.
+func recordOrderEvent(tx outbox.Execer, eventType string, data models.OrderEventData) error {
+	return outbox.Write(tx, eventType, data.Order.ID, data.Order.StoreId, data)
+}
+
+// publishCourierLocation pushes a courier's latest position to subscribers of every order the courier is carrying,
+// and each assigned order's ETA from that position. Both are only useful live, so they skip the outbox and are
+// broadcast to every API instance directly.
+func publishCourierLocation(courierID string, ping models.LocationPing) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE courier_id = $1 AND status = ANY($2)"
+	activeStatuses := []string{models.OrderAssigned, models.OrderPickedUp, models.OrderInTransit, models.OrderRescheduled, models.OrderReturning}
+	rows, err := utils.DB.Query(query, courierID, pq.Array(activeStatuses))
+	if err != nil {
+		log.Println("Error retrieving courier orders for location update:", err)
+		return
+	}
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			rows.Close()
+			log.Println("Error scanning courier order:", err)
+			return
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+
+	position := geo.Point{Lat: ping.Latitude, Lng: ping.Longitude}
+	for i := range orders {
+		order := &orders[i]
+		// Like the tracking trail, the position is only shared while the courier is carrying the order
+		if order.Status == models.OrderPickedUp || order.Status == models.OrderInTransit {
+			if err := outbox.Broadcast(hub.Event{Type: hub.OrderLocation, OrderID: order.ID, StoreId: order.StoreId, Data: ping}); err != nil {
+				log.Println("Error publishing courier location:", err)
+			}
+		}
+
+		estimate, err := estimateOrder(order, &position)
+		if err != nil {
+			log.Println("Error estimating order ETA:", err)
+			continue
+		}
+		if estimate != nil {
+			if err := outbox.Broadcast(hub.Event{Type: hub.OrderETA, OrderID: order.ID, StoreId: order.StoreId, Data: estimate}); err != nil {
+				log.Println("Error publishing order ETA:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/payments"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// PaymentController handles paying for orders
+type PaymentController struct{}
+
+// CreatePayment godoc
+// @Summary Pay for an order
+// @Description Choose how to pay for a priced order. "card" authorizes the card token in source straight away; "cod" is collected by the courier at delivery. Repeating the call returns the existing payment; after a declined card a new payment can be started.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param payment body models.CreatePaymentRequest true "Payment method and card token"
+// @Success 201 {object} models.Payment "The payment"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 402 {object} models.Payment "The card was declined"
+// @Failure 403 {object} map[string]string "Only the customer can pay for the order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Order cannot be paid for"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/payment [post]
+func (pc *PaymentController) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleUser {
+		http.Error(w, "Only the customer can pay for the order", http.StatusForbidden)
+		return
+	}
+
+	var req models.CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !payments.IsValidMethod(req.Method) {
+		http.Error(w, "Unsupported payment method", http.StatusBadRequest)
+		return
+	}
+	if req.Method == payments.MethodCard && req.Source == "" {
+		http.Error(w, "Missing card source", http.StatusBadRequest)
+		return
+	}
+	if order.PriceTotal == nil || order.PriceCurrency == nil {
+		http.Error(w, "Order has no quoted price to pay", http.StatusConflict)
+		return
+	}
+	if order.Status == models.OrderCancelled {
+		http.Error(w, "Order is cancelled", http.StatusConflict)
+		return
+	}
+
+	payment, err := payments.Create(order, req.Method, req.Source, identity.UserID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if payment.Status == payments.StatusFailed {
+		w.WriteHeader(http.StatusPaymentRequired)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(payment)
+}
+
+// GetPayment godoc
+// @Summary Get an order's payment
+// @Description Get the latest payment for an order. Available to the customer, the assigned courier and the store's staff.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Payment "The payment"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order"
+// @Failure 404 {object} map[string]string "Order or payment not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/payment [get]
+func (pc *PaymentController) GetPayment(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := payments.Latest(order.ID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// CapturePayment godoc
+// @Summary Capture an order's payment
+// @Description Take the money for an order. For cash on delivery the assigned courier confirms the amount they collected once the order is delivered; card payments are captured by the store's staff. Requires an Idempotency-Key header; retrying with the same key does not capture twice.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param Idempotency-Key header string true "Unique key for this capture"
+// @Param capture body models.PaymentAmountRequest false "Amount collected or to capture (0 for the full amount)"
+// @Success 200 {object} models.Payment "The captured payment"
+// @Failure 400 {object} map[string]string "Missing idempotency key or invalid amount"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 402 {object} map[string]string "The provider declined the capture"
+// @Failure 403 {object} map[string]string "Not allowed to capture this payment"
+// @Failure 404 {object} map[string]string "Order or payment not found"
+// @Failure 409 {object} map[string]string "Payment cannot be captured, or cash is confirmed before delivery"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/payment/capture [post]
+func (pc *PaymentController) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	key, req, ok := decodePaymentOperation(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := payments.Latest(order.ID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	switch identity.Role {
+	case models.RoleAdmin, models.RoleOwner:
+	case models.RoleCourier:
+		if payment.Method != payments.MethodCashOnDelivery {
+			http.Error(w, "Couriers can only confirm cash collection", http.StatusForbidden)
+			return
+		}
+	default:
+		http.Error(w, "Not allowed to capture this payment", http.StatusForbidden)
+		return
+	}
+	// Cash changes hands at the door, so it can only be confirmed once the order has been delivered
+	if payment.Method == payments.MethodCashOnDelivery && order.Status != models.OrderDelivered {
+		http.Error(w, "Cash can only be collected once the order is delivered", http.StatusConflict)
+		return
+	}
+
+	payment, err = payments.Capture(order.ID, req.Amount, key, identity.UserID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// RefundPayment godoc
+// @Summary Refund an order's payment
+// @Description Refund part or all of a captured payment. Only the store's admins and owner can refund. Requires an Idempotency-Key header; retrying with the same key does not refund twice.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param Idempotency-Key header string true "Unique key for this refund"
+// @Param refund body models.PaymentAmountRequest false "Amount to refund (0 for everything not yet refunded)"
+// @Success 200 {object} models.Payment "The refunded payment"
+// @Failure 400 {object} map[string]string "Missing idempotency key or invalid amount"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 402 {object} map[string]string "The provider declined the refund"
+// @Failure 403 {object} map[string]string "Not allowed to refund this payment"
+// @Failure 404 {object} map[string]string "Order or payment not found"
+// @Failure 409 {object} map[string]string "Payment cannot be refunded"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/payment/refund [post]
+func (pc *PaymentController) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleAdmin && identity.Role != models.RoleOwner {
+		http.Error(w, "Not allowed to refund this payment", http.StatusForbidden)
+		return
+	}
+
+	key, req, ok := decodePaymentOperation(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := payments.Refund(order.ID, req.Amount, key, identity.UserID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// decodePaymentOperation reads the idempotency key and optional amount of a capture or refund,
+// writing an error response if they are missing or invalid
+func decodePaymentOperation(w http.ResponseWriter, r *http.Request) (string, models.PaymentAmountRequest, bool) {
+	var req models.PaymentAmountRequest
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || len(key) > 255 {
+		http.Error(w, "Missing Idempotency-Key header", http.StatusBadRequest)
+		return "", req, false
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return "", req, false
+		}
+	}
+	if req.Amount < 0 {
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return "", req, false
+	}
+	return key, req, true
+}
+
+// writePaymentError maps an error from the payments package to a response
+func writePaymentError(w http.ResponseWriter, err error) {
+	var stateErr *payments.StateError
+	var declined *payments.DeclinedError
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Payment not found", http.StatusNotFound)
+	case err == payments.ErrInvalidAmount:
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+	case errors.As(err, &stateErr):
+		http.Error(w, stateErr.Error(), http.StatusConflict)
+	case errors.As(err, &declined):
+		http.Error(w, declined.Error(), http.StatusPaymentRequired)
+	default:
+		log.Println("Error processing payment:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"PTS/geo"
+	"PTS/geocode"
+	"PTS/models"
+	"PTS/pricing"
+	"PTS/utils"
+	"PTS/vehicles"
+	"PTS/windows"
+	"PTS/zones"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultQuoteTTL = 15 * time.Minute
+
+// QuoteTTL is how long a quoted price can be locked into an order
+var QuoteTTL = utils.GetEnvDuration("PRICE_QUOTE_TTL", defaultQuoteTTL)
+
+// rateCards looks up the rate card each store prices with
+var rateCards pricing.RateCardProvider = pricing.StoreProvider{}
+
+// errQuoteUnavailable is returned when a quote is unknown, expired, already used or belongs to someone else
+var errQuoteUnavailable = errors.New("quote is expired, already used or not yours")
+
+// PricingController quotes delivery prices
+type PricingController struct{}
+
+// CreateQuote godoc
+// @Summary Quote a delivery price
+// @Description Price a delivery with the store's rate card from the distance between pickup and drop-off, the package size and weight, the delivery window and when it starts, the drop-off's service zone and the vehicle needed. The window must be open in the store's schedule on the delivery date, which defaults to its next upcoming slot. The returned quote ID can be passed when placing the order to lock in the price until the quote expires.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param quote body models.QuoteRequest true "What to price"
+// @Success 201 {object} models.Quote "The quoted price"
+// @Failure 400 {object} map[string]string "Missing required fields, invalid input, a window the store does not offer on that date or the drop-off is outside the store's service zones"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers can request quotes"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /quotes [post]
+func (pc *PricingController) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	var req models.QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.StoreId == "" || req.Delivery == "" || req.SizeClass == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if !req.PickupPoint.Valid() || !req.DropOffPoint.Valid() {
+		http.Error(w, "Invalid pickup or drop-off coordinates", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(req.StoreId); err != nil {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	var storeExists bool
+	err := utils.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM stores WHERE id = $1)", req.StoreId).Scan(&storeExists)
+	if err != nil {
+		log.Println("Error checking store existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !storeExists {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	// The drop-off must be in one of the store's zones, whose name can add a surcharge
+	var zoneName string
+	zone, err := zones.Locate(req.StoreId, req.DropOffPoint)
+	var outside *zones.OutsideError
+	if errors.As(err, &outside) {
+		http.Error(w, outside.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error checking service area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if zone != nil {
+		zoneName = zone.Name
+	}
+
+	// Price the slot being delivered in, so peak pricing follows the delivery time rather than the time of asking
+	slot, _, err := windows.Resolve(req.StoreId, req.Delivery, req.DeliveryDate, time.Now())
+	var windowRejection *windows.Rejection
+	if errors.As(err, &windowRejection) {
+		http.Error(w, windowRejection.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error resolving delivery window:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	req.DeliveryDate = slot.Date
+
+	card, err := rateCards.CurrentRateCard(req.StoreId)
+	if err != nil {
+		log.Println("Error loading rate card:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	price, err := card.Price(pricing.Input{
+		DistanceKm:     geo.HaversineKm(req.PickupPoint, req.DropOffPoint),
+		SizeClass:      req.SizeClass,
+		WeightKg:       req.WeightKg,
+		DeliveryWindow: req.Delivery,
+		Zone:           zoneName,
+		VehicleType:    req.VehicleType,
+		At:             slot.Start,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		log.Println("Error encoding quote request:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	priceJSON, err := json.Marshal(price)
+	if err != nil {
+		log.Println("Error encoding quote price:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	var rateCardID *string
+	if price.RateCardID != "" {
+		rateCardID = &price.RateCardID
+	}
+
+	now := time.Now()
+	quote := models.Quote{StoreId: req.StoreId, DeliveryDate: slot.Date, Price: price, ExpiresAt: now.Add(QuoteTTL), CreatedAt: now}
+	query := `
+        INSERT INTO price_quotes (store_id, user_id, request, price, total, currency, rate_card_id, zone, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id
+    `
+	err = utils.DB.QueryRow(query, req.StoreId, identity.UserID, requestJSON, priceJSON, price.Total, price.Currency, rateCardID, zoneName, quote.ExpiresAt, now).Scan(&quote.ID)
+	if err != nil {
+		log.Println("Error saving quote:", err)
+		http.Error(w, "Could not create quote", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
+
+// quotedPrice is the part of a redeemed quote that is copied onto the order
+type quotedPrice struct {
+	Total      int64
+	Currency   string
+	RateCardID *string
+}
+
+// quoteLocationToleranceKm is how far the order's geocoded pickup and drop-off may be from the points that were quoted
+const quoteLocationToleranceKm = 0.2
+
+// quotedOrder is what the order being placed looks like, to check it is the delivery that was quoted
+type quotedOrder struct {
+	Pickup   *geocode.Address
+	DropOff  *geocode.Address
+	Delivery string
+	// DeliveryDate is the date of the delivery slot the order books
+	DeliveryDate string
+	Package      *vehicles.Package
+	// Zone is the name of the service zone the drop-off is in, empty if the store has no zones
+	Zone string
+}
+
+// redeemQuote locks a customer's unexpired, unused quote for the store into the order being placed.
+// It returns errQuoteUnavailable if the quote cannot be used or was for a different delivery.
+func redeemQuote(tx *sql.Tx, quoteID, userID, storeID string, order quotedOrder) (*quotedPrice, error) {
+	if _, err := uuid.Parse(quoteID); err != nil {
+		return nil, errQuoteUnavailable
+	}
+
+	var quoted quotedPrice
+	var requestJSON, priceJSON []byte
+	var zone sql.NullString
+	query := `
+        SELECT total, currency, rate_card_id, request, price, zone FROM price_quotes
+        WHERE id = $1 AND user_id = $2 AND store_id = $3 AND order_id IS NULL AND expires_at > $4
+        FOR UPDATE
+    `
+	err := tx.QueryRow(query, quoteID, userID, storeID, time.Now()).Scan(&quoted.Total, &quoted.Currency, &quoted.RateCardID, &requestJSON, &priceJSON, &zone)
+	if err == sql.ErrNoRows {
+		return nil, errQuoteUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var request models.QuoteRequest
+	if err := json.Unmarshal(requestJSON, &request); err != nil {
+		return nil, err
+	}
+	var price pricing.Price
+	if err := json.Unmarshal(priceJSON, &price); err != nil {
+		return nil, err
+	}
+	if !quoteCovers(request, price, zone.String, order) {
+		return nil, errQuoteUnavailable
+	}
+	return &quoted, nil
+}
+
+// quoteCovers reports whether a quote was for the order's delivery: the same pickup and drop-off, window and
+// zone, and a package no bigger or heavier than the one priced
+func quoteCovers(request models.QuoteRequest, price pricing.Price, zone string, order quotedOrder) bool {
+	if order.Pickup == nil || order.Pickup.Location == nil || order.DropOff == nil || order.DropOff.Location == nil || order.Package == nil {
+		return false
+	}
+	if geo.HaversineKm(request.PickupPoint, *order.Pickup.Location) > quoteLocationToleranceKm ||
+		geo.HaversineKm(request.DropOffPoint, *order.DropOff.Location) > quoteLocationToleranceKm {
+		return false
+	}
+	if request.Delivery != order.Delivery || request.DeliveryDate != order.DeliveryDate || zone != order.Zone {
+		return false
+	}
+
+	sizeClass := order.Package.SizeClass()
+	if !pricing.SizeFits(sizeClass, request.SizeClass) || order.Package.WeightKg > request.WeightKg {
+		return false
+	}
+	return pricing.VehicleFits(pricing.RequiredVehicle(sizeClass, order.Package.WeightKg), price.VehicleType)
+}
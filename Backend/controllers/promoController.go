@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/promos"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const promoCodeColumns = "id, store_id, code, kind, value, max_discount, min_order_value, max_redemptions, max_per_user, redemption_count, starts_at, ends_at, active, created_at, updated_at"
+
+// PromoController lets store owners manage promo codes
+type PromoController struct{}
+
+// CreatePromoCode godoc
+// @Summary Create a promo code
+// @Description Create a percentage or fixed-amount promo code for the owner's store. Codes are case-insensitive.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param promo body models.PromoCodeRequest true "Promo code rules"
+// @Success 201 {object} models.PromoCode "The created promo code"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage promo codes"
+// @Failure 409 {object} map[string]string "The store already has this code"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /promo-codes [post]
+func (pc *PromoController) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if message := validatePromoRequest(&req); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	var promo models.PromoCode
+	query := `
+        INSERT INTO promo_codes (store_id, code, kind, value, max_discount, min_order_value, max_redemptions, max_per_user, starts_at, ends_at, active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+        RETURNING ` + promoCodeColumns
+	row := utils.DB.QueryRow(query, identity.StoreId, req.Code, req.Kind, req.Value, req.MaxDiscount, req.MinOrderValue,
+		req.MaxRedemptions, req.MaxPerUser, req.StartsAt, req.EndsAt, active, time.Now())
+	if err := scanPromoCode(row, &promo); err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, "The store already has this code", http.StatusConflict)
+			return
+		}
+		log.Println("Error inserting promo code:", err)
+		http.Error(w, "Could not create promo code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(promo)
+}
+
+// ListPromoCodes godoc
+// @Summary List promo codes
+// @Description List the owner's store promo codes with how many times each has been redeemed
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.PromoCode "Promo codes"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage promo codes"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /promo-codes [get]
+func (pc *PromoController) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rows, err := utils.DB.Query("SELECT "+promoCodeColumns+" FROM promo_codes WHERE store_id = $1 ORDER BY created_at DESC", identity.StoreId)
+	if err != nil {
+		log.Println("Error retrieving promo codes:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	codes := []models.PromoCode{}
+	for rows.Next() {
+		var promo models.PromoCode
+		if err := scanPromoCode(rows, &promo); err != nil {
+			log.Println("Error scanning promo code:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		codes = append(codes, promo)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+// UpdatePromoCode godoc
+// @Summary Update a promo code
+// @Description Replace a promo code's rules. Redemptions already made are kept and still count towards the caps.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Promo code ID"
+// @Param promo body models.PromoCodeRequest true "Promo code rules"
+// @Success 200 {object} models.PromoCode "The updated promo code"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage promo codes"
+// @Failure 404 {object} map[string]string "Promo code not found"
+// @Failure 409 {object} map[string]string "The store already has this code"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /promo-codes/{id} [put]
+func (pc *PromoController) UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	promoID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(promoID); err != nil {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if message := validatePromoRequest(&req); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	var promo models.PromoCode
+	query := `
+        UPDATE promo_codes
+        SET code = $1, kind = $2, value = $3, max_discount = $4, min_order_value = $5, max_redemptions = $6, max_per_user = $7,
+            starts_at = $8, ends_at = $9, active = COALESCE($10, active), updated_at = $11
+        WHERE id = $12 AND store_id = $13
+        RETURNING ` + promoCodeColumns
+	row := utils.DB.QueryRow(query, req.Code, req.Kind, req.Value, req.MaxDiscount, req.MinOrderValue, req.MaxRedemptions, req.MaxPerUser,
+		req.StartsAt, req.EndsAt, req.Active, time.Now(), promoID, identity.StoreId)
+	if err := scanPromoCode(row, &promo); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Promo code not found", http.StatusNotFound)
+			return
+		}
+		if isUniqueViolation(err) {
+			http.Error(w, "The store already has this code", http.StatusConflict)
+			return
+		}
+		log.Println("Error updating promo code:", err)
+		http.Error(w, "Could not update promo code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promo)
+}
+
+// DeactivatePromoCode godoc
+// @Summary Deactivate a promo code
+// @Description Stop a promo code from being applied to new orders. It is kept for the orders that used it.
+// @Security BearerAuth
+// @Param id path string true "Promo code ID"
+// @Success 204 "Promo code deactivated"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage promo codes"
+// @Failure 404 {object} map[string]string "Promo code not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /promo-codes/{id} [delete]
+func (pc *PromoController) DeactivatePromoCode(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	promoID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(promoID); err != nil {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := utils.DB.Exec("UPDATE promo_codes SET active = FALSE, updated_at = $1 WHERE id = $2 AND store_id = $3", time.Now(), promoID, identity.StoreId)
+	if err != nil {
+		log.Println("Error deactivating promo code:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validatePromoRequest normalises the code and returns a message describing the first invalid field, or ""
+func validatePromoRequest(req *models.PromoCodeRequest) string {
+	req.Code = promos.NormalizeCode(req.Code)
+	if req.Code == "" || req.Kind == "" {
+		return "Missing required fields"
+	}
+	switch req.Kind {
+	case promos.KindPercent:
+		if req.Value < 1 || req.Value > 100 {
+			return "Percentage must be between 1 and 100"
+		}
+	case promos.KindFixed:
+		if req.Value < 1 {
+			return "Fixed discount must be positive"
+		}
+	default:
+		return "Kind must be percent or fixed"
+	}
+	if req.MaxDiscount != nil && *req.MaxDiscount < 1 {
+		return "Maximum discount must be positive"
+	}
+	if req.MinOrderValue < 0 {
+		return "Minimum order value must not be negative"
+	}
+	if (req.MaxRedemptions != nil && *req.MaxRedemptions < 1) || (req.MaxPerUser != nil && *req.MaxPerUser < 1) {
+		return "Usage caps must be positive"
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate key
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// scanPromoCode reads a row selected with promoCodeColumns into a promo code
+func scanPromoCode(row rowScanner, promo *models.PromoCode) error {
+	return row.Scan(
+		&promo.ID, &promo.StoreId, &promo.Code, &promo.Kind, &promo.Value, &promo.MaxDiscount, &promo.MinOrderValue,
+		&promo.MaxRedemptions, &promo.MaxPerUser, &promo.RedemptionCount, &promo.StartsAt, &promo.EndsAt, &promo.Active,
+		&promo.CreatedAt, &promo.UpdatedAt,
+	)
+}
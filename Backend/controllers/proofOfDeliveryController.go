@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"PTS/blobstore"
+	"PTS/geo"
+	"PTS/handoff"
+	"PTS/models"
+	"PTS/outbox"
+	"PTS/utils"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const defaultProofLinkTTL = 15 * time.Minute
+
+// ProofLinkTTL is how long the signed image links returned with a proof of delivery stay valid
+var ProofLinkTTL = utils.GetEnvDuration("BLOB_URL_TTL", defaultProofLinkTTL)
+
+// maxProofUploadSize bounds the whole multipart request: two images plus the form fields
+var maxProofUploadSize = 2*blobstore.ImagePolicy.MaxSize + 1<<20
+
+// ProofOfDeliveryController handles the evidence couriers submit when completing a delivery
+type ProofOfDeliveryController struct{}
+
+// SubmitProofOfDelivery godoc
+// @Summary Complete a delivery with proof
+// @Description Mark a picked-up order delivered, attaching who received it, where and when, and a signature image and/or photo (PNG or JPEG, up to 5 MB each). The customer's delivery code must be submitted as pin unless the store overrode it. Only the assigned courier can submit proof.
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param recipient_name formData string true "Name of the person who received the package"
+// @Param lat formData number true "Latitude where the package was handed over"
+// @Param lng formData number true "Longitude where the package was handed over"
+// @Param captured_at formData string false "When the proof was captured (RFC 3339, default now)"
+// @Param pin formData string false "Delivery code given by the customer, required unless overridden by the store"
+// @Param signature formData file false "Recipient's signature"
+// @Param photo formData file false "Photo of the delivered package"
+// @Success 201 {object} models.ProofOfDelivery "The recorded proof of delivery"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only the assigned courier can complete the delivery"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 409 {object} map[string]string "Order cannot be delivered"
+// @Failure 422 {object} map[string]string "Wrong delivery code"
+// @Failure 423 {object} map[string]string "Delivery code locked after too many wrong attempts"
+// @Failure 413 {object} map[string]string "Upload too large"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/proof-of-delivery [post]
+func (pc *ProofOfDeliveryController) SubmitProofOfDelivery(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+	if identity.Role != models.RoleCourier {
+		http.Error(w, "Only the assigned courier can complete the delivery", http.StatusForbidden)
+		return
+	}
+	if !canTransition(order.Status, models.OrderDelivered) {
+		http.Error(w, "Invalid status transition from "+order.Status+" to "+models.OrderDelivered, http.StatusConflict)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxProofUploadSize)
+	if err := r.ParseMultipartForm(maxProofUploadSize); err != nil {
+		http.Error(w, "Upload too large or not multipart", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	proof := models.ProofOfDelivery{OrderID: order.ID, CourierID: identity.CourierID, RecipientName: r.FormValue("recipient_name")}
+	if proof.RecipientName == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	var point geo.Point
+	var latErr, lngErr error
+	point.Lat, latErr = strconv.ParseFloat(r.FormValue("lat"), 64)
+	point.Lng, lngErr = strconv.ParseFloat(r.FormValue("lng"), 64)
+	if latErr != nil || lngErr != nil || !point.Valid() {
+		http.Error(w, "Invalid coordinates", http.StatusBadRequest)
+		return
+	}
+	proof.Lat, proof.Lng = point.Lat, point.Lng
+
+	now := time.Now()
+	proof.CapturedAt = now
+	if value := r.FormValue("captured_at"); value != "" {
+		capturedAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil || capturedAt.After(now.Add(maxPingClockSkew)) {
+			http.Error(w, "Invalid captured_at time", http.StatusBadRequest)
+			return
+		}
+		proof.CapturedAt = capturedAt
+	}
+
+	signature, signatureType, err := readProofImage(r, "signature")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	photo, photoType, err := readProofImage(r, "photo")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if signature == nil && photo == nil {
+		http.Error(w, "A signature or photo is required", http.StatusBadRequest)
+		return
+	}
+
+	// Check the customer's delivery code before storing anything
+	proof.PinVerified, err = handoff.Verify(order.ID, r.FormValue("pin"))
+	if err != nil {
+		var mismatch *handoff.MismatchError
+		switch {
+		case err == handoff.ErrCodeRequired:
+			http.Error(w, "Delivery code is required", http.StatusBadRequest)
+		case errors.As(err, &mismatch):
+			http.Error(w, mismatch.Error(), http.StatusUnprocessableEntity)
+		case err == handoff.ErrLocked:
+			http.Error(w, "Too many wrong delivery codes; ask the store to override the code", http.StatusLocked)
+		default:
+			log.Println("Error verifying delivery code:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Upload the files first; they are removed again if the delivery cannot be recorded. Keys are unique per
+	// submission, so a request that loses the race to deliver the order cannot remove the winner's files.
+	submission := uuid.NewString()
+	var signatureKey, photoKey *string
+	var stored []string
+	for _, upload := range []struct {
+		name        string
+		content     []byte
+		contentType string
+		key         **string
+	}{{"signature", signature, signatureType, &signatureKey}, {"photo", photo, photoType, &photoKey}} {
+		if upload.content == nil {
+			continue
+		}
+		key := "pod/" + order.ID + "/" + submission + "-" + upload.name
+		if err := blobstore.Default.Put(key, upload.contentType, bytes.NewReader(upload.content)); err != nil {
+			log.Println("Error storing proof of delivery file:", err)
+			removeBlobs(stored)
+			http.Error(w, "Could not store proof of delivery", http.StatusInternalServerError)
+			return
+		}
+		stored = append(stored, key)
+		*upload.key = &key
+	}
+
+	err = utils.WithTx(func(tx *sql.Tx) error {
+		query := `
+            INSERT INTO proofs_of_delivery (order_id, courier_id, recipient_name, lat, lng, captured_at, pin_verified, signature_key, signature_content_type, photo_key, photo_content_type, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            RETURNING id, created_at
+        `
+		err := tx.QueryRow(query, order.ID, proof.CourierID, proof.RecipientName, proof.Lat, proof.Lng, proof.CapturedAt,
+			proof.PinVerified, signatureKey, nullIfEmpty(signatureType), photoKey, nullIfEmpty(photoType), now).Scan(&proof.ID, &proof.CreatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = completeDelivery(tx, order)
+		return err
+	})
+	if err != nil {
+		removeBlobs(stored)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Order was changed by another request", http.StatusConflict)
+			return
+		}
+		if err == handoff.ErrNotCleared {
+			http.Error(w, "Delivery code has not been verified", http.StatusConflict)
+			return
+		}
+		log.Println("Error recording proof of delivery:", err)
+		http.Error(w, "Could not record proof of delivery", http.StatusInternalServerError)
+		return
+	}
+	outbox.Notify()
+
+	setProofURLs(&proof, signatureKey, nullIfEmpty(signatureType), photoKey, nullIfEmpty(photoType))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(proof)
+}
+
+// GetProofOfDelivery godoc
+// @Summary Get an order's proof of delivery
+// @Description Get the evidence recorded when the order was delivered, with signed links to the signature and photo that work without a token until links_expire_at. Available to the customer, the assigned courier and the store's staff.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.ProofOfDelivery "The proof of delivery"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order"
+// @Failure 404 {object} map[string]string "Order or proof of delivery not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/proof-of-delivery [get]
+func (pc *ProofOfDeliveryController) GetProofOfDelivery(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	var proof models.ProofOfDelivery
+	var signatureKey, signatureType, photoKey, photoType *string
+	query := `
+        SELECT id, order_id, courier_id, recipient_name, lat, lng, captured_at, pin_verified,
+            signature_key, signature_content_type, photo_key, photo_content_type, created_at
+        FROM proofs_of_delivery WHERE order_id = $1
+    `
+	err := utils.DB.QueryRow(query, order.ID).Scan(&proof.ID, &proof.OrderID, &proof.CourierID, &proof.RecipientName, &proof.Lat,
+		&proof.Lng, &proof.CapturedAt, &proof.PinVerified, &signatureKey, &signatureType, &photoKey, &photoType, &proof.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Proof of delivery not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error loading proof of delivery:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	setProofURLs(&proof, signatureKey, signatureType, photoKey, photoType)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proof)
+}
+
+// GetProofOfDeliveryFile godoc
+// @Summary Download a proof of delivery image
+// @Description Download the signature or photo attached to an order's proof of delivery. Available to the customer, the assigned courier and the store's staff.
+// @Produce image/png
+// @Produce image/jpeg
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param kind path string true "signature or photo"
+// @Success 200 {file} file "The image"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this order"
+// @Failure 404 {object} map[string]string "Order or image not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/proof-of-delivery/{kind} [get]
+func (pc *ProofOfDeliveryController) GetProofOfDeliveryFile(w http.ResponseWriter, r *http.Request) {
+	_, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	kind := mux.Vars(r)["kind"]
+	var key, contentType sql.NullString
+	var query string
+	switch kind {
+	case "signature":
+		query = "SELECT signature_key, signature_content_type FROM proofs_of_delivery WHERE order_id = $1"
+	case "photo":
+		query = "SELECT photo_key, photo_content_type FROM proofs_of_delivery WHERE order_id = $1"
+	default:
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	err := utils.DB.QueryRow(query, order.ID).Scan(&key, &contentType)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("Error loading proof of delivery:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !key.Valid {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	content, err := blobstore.Default.Open(key.String)
+	if err != nil {
+		if err == blobstore.ErrNotFound {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error opening proof of delivery file:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType.String)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	io.Copy(w, content)
+}
+
+// readProofImage reads an optional uploaded image, checking it against blobstore.ImagePolicy.
+// It returns nil content if the field was not sent.
+func readProofImage(r *http.Request, field string) ([]byte, string, error) {
+	file, header, err := r.FormFile(field)
+	if err == http.ErrMissingFile {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	if header.Size > blobstore.ImagePolicy.MaxSize {
+		return nil, "", errors.New(field + ": file is too large")
+	}
+	content, contentType, err := blobstore.ImagePolicy.Read(file)
+	var invalid *blobstore.ValidationError
+	if errors.As(err, &invalid) {
+		return nil, "", errors.New(field + ": " + invalid.Message)
+	}
+	return content, contentType, err
+}
+
+// setProofURLs links the proof's images to time-limited signed downloads, leaving them out if signing fails
+func setProofURLs(proof *models.ProofOfDelivery, signatureKey, signatureType, photoKey, photoType *string) {
+	expiresAt := time.Now().Add(ProofLinkTTL)
+	sign := func(key, contentType *string) *string {
+		if key == nil {
+			return nil
+		}
+		var kind string
+		if contentType != nil {
+			kind = *contentType
+		}
+		url, err := blobstore.SignedURL(*key, kind, ProofLinkTTL)
+		if err != nil {
+			log.Println("Error signing proof of delivery link:", err)
+			return nil
+		}
+		return &url
+	}
+
+	proof.SignatureURL = sign(signatureKey, signatureType)
+	proof.PhotoURL = sign(photoKey, photoType)
+	if proof.SignatureURL != nil || proof.PhotoURL != nil {
+		proof.LinksExpireAt = &expiresAt
+	}
+}
+
+// removeBlobs deletes uploaded files that were not recorded, logging failures
+func removeBlobs(keys []string) {
+	for _, key := range keys {
+		if err := blobstore.Default.Delete(key); err != nil {
+			log.Println("Error removing blob", key+":", err)
+		}
+	}
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
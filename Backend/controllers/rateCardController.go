@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/pricing"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// RateCardController lets store owners define how their deliveries are priced
+type RateCardController struct{}
+
+// CreateRateCard godoc
+// @Summary Publish a rate card
+// @Description Save a new version of the owner's store rate card. New quotes are priced with it immediately; orders placed earlier keep the version they were quoted with.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param card body pricing.RateCard true "Fees, surcharges, multipliers and peak periods (amounts in minor currency units)"
+// @Success 201 {object} pricing.RateCard "The saved rate card with its version"
+// @Failure 400 {object} map[string]string "Invalid rate card"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage rate cards"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /rate-cards [post]
+func (rc *RateCardController) CreateRateCard(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var card pricing.RateCard
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := card.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		saved, err := pricing.Save(tx, identity.StoreId, identity.UserID, card)
+		card = saved
+		return err
+	})
+	if err != nil {
+		log.Println("Error saving rate card:", err)
+		http.Error(w, "Could not save rate card", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card)
+}
+
+// ListRateCards godoc
+// @Summary List rate card versions
+// @Description List every version of the store's rate card, newest first. Available to the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} pricing.RateCard "Rate card versions"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view rate cards"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /rate-cards [get]
+func (rc *RateCardController) ListRateCards(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	cards, err := pricing.List(identity.StoreId)
+	if err != nil {
+		log.Println("Error retrieving rate cards:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cards)
+}
+
+// GetCurrentRateCard godoc
+// @Summary Get the current rate card
+// @Description Get the rate card new quotes for the store are priced with. Stores that have not published one use the default card, which has no version.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pricing.RateCard "The current rate card"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view rate cards"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /rate-cards/current [get]
+func (rc *RateCardController) GetCurrentRateCard(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	card, err := rateCards.CurrentRateCard(identity.StoreId)
+	if err != nil {
+		log.Println("Error loading rate card:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
+
+// GetRateCardVersion godoc
+// @Summary Get a rate card version
+// @Description Get one version of the store's rate card, e.g. the one a past order was priced with
+// @Produce json
+// @Security BearerAuth
+// @Param version path int true "Rate card version"
+// @Success 200 {object} pricing.RateCard "The rate card"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view rate cards"
+// @Failure 404 {object} map[string]string "Rate card version not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /rate-cards/{version} [get]
+func (rc *RateCardController) GetRateCardVersion(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, "Rate card version not found", http.StatusNotFound)
+		return
+	}
+
+	card, err := pricing.Version(identity.StoreId, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Rate card version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error loading rate card:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(card)
+}
@@ -0,0 +1,625 @@
+package controllers
+
+import (
+	"PTS/hub"
+	"PTS/models"
+	"PTS/recurring"
+	"PTS/utils"
+	"PTS/windows"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// upcomingOccurrences is how many future dates are listed on a recurring order
+const upcomingOccurrences = 10
+
+const recurringOrderColumns = `id, user_id, store_id, pickup_location, drop_off_location, pickup_address, drop_off_address, delivery_window, package_details, rule,
+    to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), status, created_at, updated_at`
+
+// RecurringOrderController lets customers repeat an order on a schedule. The scheduler places each
+// occurrence as a normal order recurring.LeadDays ahead of its delivery date.
+type RecurringOrderController struct{}
+
+// CreateRecurringOrder godoc
+// @Summary Create a recurring order
+// @Description Repeat an order with a store on a schedule. The rule is daily or weekly (every interval days or weeks, weekly on the listed weekdays) or a cron-style "day-of-month month day-of-week" expression. Each occurrence is placed as a normal order a few days ahead in the given delivery window; if the store does not deliver then or the window is full, that occurrence fails and the next one is tried.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param recurringOrder body models.RecurringOrderRequest true "Order data and schedule"
+// @Success 201 {object} models.RecurringOrder "The created recurring order with its upcoming dates"
+// @Failure 400 {object} map[string]string "Missing required fields, invalid schedule, an address could not be found or the drop-off is outside the store's service zones"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers can create recurring orders"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders [post]
+func (rc *RecurringOrderController) CreateRecurringOrder(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	var req models.RecurringOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.StoreId == "" || req.Delivery == "" || req.PackageDetails == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if err := req.Rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(req.StoreId); err != nil {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	today := recurring.Today(time.Now())
+	start := today.AddDate(0, 0, 1)
+	if req.StartDate != "" {
+		var err error
+		if start, err = time.Parse(time.DateOnly, req.StartDate); err != nil {
+			http.Error(w, "start_date must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		if start.Before(today) {
+			http.Error(w, "start_date cannot be in the past", http.StatusBadRequest)
+			return
+		}
+	}
+	var end *string
+	if req.EndDate != "" {
+		endDate, err := time.Parse(time.DateOnly, req.EndDate)
+		if err != nil {
+			http.Error(w, "end_date must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		if endDate.Before(start) {
+			http.Error(w, "end_date cannot be before start_date", http.StatusBadRequest)
+			return
+		}
+		end = &req.EndDate
+	}
+	first := req.Rule.Next(start, start, 1)
+	if len(first) == 0 || (end != nil && first[0].Format(time.DateOnly) > *end) {
+		http.Error(w, "The schedule never repeats between start_date and end_date", http.StatusBadRequest)
+		return
+	}
+
+	var storeExists bool
+	err := utils.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM stores WHERE id = $1)", req.StoreId).Scan(&storeExists)
+	if err != nil {
+		log.Println("Error checking store existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !storeExists {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return
+	}
+
+	// Individual dates are checked when they are placed, but the store must offer the window at all
+	schedule, err := windows.ForStore(req.StoreId)
+	if err != nil {
+		log.Println("Error loading delivery schedule:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if !offersWindow(schedule, req.Delivery) {
+		http.Error(w, "The store does not deliver in the "+req.Delivery+" window", http.StatusBadRequest)
+		return
+	}
+
+	// Each occurrence is placed with these addresses, so later address book edits do not move the order
+	pickup, dropOff, err := resolveOrderAddresses(identity.UserID, req.Pickup, req.DropOff, req.OrderAddresses)
+	var addressRejection *addressRejection
+	if errors.As(err, &addressRejection) {
+		http.Error(w, addressRejection.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error resolving recurring order addresses:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := checkServiceArea(req.StoreId, dropOff); err != nil {
+		if errors.As(err, &addressRejection) {
+			http.Error(w, addressRejection.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Println("Error checking service area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	pickupJSON, err := addressJSON(pickup)
+	if err != nil {
+		log.Println("Error encoding pickup address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	dropOffJSON, err := addressJSON(dropOff)
+	if err != nil {
+		log.Println("Error encoding drop-off address:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	rule, err := json.Marshal(req.Rule)
+	if err != nil {
+		log.Println("Error encoding recurrence rule:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+        INSERT INTO recurring_orders (user_id, store_id, pickup_location, drop_off_location, pickup_address, drop_off_address, delivery_window, package_details, rule, start_date, end_date, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
+        RETURNING ` + recurringOrderColumns
+	row := utils.DB.QueryRow(query, identity.UserID, req.StoreId, pickup.String(), dropOff.String(), pickupJSON, dropOffJSON, req.Delivery, req.PackageDetails, rule,
+		start.Format(time.DateOnly), end, recurring.StatusActive, time.Now())
+	order, err := scanRecurringOrder(row)
+	if err != nil {
+		log.Println("Error creating recurring order:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if order.Upcoming, err = listUpcoming(order); err != nil {
+		log.Println("Error listing upcoming occurrences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+// ListRecurringOrders godoc
+// @Summary List recurring orders
+// @Description List the logged-in customer's recurring orders, including cancelled ones
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.RecurringOrder "Recurring orders, newest first"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only customers have recurring orders"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders [get]
+func (rc *RecurringOrderController) ListRecurringOrders(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return
+	}
+
+	rows, err := utils.DB.Query("SELECT "+recurringOrderColumns+" FROM recurring_orders WHERE user_id = $1 ORDER BY created_at DESC", identity.UserID)
+	if err != nil {
+		log.Println("Error retrieving recurring orders:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.RecurringOrder{}
+	for rows.Next() {
+		order, err := scanRecurringOrder(rows)
+		if err != nil {
+			log.Println("Error scanning recurring order:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		orders = append(orders, *order)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+// GetRecurringOrder godoc
+// @Summary Get a recurring order
+// @Description Get one of the customer's recurring orders with its next dates. Dates the scheduler has handled show whether they were placed (with the order ID), failed (with the reason) or skipped.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Success 200 {object} models.RecurringOrder "The recurring order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to view this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id} [get]
+func (rc *RecurringOrderController) GetRecurringOrder(w http.ResponseWriter, r *http.Request) {
+	order, ok := loadOwnRecurringOrder(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	if order.Upcoming, err = listUpcoming(order); err != nil {
+		log.Println("Error listing upcoming occurrences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// PauseRecurringOrder godoc
+// @Summary Pause a recurring order
+// @Description Stop placing new orders until the recurring order is resumed. Orders already placed are kept and can be cancelled individually.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Success 200 {object} models.RecurringOrder "The paused recurring order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to change this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order not found"
+// @Failure 409 {object} map[string]string "The recurring order is not active"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id}/pause [post]
+func (rc *RecurringOrderController) PauseRecurringOrder(w http.ResponseWriter, r *http.Request) {
+	setRecurringStatus(w, r, recurring.StatusPaused, recurring.StatusActive)
+}
+
+// ResumeRecurringOrder godoc
+// @Summary Resume a recurring order
+// @Description Start placing orders again for a paused recurring order. Upcoming dates that are still open are placed on the scheduler's next run.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Success 200 {object} models.RecurringOrder "The resumed recurring order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to change this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order not found"
+// @Failure 409 {object} map[string]string "The recurring order is not paused"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id}/resume [post]
+func (rc *RecurringOrderController) ResumeRecurringOrder(w http.ResponseWriter, r *http.Request) {
+	setRecurringStatus(w, r, recurring.StatusActive, recurring.StatusPaused)
+}
+
+// CancelRecurringOrder godoc
+// @Summary Cancel a recurring order
+// @Description Stop a recurring order for good. Orders already placed are kept and can be cancelled individually.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Success 200 {object} models.RecurringOrder "The cancelled recurring order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to change this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order not found"
+// @Failure 409 {object} map[string]string "The recurring order is already cancelled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id} [delete]
+func (rc *RecurringOrderController) CancelRecurringOrder(w http.ResponseWriter, r *http.Request) {
+	setRecurringStatus(w, r, recurring.StatusCancelled, recurring.StatusActive, recurring.StatusPaused)
+}
+
+// SkipOccurrence godoc
+// @Summary Skip a date of a recurring order
+// @Description Skip one upcoming date so no order is placed for it. Dates that already have an order cannot be skipped; cancel that order instead.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Param skip body models.SkipOccurrenceRequest true "Date to skip (YYYY-MM-DD)"
+// @Success 200 {object} models.RecurringOrder "The recurring order"
+// @Failure 400 {object} map[string]string "Invalid date or the order does not repeat on it"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to change this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order not found"
+// @Failure 409 {object} map[string]string "The recurring order is cancelled or the date has already been placed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id}/skips [post]
+func (rc *RecurringOrderController) SkipOccurrence(w http.ResponseWriter, r *http.Request) {
+	order, ok := loadOwnRecurringOrder(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.SkipOccurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		http.Error(w, "date must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if date.Before(recurring.Today(time.Now())) {
+		http.Error(w, "Cannot skip a date in the past", http.StatusBadRequest)
+		return
+	}
+	if !repeatsOn(order, date) {
+		http.Error(w, "The recurring order does not repeat on "+req.Date, http.StatusBadRequest)
+		return
+	}
+	if order.Status == recurring.StatusCancelled {
+		http.Error(w, "The recurring order is cancelled", http.StatusConflict)
+		return
+	}
+
+	query := `
+        INSERT INTO recurring_occurrences (recurring_id, occurrence_date, status, created_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (recurring_id, occurrence_date) DO NOTHING
+        RETURNING status
+    `
+	var status string
+	err = utils.DB.QueryRow(query, order.ID, req.Date, recurring.OccurrenceSkipped, time.Now()).Scan(&status)
+	if err == sql.ErrNoRows {
+		// Already recorded: skipping twice is fine, but a placed or failed date is settled
+		err = utils.DB.QueryRow("SELECT status FROM recurring_occurrences WHERE recurring_id = $1 AND occurrence_date = $2", order.ID, req.Date).Scan(&status)
+		if err == nil && status != recurring.OccurrenceSkipped {
+			http.Error(w, "The order for "+req.Date+" has already been "+status, http.StatusConflict)
+			return
+		}
+	}
+	if err != nil {
+		log.Println("Error skipping occurrence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if order.Upcoming, err = listUpcoming(order); err != nil {
+		log.Println("Error listing upcoming occurrences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// UnskipOccurrence godoc
+// @Summary Stop skipping a date of a recurring order
+// @Description Place an order for a date that was skipped after all. If the date is already within the scheduler's lead time it is placed on the next run.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recurring order ID"
+// @Param date path string true "Skipped date (YYYY-MM-DD)"
+// @Success 200 {object} models.RecurringOrder "The recurring order"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to change this recurring order"
+// @Failure 404 {object} map[string]string "Recurring order or skipped date not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /recurring-orders/{id}/skips/{date} [delete]
+func (rc *RecurringOrderController) UnskipOccurrence(w http.ResponseWriter, r *http.Request) {
+	order, ok := loadOwnRecurringOrder(w, r)
+	if !ok {
+		return
+	}
+
+	date := mux.Vars(r)["date"]
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		http.Error(w, "Skipped date not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := utils.DB.Exec("DELETE FROM recurring_occurrences WHERE recurring_id = $1 AND occurrence_date = $2 AND status = $3",
+		order.ID, date, recurring.OccurrenceSkipped)
+	if err != nil {
+		log.Println("Error removing skipped occurrence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		http.Error(w, "Skipped date not found", http.StatusNotFound)
+		return
+	}
+
+	if order.Upcoming, err = listUpcoming(order); err != nil {
+		log.Println("Error listing upcoming occurrences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// PlaceRecurringOrder places the order for one occurrence of a recurring order within tx.
+// It is the scheduler's PlaceFunc; dates the store cannot take are returned as a *recurring.Failure.
+func PlaceRecurringOrder(tx *sql.Tx, occurrence recurring.Occurrence) (string, error) {
+	req := models.PlaceOrderRequest{
+		StoreId:        occurrence.StoreId,
+		Pickup:         occurrence.Pickup,
+		DropOff:        occurrence.DropOff,
+		Delivery:       occurrence.DeliveryWindow,
+		PackageDetails: occurrence.PackageDetails,
+		DeliveryDate:   occurrence.Date,
+	}
+
+	// The store may have redrawn its zones since the recurring order was created
+	_, err := checkServiceArea(req.StoreId, occurrence.DropOffAddress)
+	var addressRejection *addressRejection
+	if errors.As(err, &addressRejection) {
+		return "", &recurring.Failure{Reason: addressRejection.message}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	slot, capacity, err := windows.Resolve(req.StoreId, req.Delivery, req.DeliveryDate, time.Now())
+	var windowRejection *windows.Rejection
+	if errors.As(err, &windowRejection) {
+		return "", &recurring.Failure{Reason: windowRejection.Reason}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	order, err := insertOrder(tx, occurrence.UserID, req, occurrence.PickupAddress, occurrence.DropOffAddress, slot, capacity, nil)
+	if err == windows.ErrFull {
+		return "", &recurring.Failure{Reason: "the " + req.Delivery + " window on " + req.DeliveryDate + " is full"}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return order.ID, recordOrderEvent(tx, hub.OrderCreated, models.OrderEventData{Order: order})
+}
+
+// setRecurringStatus moves the customer's recurring order to status if it is currently in one of from
+func setRecurringStatus(w http.ResponseWriter, r *http.Request, status string, from ...string) {
+	order, ok := loadOwnRecurringOrder(w, r)
+	if !ok {
+		return
+	}
+
+	query := `
+        UPDATE recurring_orders SET status = $1, updated_at = $2
+        WHERE id = $3 AND status = ANY($4)
+        RETURNING ` + recurringOrderColumns
+	updated, err := scanRecurringOrder(utils.DB.QueryRow(query, status, time.Now(), order.ID, pq.Array(from)))
+	if err == sql.ErrNoRows {
+		http.Error(w, "The recurring order is "+order.Status, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("Error updating recurring order:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	if updated.Upcoming, err = listUpcoming(updated); err != nil {
+		log.Println("Error listing upcoming occurrences:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// loadOwnRecurringOrder loads the recurring order named in the URL, writing the error response if it is
+// missing or does not belong to the logged-in customer
+func loadOwnRecurringOrder(w http.ResponseWriter, r *http.Request) (*models.RecurringOrder, bool) {
+	identity, ok := requireRole(w, r, models.RoleUser)
+	if !ok {
+		return nil, false
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	order, err := scanRecurringOrder(utils.DB.QueryRow("SELECT "+recurringOrderColumns+" FROM recurring_orders WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring order not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Println("Error retrieving recurring order:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if order.UserID != identity.UserID {
+		http.Error(w, "Not allowed to access this recurring order", http.StatusForbidden)
+		return nil, false
+	}
+
+	return order, true
+}
+
+func scanRecurringOrder(row rowScanner) (*models.RecurringOrder, error) {
+	var order models.RecurringOrder
+	var rule, pickup, dropOff []byte
+	err := row.Scan(&order.ID, &order.UserID, &order.StoreId, &order.PickupLocation, &order.DropOffLocation, &pickup, &dropOff, &order.DeliveryWindow,
+		&order.PackageDetails, &rule, &order.StartDate, &order.EndDate, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rule, &order.Rule); err != nil {
+		return nil, err
+	}
+	if err := unmarshalAddresses(pickup, dropOff, &order.PickupAddress, &order.DropOffAddress); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// listUpcoming returns the order's next dates from today, merged with what the scheduler recorded for them
+func listUpcoming(order *models.RecurringOrder) ([]models.RecurringOccurrence, error) {
+	today := recurring.Today(time.Now())
+	query := `
+        SELECT to_char(occurrence_date, 'YYYY-MM-DD'), status, order_id, reason
+        FROM recurring_occurrences
+        WHERE recurring_id = $1 AND occurrence_date >= $2
+        ORDER BY occurrence_date
+    `
+	rows, err := utils.DB.Query(query, order.ID, today.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recorded := map[string]models.RecurringOccurrence{}
+	for rows.Next() {
+		var occurrence models.RecurringOccurrence
+		if err := rows.Scan(&occurrence.Date, &occurrence.Status, &occurrence.OrderID, &occurrence.Reason); err != nil {
+			return nil, err
+		}
+		recorded[occurrence.Date] = occurrence
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	upcoming := []models.RecurringOccurrence{}
+	if order.Status == recurring.StatusCancelled {
+		return upcoming, nil
+	}
+	start, err := time.Parse(time.DateOnly, order.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	for _, date := range order.Rule.Next(start, today, upcomingOccurrences) {
+		day := date.Format(time.DateOnly)
+		if order.EndDate != nil && day > *order.EndDate {
+			break
+		}
+		occurrence, ok := recorded[day]
+		if !ok {
+			occurrence = models.RecurringOccurrence{Date: day}
+		}
+		upcoming = append(upcoming, occurrence)
+	}
+	return upcoming, nil
+}
+
+// repeatsOn reports whether the recurring order has an occurrence on date
+func repeatsOn(order *models.RecurringOrder, date time.Time) bool {
+	start, err := time.Parse(time.DateOnly, order.StartDate)
+	if err != nil || !order.Rule.Matches(start, date) {
+		return false
+	}
+	return order.EndDate == nil || date.Format(time.DateOnly) <= *order.EndDate
+}
+
+// offersWindow reports whether the schedule has the named window on any weekday
+func offersWindow(schedule windows.Schedule, name string) bool {
+	for _, day := range schedule.Days {
+		for _, window := range day {
+			if window.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
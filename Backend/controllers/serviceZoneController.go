@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"PTS/geo"
+	"PTS/geocode"
+	"PTS/models"
+	"PTS/utils"
+	"PTS/zones"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const maxZoneNameLength = 100
+
+// ServiceZoneController lets owners draw the areas their store delivers to
+type ServiceZoneController struct{}
+
+// CreateServiceZone godoc
+// @Summary Add a service zone
+// @Description Add an area the owner's store delivers to, as a GeoJSON Polygon or MultiPolygon. Once a store has an active zone, orders with drop-offs outside all its zones are rejected. A rate card's zone_surcharges are keyed by zone name.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param zone body models.ServiceZoneRequest true "Zone name and area"
+// @Success 201 {object} zones.Zone "The created zone"
+// @Failure 400 {object} map[string]string "Missing name or invalid area"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage service zones"
+// @Failure 409 {object} map[string]string "The store already has a zone with this name"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /service-zones [post]
+func (sc *ServiceZoneController) CreateServiceZone(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeServiceZone(w, r)
+	if !ok {
+		return
+	}
+	active := req.Active == nil || *req.Active
+
+	area, err := json.Marshal(req.Area)
+	if err != nil {
+		log.Println("Error encoding zone area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+        INSERT INTO service_zones (store_id, name, area, active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $5)
+        RETURNING ` + zones.Columns
+	zone, err := zones.Scan(utils.DB.QueryRow(query, identity.StoreId, req.Name, area, active, time.Now()))
+	if err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, "The store already has a zone named "+req.Name, http.StatusConflict)
+			return
+		}
+		log.Println("Error creating service zone:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(zone)
+}
+
+// ListServiceZones godoc
+// @Summary List the store's service zones
+// @Description List every service zone of the store, active or not. Available to the store's admins and owner.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} zones.Zone "Zones by name"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only store staff can view service zones"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /service-zones [get]
+func (sc *ServiceZoneController) ListServiceZones(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	list, err := zones.ForStore(identity.StoreId, false)
+	if err != nil {
+		log.Println("Error retrieving service zones:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateServiceZone godoc
+// @Summary Change a service zone
+// @Description Replace a zone's name, area and active flag. Orders already placed are not affected.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Zone ID"
+// @Param zone body models.ServiceZoneRequest true "Zone name and area"
+// @Success 200 {object} zones.Zone "The updated zone"
+// @Failure 400 {object} map[string]string "Missing name or invalid area"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage service zones"
+// @Failure 404 {object} map[string]string "Zone not found"
+// @Failure 409 {object} map[string]string "The store already has a zone with this name"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /service-zones/{id} [put]
+func (sc *ServiceZoneController) UpdateServiceZone(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Zone not found", http.StatusNotFound)
+		return
+	}
+
+	req, ok := decodeServiceZone(w, r)
+	if !ok {
+		return
+	}
+	active := req.Active == nil || *req.Active
+
+	area, err := json.Marshal(req.Area)
+	if err != nil {
+		log.Println("Error encoding zone area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+        UPDATE service_zones SET name = $1, area = $2, active = $3, updated_at = $4
+        WHERE id = $5 AND store_id = $6
+        RETURNING ` + zones.Columns
+	zone, err := zones.Scan(utils.DB.QueryRow(query, req.Name, area, active, time.Now(), id, identity.StoreId))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Zone not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, "The store already has a zone named "+req.Name, http.StatusConflict)
+			return
+		}
+		log.Println("Error updating service zone:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zone)
+}
+
+// DeleteServiceZone godoc
+// @Summary Delete a service zone
+// @Description Remove a zone from the owner's store. If it was the store's last active zone, the store delivers anywhere again.
+// @Security BearerAuth
+// @Param id path string true "Zone ID"
+// @Success 204 "Zone deleted"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage service zones"
+// @Failure 404 {object} map[string]string "Zone not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /service-zones/{id} [delete]
+func (sc *ServiceZoneController) DeleteServiceZone(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "Zone not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := utils.DB.Exec("DELETE FROM service_zones WHERE id = $1 AND store_id = $2", id, identity.StoreId)
+	if err != nil {
+		log.Println("Error deleting service zone:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		http.Error(w, "Zone not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStoreServiceZones godoc
+// @Summary Get where a store delivers
+// @Description List a store's active service zones, for drawing them on a map. An empty list means the store delivers anywhere.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {array} zones.Zone "Active zones by name"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/{id}/service-zones [get]
+func (sc *ServiceZoneController) GetStoreServiceZones(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := requireStore(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := zones.ForStore(storeID, true)
+	if err != nil {
+		log.Println("Error retrieving service zones:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CheckServiceArea godoc
+// @Summary Check whether a store delivers to a point
+// @Description Check a drop-off position against the store's active service zones before placing an order
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param lat query number true "Latitude"
+// @Param lng query number true "Longitude"
+// @Success 200 {object} models.ServiceAreaCheck "Whether the point is served, and by which zone"
+// @Failure 400 {object} map[string]string "Invalid coordinates"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 404 {object} map[string]string "Store not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/{id}/service-zones/check [get]
+func (sc *ServiceZoneController) CheckServiceArea(w http.ResponseWriter, r *http.Request) {
+	storeID, ok := requireStore(w, r)
+	if !ok {
+		return
+	}
+
+	lat, latErr := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(r.URL.Query().Get("lng"), 64)
+	point := geo.Point{Lat: lat, Lng: lng}
+	if latErr != nil || lngErr != nil || !point.Valid() {
+		http.Error(w, "lat and lng must be valid coordinates", http.StatusBadRequest)
+		return
+	}
+
+	var check models.ServiceAreaCheck
+	zone, err := zones.Locate(storeID, point)
+	var outside *zones.OutsideError
+	switch {
+	case errors.As(err, &outside):
+		check = models.ServiceAreaCheck{Nearest: outside.Nearest, DistanceKm: outside.DistanceKm, Message: outside.Error()}
+	case err != nil:
+		log.Println("Error checking service area:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	case zone != nil:
+		check = models.ServiceAreaCheck{Inside: true, ZoneID: zone.ID, ZoneName: zone.Name}
+	default:
+		check = models.ServiceAreaCheck{Inside: true}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
+
+// checkServiceArea returns an *addressRejection if the drop-off is outside the store's service zones,
+// and otherwise the zone it is in (nil if the store delivers anywhere)
+func checkServiceArea(storeID string, dropOff *geocode.Address) (*zones.Zone, error) {
+	if dropOff == nil || dropOff.Location == nil {
+		return nil, nil
+	}
+
+	zone, err := zones.Locate(storeID, *dropOff.Location)
+	var outside *zones.OutsideError
+	if errors.As(err, &outside) {
+		return nil, &addressRejection{outside.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A city centre says nothing about which side of a zone's edge the address is on
+	if zone != nil && dropOff.Precision == geocode.PrecisionCity {
+		return nil, &addressRejection{"the drop-off address is only known to the city; add the district or pin its location so we can check the store delivers there"}
+	}
+	return zone, nil
+}
+
+// decodeServiceZone reads and validates a ServiceZoneRequest, writing the error response if it is invalid
+func decodeServiceZone(w http.ResponseWriter, r *http.Request) (models.ServiceZoneRequest, bool) {
+	var req models.ServiceZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxZoneNameLength {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return req, false
+	}
+	if _, err := req.Area.Shape(); err != nil {
+		http.Error(w, "Invalid area: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// requireStore reads the store ID from the URL for any signed-in account, writing the error response
+// if the store does not exist
+func requireStore(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, ok := requireRole(w, r, models.RoleUser, models.RoleCourier, models.RoleAdmin, models.RoleOwner); !ok {
+		return "", false
+	}
+
+	storeID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(storeID); err != nil {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return "", false
+	}
+
+	var storeExists bool
+	if err := utils.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM stores WHERE id = $1)", storeID).Scan(&storeExists); err != nil {
+		log.Println("Error checking store existence:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return "", false
+	}
+	if !storeExists {
+		http.Error(w, "Store not found", http.StatusNotFound)
+		return "", false
+	}
+	return storeID, true
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"PTS/hub"
+	"PTS/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// streamKeepAlive is how often a comment is sent to keep idle streams open through proxies
+const streamKeepAlive = 25 * time.Second
+
+// StreamController pushes live order updates to subscribers using Server-Sent Events
+type StreamController struct{}
+
+// StreamOrder godoc
+// @Summary Stream live updates for an order
+// @Description Subscribe to an order's status changes, courier assignment changes and courier location updates as Server-Sent Events. Available to the customer, the assigned courier and the store's staff; a courier's stream ends if the order is reassigned to someone else. Streams that fall too far behind are closed; reconnect to start again from the current state. Browsers may pass the token as the access_token query parameter.
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param access_token query string false "JWT token, for clients that cannot set headers"
+// @Success 200 {object} hub.Event "Stream of order events"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to access this order"
+// @Failure 404 {object} map[string]string "Order not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /orders/{id}/stream [get]
+func (sc *StreamController) StreamOrder(w http.ResponseWriter, r *http.Request) {
+	identity, order, ok := loadAuthorizedOrder(w, r)
+	if !ok {
+		return
+	}
+
+	sub := hub.Default.Subscribe(hub.OrderTopic(order.ID))
+	defer sub.Close()
+
+	// A courier loses access when the order is reassigned. The assignment reaches the hub through the outbox,
+	// after the new courier's location may already have been broadcast, so access is checked again before
+	// every assignment, location and ETA event and the stream ends once it is gone.
+	allowed := func(event hub.Event) bool {
+		if identity.Role != models.RoleCourier {
+			return true
+		}
+		switch event.Type {
+		case hub.OrderAssigned, hub.OrderLocation, hub.OrderETA:
+		default:
+			return true
+		}
+		current, err := loadOrder(order.ID)
+		if err != nil {
+			log.Println("Error reloading order for stream:", err)
+			return false
+		}
+		return canViewOrder(identity, current)
+	}
+
+	// Start with the current state so the client does not need a separate fetch
+	snapshot := hub.Event{Type: "order.snapshot", OrderID: order.ID, StoreId: order.StoreId, Data: order, At: time.Now()}
+	streamEvents(w, r, sub, snapshot, allowed)
+}
+
+// StreamStore godoc
+// @Summary Stream live updates for a store
+// @Description Subscribe to events for every order of a store as Server-Sent Events. Only the store's admins and owner can subscribe. Streams that fall too far behind are closed; reconnect to start again. Browsers may pass the token as the access_token query parameter.
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param access_token query string false "JWT token, for clients that cannot set headers"
+// @Success 200 {object} hub.Event "Stream of order events"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Not allowed to access this store"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /stores/{id}/stream [get]
+func (sc *StreamController) StreamStore(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleAdmin, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	storeID := mux.Vars(r)["id"]
+	if identity.StoreId != storeID {
+		http.Error(w, "Not allowed to access this store", http.StatusForbidden)
+		return
+	}
+
+	sub := hub.Default.Subscribe(hub.StoreTopic(storeID))
+	defer sub.Close()
+
+	streamEvents(w, r, sub, hub.Event{Type: "store.subscribed", StoreId: storeID, At: time.Now()}, nil)
+}
+
+// streamEvents writes the first event and then every event from the subscription until the client disconnects.
+// If allowed is set, it is asked before each event is written and the stream ends when it returns false.
+func streamEvents(w http.ResponseWriter, r *http.Request, sub *hub.Subscription, first hub.Event, allowed func(hub.Event) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if err := writeEvent(w, first); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sub.Lost:
+			// Events were dropped; end the stream so the client reconnects and starts from a fresh snapshot
+			return
+		case event, open := <-sub.C:
+			if !open {
+				return
+			}
+			if allowed != nil && !allowed(event) {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event named after the event type
+func writeEvent(w http.ResponseWriter, event hub.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Error encoding stream event:", err)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+	return err
+}
@@ -0,0 +1,454 @@
+package controllers
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"PTS/webhooks"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const webhookEndpointColumns = "id, store_id, url, secret, events, active, created_at, updated_at"
+
+const webhookDeliveryColumns = "id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_response_status, last_error, created_at, updated_at"
+
+// WebhookController lets store owners manage webhook endpoints and inspect deliveries
+type WebhookController struct{}
+
+// CreateWebhook godoc
+// @Summary Register a webhook endpoint
+// @Description Register a URL to be notified about the owner's store order events. The response includes the signing secret, which is only shown once.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param webhook body models.WebhookEndpointRequest true "Endpoint URL and subscribed events"
+// @Success 201 {object} models.WebhookEndpoint "The created endpoint, including its secret"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks [post]
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if message := validateWebhookRequest(&req); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		log.Println("Error generating webhook secret:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	var endpoint models.WebhookEndpoint
+	query := `
+        INSERT INTO webhook_endpoints (store_id, url, secret, events, active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+        RETURNING ` + webhookEndpointColumns
+	err = scanWebhookEndpoint(utils.DB.QueryRow(query, identity.StoreId, req.URL, secret, pq.Array(req.Events), active, time.Now()), &endpoint)
+	if err != nil {
+		log.Println("Error inserting webhook endpoint:", err)
+		http.Error(w, "Could not register webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+// ListWebhooks godoc
+// @Summary List webhook endpoints
+// @Description List the webhook endpoints registered for the owner's store. Secrets are not included.
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.WebhookEndpoint "Registered endpoints"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks [get]
+func (wc *WebhookController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rows, err := utils.DB.Query("SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE store_id = $1 ORDER BY created_at", identity.StoreId)
+	if err != nil {
+		log.Println("Error retrieving webhook endpoints:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	endpoints := []models.WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+		if err := scanWebhookEndpoint(rows, &endpoint); err != nil {
+			log.Println("Error scanning webhook endpoint:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		endpoint.Secret = ""
+		endpoints = append(endpoints, endpoint)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook endpoint
+// @Description Change an endpoint's URL, subscribed events or active flag
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Param webhook body models.WebhookEndpointRequest true "Endpoint URL and subscribed events"
+// @Success 200 {object} models.WebhookEndpoint "The updated endpoint"
+// @Failure 400 {object} map[string]string "Missing required fields or invalid input"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id} [put]
+func (wc *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if message := validateWebhookRequest(&req); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	active := endpoint.Active
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	query := `
+        UPDATE webhook_endpoints SET url = $1, events = $2, active = $3, updated_at = $4
+        WHERE id = $5
+        RETURNING ` + webhookEndpointColumns
+	err := scanWebhookEndpoint(utils.DB.QueryRow(query, req.URL, pq.Array(req.Events), active, time.Now(), endpoint.ID), endpoint)
+	if err != nil {
+		log.Println("Error updating webhook endpoint:", err)
+		http.Error(w, "Could not update webhook", http.StatusInternalServerError)
+		return
+	}
+	endpoint.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook endpoint
+// @Description Delete an endpoint together with its delivery log
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Success 200 {object} map[string]string "Success response message"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id} [delete]
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := utils.DB.Exec("DELETE FROM webhook_endpoints WHERE id = $1", endpoint.ID); err != nil {
+		log.Println("Error deleting webhook endpoint:", err)
+		http.Error(w, "Could not delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// PingWebhook godoc
+// @Summary Send a test event
+// @Description Queue a ping event for the endpoint, regardless of its event filters, to check that it receives and verifies deliveries
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Success 202 {object} map[string]string "ID of the queued delivery"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id}/ping [post]
+func (wc *WebhookController) PingWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := webhooks.EnqueueForEndpoint(endpoint.ID, endpoint.StoreId, webhooks.EventPing, map[string]string{"endpoint_id": endpoint.ID})
+	if err != nil {
+		log.Println("Error queueing webhook ping:", err)
+		http.Error(w, "Could not queue ping", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"delivery_id": deliveryID})
+}
+
+// ListWebhookDeliveries godoc
+// @Summary List webhook deliveries
+// @Description List the most recent deliveries to an endpoint, optionally filtered by status
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Param status query string false "Filter by status (pending, succeeded, failed)"
+// @Success 200 {array} models.WebhookDelivery "Deliveries, newest first"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id}/deliveries [get]
+func (wc *WebhookController) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE endpoint_id = $1 AND ($2 = '' OR status = $2) ORDER BY created_at DESC LIMIT 100"
+	rows, err := utils.DB.Query(query, endpoint.ID, r.URL.Query().Get("status"))
+	if err != nil {
+		log.Println("Error retrieving webhook deliveries:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			log.Println("Error scanning webhook delivery:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetWebhookDelivery godoc
+// @Summary Get a webhook delivery
+// @Description Get a delivery with its payload and the log of every attempt
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery "The delivery and its attempts"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook or delivery not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id}/deliveries/{deliveryId} [get]
+func (wc *WebhookController) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, ok := loadWebhookDelivery(w, r, endpoint.ID)
+	if !ok {
+		return
+	}
+
+	query := "SELECT attempted_at, response_status, error, duration_ms FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at"
+	rows, err := utils.DB.Query(query, delivery.ID)
+	if err != nil {
+		log.Println("Error retrieving webhook attempts:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []models.WebhookAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		if err := rows.Scan(&attempt.AttemptedAt, &attempt.ResponseStatus, &attempt.Error, &attempt.DurationMs); err != nil {
+			log.Println("Error scanning webhook attempt:", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// RedeliverWebhook godoc
+// @Summary Redeliver a webhook
+// @Description Queue a delivery to be sent again immediately with a fresh retry budget. The payload and event ID stay the same so receivers can deduplicate.
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook endpoint ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery "The queued delivery"
+// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Failure 403 {object} map[string]string "Only owners can manage webhooks"
+// @Failure 404 {object} map[string]string "Webhook or delivery not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (wc *WebhookController) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, ok := loadWebhookDelivery(w, r, endpoint.ID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	query := `
+        UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = $2, updated_at = $2
+        WHERE id = $3
+        RETURNING ` + webhookDeliveryColumns
+	if err := scanWebhookDelivery(utils.DB.QueryRow(query, models.DeliveryPending, now, delivery.ID), delivery); err != nil {
+		log.Println("Error queueing webhook redelivery:", err)
+		http.Error(w, "Could not redeliver webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// validateWebhookRequest returns a message describing what is wrong with the request, or "" if it is valid
+func validateWebhookRequest(req *models.WebhookEndpointRequest) string {
+	if req.URL == "" || len(req.Events) == 0 {
+		return "Missing required fields"
+	}
+
+	if err := webhooks.CheckURL(req.URL); err == webhooks.ErrBlockedAddress {
+		return "URL must not point to a local, private or internal address"
+	} else if err != nil {
+		return err.Error()
+	}
+
+	for _, event := range req.Events {
+		if !webhooks.IsValidEvent(event) {
+			return "Unknown event type: " + event
+		}
+	}
+	return ""
+}
+
+// loadOwnedWebhook loads the endpoint named in the URL if it belongs to the calling owner's store
+func loadOwnedWebhook(w http.ResponseWriter, r *http.Request) (*models.WebhookEndpoint, bool) {
+	identity, ok := requireRole(w, r, models.RoleOwner)
+	if !ok {
+		return nil, false
+	}
+
+	endpointID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(endpointID); err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	var endpoint models.WebhookEndpoint
+	query := "SELECT " + webhookEndpointColumns + " FROM webhook_endpoints WHERE id = $1 AND store_id = $2"
+	if err := scanWebhookEndpoint(utils.DB.QueryRow(query, endpointID, identity.StoreId), &endpoint); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Println("Error retrieving webhook endpoint:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &endpoint, true
+}
+
+// loadWebhookDelivery loads the delivery named in the URL if it belongs to the endpoint
+func loadWebhookDelivery(w http.ResponseWriter, r *http.Request, endpointID string) (*models.WebhookDelivery, bool) {
+	deliveryID := mux.Vars(r)["deliveryId"]
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	var delivery models.WebhookDelivery
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2"
+	if err := scanWebhookDelivery(utils.DB.QueryRow(query, deliveryID, endpointID), &delivery); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Println("Error retrieving webhook delivery:", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &delivery, true
+}
+
+// scanWebhookEndpoint reads a row selected with webhookEndpointColumns into an endpoint
+func scanWebhookEndpoint(row rowScanner, endpoint *models.WebhookEndpoint) error {
+	return row.Scan(
+		&endpoint.ID, &endpoint.StoreId, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.Events),
+		&endpoint.Active, &endpoint.CreatedAt, &endpoint.UpdatedAt,
+	)
+}
+
+// scanWebhookDelivery reads a row selected with webhookDeliveryColumns into a delivery
+func scanWebhookDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	// Scanning into *[]byte copies the driver's buffer, which json.RawMessage would not
+	var payload []byte
+	err := row.Scan(
+		&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastResponseStatus, &delivery.LastError,
+		&delivery.CreatedAt, &delivery.UpdatedAt,
+	)
+	delivery.Payload = payload
+	return err
+}
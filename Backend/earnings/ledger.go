@@ -0,0 +1,234 @@
+package earnings
+
+import (
+	"PTS/eta"
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// dateLayout is how earning days are written in queries and responses
+const dateLayout = "2006-01-02"
+
+const entryColumns = "id, courier_id, order_id, kind, description, amount, currency, earned_on, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// CurrentRules returns the latest pay rules the store has saved, or DefaultPayRules
+func CurrentRules(storeID string) (PayRules, error) {
+	query := "SELECT id, version, rules, created_at FROM courier_pay_rules WHERE store_id = $1 ORDER BY version DESC LIMIT 1"
+	rules, err := scanRules(utils.DB.QueryRow(query, storeID))
+	if err == sql.ErrNoRows {
+		return DefaultPayRules, nil
+	}
+	return rules, err
+}
+
+// SaveRules stores the rules as the store's next version. Earlier versions are kept, and ledger
+// entries point at the version they were earned under.
+func SaveRules(tx *sql.Tx, storeID, createdBy string, rules PayRules) (PayRules, error) {
+	rules.ID, rules.Version, rules.CreatedAt = "", 0, nil
+	body, err := json.Marshal(rules)
+	if err != nil {
+		return PayRules{}, err
+	}
+
+	// Serialise versioning per store
+	if _, err := tx.Exec("SELECT id FROM stores WHERE id = $1 FOR UPDATE", storeID); err != nil {
+		return PayRules{}, err
+	}
+
+	query := `
+        INSERT INTO courier_pay_rules (store_id, version, rules, created_by, created_at)
+        SELECT $1::UUID, COALESCE(MAX(version), 0) + 1, $2::JSONB, $3::UUID, $4::TIMESTAMP FROM courier_pay_rules WHERE store_id = $1::UUID
+        RETURNING id, version, rules, created_at
+    `
+	return scanRules(tx.QueryRow(query, storeID, body, createdBy, time.Now()))
+}
+
+// ListRules returns every saved version of the store's pay rules, newest first
+func ListRules(storeID string) ([]PayRules, error) {
+	rows, err := utils.DB.Query("SELECT id, version, rules, created_at FROM courier_pay_rules WHERE store_id = $1 ORDER BY version DESC", storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []PayRules{}
+	for rows.Next() {
+		rules, err := scanRules(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rules)
+	}
+	return list, rows.Err()
+}
+
+// RecordDelivery credits the order's courier for delivering it, within the transaction that marks it delivered.
+// The distance comes from the order's price quote, or is routed between its pickup and drop-off when it has none.
+func RecordDelivery(tx *sql.Tx, order *models.Order) error {
+	if order.CourierID == nil {
+		return nil
+	}
+	courierID := *order.CourierID
+
+	rules, err := CurrentRules(order.StoreId)
+	if err != nil {
+		return err
+	}
+
+	var distanceKm float64
+	if order.QuoteID != nil {
+		err := tx.QueryRow("SELECT COALESCE((price->>'distance_km')::DOUBLE PRECISION, 0) FROM price_quotes WHERE id = $1", *order.QuoteID).Scan(&distanceKm)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	if distanceKm == 0 && order.PickupAddress != nil && order.PickupAddress.Location != nil &&
+		order.DropOffAddress != nil && order.DropOffAddress.Location != nil {
+		if distance, err := eta.DefaultRouter.DistanceKm(*order.PickupAddress.Location, *order.DropOffAddress.Location); err == nil {
+			distanceKm = distance
+		}
+	}
+
+	// Serialise deliveries per courier so daily targets are counted once
+	if _, err := tx.Exec("SELECT id FROM couriers WHERE id = $1 FOR UPDATE", courierID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	earnedOn := now.In(rules.Location()).Format(dateLayout)
+	var deliveriesToday int
+	query := "SELECT COUNT(*) FROM courier_earnings WHERE courier_id = $1 AND kind = $2 AND earned_on = $3"
+	if err := tx.QueryRow(query, courierID, KindDelivery, earnedOn).Scan(&deliveriesToday); err != nil {
+		return err
+	}
+
+	var rulesID *string
+	if rules.ID != "" {
+		rulesID = &rules.ID
+	}
+	insertQuery := `
+        INSERT INTO courier_earnings (courier_id, store_id, order_id, kind, description, amount, currency, pay_rules_id, earned_on, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+	for _, line := range rules.ForDelivery(distanceKm, now, deliveriesToday+1) {
+		_, err := tx.Exec(insertQuery, courierID, order.StoreId, order.ID, line.Kind, line.Description, line.Amount, rules.Currency, rulesID, earnedOn, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary totals a courier's earnings between the from and to days (inclusive), grouped by "day" or "week"
+func Summary(courierID, group string, from, to time.Time) (*models.EarningsSummary, error) {
+	summary := models.EarningsSummary{
+		CourierID: courierID,
+		Group:     group,
+		From:      from.Format(dateLayout),
+		To:        to.Format(dateLayout),
+		Buckets:   []models.EarningsBucket{},
+	}
+
+	query := `
+        SELECT date_trunc($1::TEXT, earned_on)::DATE AS bucket, COUNT(*) FILTER (WHERE kind = 'delivery'), SUM(amount)
+        FROM courier_earnings
+        WHERE courier_id = $2 AND earned_on >= $3::DATE AND earned_on <= $4::DATE
+        GROUP BY bucket
+        ORDER BY bucket
+    `
+	rows, err := utils.DB.Query(query, group, courierID, summary.From, summary.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket models.EarningsBucket
+		var start time.Time
+		if err := rows.Scan(&start, &bucket.Deliveries, &bucket.Amount); err != nil {
+			return nil, err
+		}
+		bucket.Start = start.Format(dateLayout)
+		summary.Deliveries += bucket.Deliveries
+		summary.Total += bucket.Amount
+		summary.Buckets = append(summary.Buckets, bucket)
+	}
+	return &summary, rows.Err()
+}
+
+// Statement lists every earning of the store's couriers between the from and to days (inclusive),
+// grouped per courier, for paying them out
+func Statement(storeID string, from, to time.Time) (*models.PayoutStatement, error) {
+	statement := models.PayoutStatement{
+		StoreId:  storeID,
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		Couriers: []models.CourierPayout{},
+	}
+
+	query := "SELECT " + entryColumns + `
+        FROM courier_earnings
+        WHERE store_id = $1 AND earned_on >= $2::DATE AND earned_on <= $3::DATE
+        ORDER BY courier_id, created_at`
+	rows, err := utils.DB.Query(query, storeID, statement.From, statement.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.EarningEntry
+		if err := scanEntry(rows, &entry); err != nil {
+			return nil, err
+		}
+
+		last := len(statement.Couriers) - 1
+		if last < 0 || statement.Couriers[last].CourierID != entry.CourierID {
+			statement.Couriers = append(statement.Couriers, models.CourierPayout{CourierID: entry.CourierID, Currency: entry.Currency})
+			last++
+		}
+		payout := &statement.Couriers[last]
+		if entry.Kind == KindDelivery {
+			payout.Deliveries++
+		}
+		payout.Total += entry.Amount
+		payout.Entries = append(payout.Entries, entry)
+		statement.Total += entry.Amount
+	}
+	return &statement, rows.Err()
+}
+
+func scanEntry(row rowScanner, entry *models.EarningEntry) error {
+	var earnedOn time.Time
+	err := row.Scan(&entry.ID, &entry.CourierID, &entry.OrderID, &entry.Kind, &entry.Description, &entry.Amount,
+		&entry.Currency, &earnedOn, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.EarnedOn = earnedOn.Format(dateLayout)
+	return nil
+}
+
+func scanRules(row rowScanner) (PayRules, error) {
+	var id string
+	var version int
+	var body []byte
+	var createdAt time.Time
+	if err := row.Scan(&id, &version, &body, &createdAt); err != nil {
+		return PayRules{}, err
+	}
+
+	var rules PayRules
+	if err := json.Unmarshal(body, &rules); err != nil {
+		return PayRules{}, err
+	}
+	rules.ID, rules.Version, rules.CreatedAt = id, version, &createdAt
+	return rules, nil
+}
@@ -0,0 +1,133 @@
+// Package earnings pays couriers for their deliveries according to their store's pay rules.
+package earnings
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Earning kinds recorded in the ledger
+const (
+	KindDelivery    = "delivery"
+	KindDistance    = "distance"
+	KindHourlyBonus = "hourly_bonus"
+	KindDailyTarget = "daily_target"
+)
+
+// PayRules is how a store pays its couriers. Amounts are in minor currency units.
+type PayRules struct {
+	// ID, Version and CreatedAt are set on rules a store has saved; DefaultPayRules has none
+	ID        string     `json:"id,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	Currency    string `json:"currency"`
+	PerDelivery int64  `json:"per_delivery"`
+	PerKm       int64  `json:"per_km"`
+
+	// HourlyBonuses add a flat amount to every delivery completed during busy hours
+	HourlyBonuses []HourlyBonus `json:"hourly_bonuses,omitempty"`
+	// DailyTargets pay a one-off bonus the day a courier completes the given number of deliveries
+	DailyTargets []DailyTarget `json:"daily_targets,omitempty"`
+	// Timezone the hours and days are counted in (UTC if empty)
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// HourlyBonus covers deliveries completed in [StartHour, EndHour), wrapping past midnight if EndHour < StartHour
+type HourlyBonus struct {
+	StartHour int   `json:"start_hour"`
+	EndHour   int   `json:"end_hour"`
+	Amount    int64 `json:"amount"`
+}
+
+// DailyTarget is a bonus for reaching a number of deliveries in one day
+type DailyTarget struct {
+	Deliveries int   `json:"deliveries"`
+	Amount     int64 `json:"amount"`
+}
+
+// Earning is one line a delivery adds to a courier's ledger
+type Earning struct {
+	Kind        string
+	Description string
+	Amount      int64
+}
+
+// DefaultPayRules is used for stores that have not configured their own
+var DefaultPayRules = PayRules{
+	Currency:    "EGP",
+	PerDelivery: 1500,
+	PerKm:       200,
+}
+
+// covers reports whether the bonus applies at the given local hour
+func (b HourlyBonus) covers(hour int) bool {
+	if b.StartHour <= b.EndHour {
+		return hour >= b.StartHour && hour < b.EndHour
+	}
+	return hour >= b.StartHour || hour < b.EndHour
+}
+
+// Location returns the time zone the rules count hours and days in
+func (p PayRules) Location() *time.Location {
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Validate checks that a store's pay rules are usable
+func (p PayRules) Validate() error {
+	if len(p.Currency) != 3 {
+		return errors.New("currency must be a 3-letter ISO code")
+	}
+	if p.PerDelivery < 0 || p.PerKm < 0 {
+		return errors.New("rates must not be negative")
+	}
+	for _, bonus := range p.HourlyBonuses {
+		if bonus.StartHour < 0 || bonus.StartHour > 23 || bonus.EndHour < 0 || bonus.EndHour > 24 || bonus.StartHour == bonus.EndHour {
+			return errors.New("hourly bonuses need distinct start and end hours between 0 and 24")
+		}
+		if bonus.Amount <= 0 {
+			return errors.New("bonus amounts must be positive")
+		}
+	}
+	for _, target := range p.DailyTargets {
+		if target.Deliveries < 1 || target.Amount <= 0 {
+			return errors.New("daily targets need a positive number of deliveries and amount")
+		}
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", p.Timezone)
+	}
+	return nil
+}
+
+// ForDelivery computes what a courier earns for one delivery of distanceKm completed at deliveredAt,
+// which was their deliveriesToday-th delivery of the day (counting this one)
+func (p PayRules) ForDelivery(distanceKm float64, deliveredAt time.Time, deliveriesToday int) []Earning {
+	// The delivery line is kept even when it pays nothing, as daily targets and summaries count deliveries by it
+	lines := []Earning{{Kind: KindDelivery, Description: "Delivery", Amount: p.PerDelivery}}
+	add := func(kind, description string, amount int64) {
+		if amount > 0 {
+			lines = append(lines, Earning{Kind: kind, Description: description, Amount: amount})
+		}
+	}
+
+	add(KindDistance, fmt.Sprintf("Distance (%.2f km)", distanceKm), int64(float64(p.PerKm)*distanceKm+0.5))
+
+	hour := deliveredAt.In(p.Location()).Hour()
+	for _, bonus := range p.HourlyBonuses {
+		if bonus.covers(hour) {
+			add(KindHourlyBonus, fmt.Sprintf("Hourly bonus (%02d:00-%02d:00)", bonus.StartHour, bonus.EndHour), bonus.Amount)
+		}
+	}
+	for _, target := range p.DailyTargets {
+		if target.Deliveries == deliveriesToday {
+			add(KindDailyTarget, fmt.Sprintf("Daily target (%d deliveries)", target.Deliveries), target.Amount)
+		}
+	}
+	return lines
+}
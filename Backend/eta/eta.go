@@ -0,0 +1,165 @@
+package eta
+
+import (
+	"PTS/geo"
+	"PTS/utils"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Where an estimate's speed came from
+const (
+	SourceHistory = "history"
+	SourceProfile = "profile"
+)
+
+// SpeedProfiles are typical city speeds in km/h per vehicle type, used until a store has enough history
+var SpeedProfiles = map[string]float64{
+	"bike":       14,
+	"motorcycle": 28,
+	"car":        22,
+	"van":        18,
+}
+
+// defaultSpeedKmh is used for vehicle types without a profile
+const defaultSpeedKmh = 20.0
+
+const (
+	// historyWindow is how far back a store's deliveries count towards its average speed
+	historyWindow = 30 * 24 * time.Hour
+	// minHistorySamples is how many deliveries a store needs before its average replaces the profile
+	minHistorySamples = 5
+	// Observations outside these speeds (km/h) are treated as bad data, like a delivery marked late
+	minObservedSpeed = 1.0
+	maxObservedSpeed = 120.0
+)
+
+// StopDwell is the time spent at the pickup collecting the package
+var StopDwell = utils.GetEnvDuration("ETA_STOP_DWELL", 5*time.Minute)
+
+// DefaultRouter measures distances for estimates, configured in main
+var DefaultRouter Router = StraightLine{DetourFactor: 1.3}
+
+// Input describes the delivery being estimated
+type Input struct {
+	StoreID string
+	Vehicle string
+	// Courier is the courier's last known position, if there is a courier and it has reported one
+	Courier *geo.Point
+	Pickup  geo.Point
+	DropOff geo.Point
+	// PickedUp is set once the courier has the package
+	PickedUp bool
+	// NotBefore is when the delivery window opens; the package is not delivered earlier
+	NotBefore *time.Time
+	Now       time.Time
+}
+
+// Estimate is when the courier should reach the pickup and drop-off
+type Estimate struct {
+	// PickupAt is only set while the courier is on the way to the pickup
+	PickupAt           *time.Time `json:"pickup_at,omitempty"`
+	PickupDistanceKm   *float64   `json:"pickup_distance_km,omitempty"`
+	DeliveryAt         time.Time  `json:"delivery_at"`
+	DeliveryDistanceKm float64    `json:"delivery_distance_km"`
+	SpeedKmh           float64    `json:"speed_kmh"`
+	// SpeedSource is "history" when the store's recent deliveries set the speed, otherwise "profile"
+	SpeedSource string    `json:"speed_source"`
+	ComputedAt  time.Time `json:"computed_at"`
+}
+
+// Compute estimates the pickup and delivery times
+func Compute(in Input) (Estimate, error) {
+	speed, source, err := Speed(in.StoreID, in.Vehicle)
+	if err != nil {
+		return Estimate{}, err
+	}
+	travel := func(km float64) time.Duration {
+		return time.Duration(km / speed * float64(time.Hour)).Round(time.Second)
+	}
+
+	estimate := Estimate{SpeedKmh: round1(speed), SpeedSource: source, ComputedAt: in.Now}
+	start, from := in.Now, in.Pickup
+	if in.PickedUp {
+		if in.Courier != nil {
+			from = *in.Courier
+		}
+	} else {
+		if in.Courier != nil {
+			distance, err := DefaultRouter.DistanceKm(*in.Courier, in.Pickup)
+			if err != nil {
+				return Estimate{}, err
+			}
+			pickupAt := in.Now.Add(travel(distance))
+			distance = round2(distance)
+			estimate.PickupAt, estimate.PickupDistanceKm = &pickupAt, &distance
+			start = pickupAt
+		}
+		start = start.Add(StopDwell)
+	}
+
+	distance, err := DefaultRouter.DistanceKm(from, in.DropOff)
+	if err != nil {
+		return Estimate{}, err
+	}
+	estimate.DeliveryDistanceKm = round2(distance)
+	estimate.DeliveryAt = start.Add(travel(distance))
+	if in.NotBefore != nil && estimate.DeliveryAt.Before(*in.NotBefore) {
+		estimate.DeliveryAt = *in.NotBefore
+	}
+	return estimate, nil
+}
+
+// Speed is the km/h to assume for the store's couriers on this vehicle: the store's recent average
+// once it has enough deliveries, otherwise the vehicle's profile
+func Speed(storeID, vehicle string) (float64, string, error) {
+	vehicle = strings.ToLower(strings.TrimSpace(vehicle))
+
+	var samples int
+	var distance, seconds sql.NullFloat64
+	query := `
+        SELECT COUNT(*), SUM(distance_km), SUM(duration_seconds)
+        FROM eta_observations
+        WHERE store_id = $1 AND vehicle_type = $2 AND observed_at > $3
+    `
+	err := utils.DB.QueryRow(query, storeID, vehicle, time.Now().Add(-historyWindow)).Scan(&samples, &distance, &seconds)
+	if err != nil {
+		return 0, "", err
+	}
+	if samples >= minHistorySamples && seconds.Float64 > 0 {
+		return distance.Float64 / seconds.Float64 * 3600, SourceHistory, nil
+	}
+
+	if speed, ok := SpeedProfiles[vehicle]; ok {
+		return speed, SourceProfile, nil
+	}
+	return defaultSpeedKmh, SourceProfile, nil
+}
+
+// Record adds a completed delivery leg to the store's history within tx. Implausible legs are ignored.
+func Record(tx *sql.Tx, storeID, orderID, vehicle string, distanceKm float64, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+	speed := distanceKm / duration.Hours()
+	if speed < minObservedSpeed || speed > maxObservedSpeed {
+		return nil
+	}
+
+	query := `
+        INSERT INTO eta_observations (store_id, order_id, vehicle_type, distance_km, duration_seconds, observed_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (order_id) DO NOTHING
+    `
+	_, err := tx.Exec(query, storeID, orderID, strings.ToLower(strings.TrimSpace(vehicle)), distanceKm, duration.Seconds(), time.Now())
+	return err
+}
+
+func round1(value float64) float64 {
+	return float64(int64(value*10+0.5)) / 10
+}
+
+func round2(value float64) float64 {
+	return float64(int64(value*100+0.5)) / 100
+}
@@ -0,0 +1,190 @@
+// Package eta estimates when a courier will reach an order's pickup and drop-off, from the courier's
+// position, road distance, the vehicle's speed and how fast the store's deliveries have actually been.
+package eta
+
+import (
+	"PTS/geo"
+	"container/heap"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Router measures the distance a courier travels between two points
+type Router interface {
+	DistanceKm(from, to geo.Point) (float64, error)
+}
+
+// StraightLine estimates road distance as the great-circle distance scaled by a detour factor
+type StraightLine struct {
+	DetourFactor float64
+}
+
+func (s StraightLine) DistanceKm(from, to geo.Point) (float64, error) {
+	factor := s.DetourFactor
+	if factor < 1 {
+		factor = 1
+	}
+	return geo.HaversineKm(from, to) * factor, nil
+}
+
+// ErrNoRoute is returned by Graph when the two points are not connected by its roads
+var ErrNoRoute = errors.New("no route between the points")
+
+// Graph routes along a road network. Each end is joined to the nearest node in a straight line,
+// and the shortest path between those nodes is found with Dijkstra's algorithm.
+type Graph struct {
+	nodes []geo.Point
+	edges [][]graphEdge
+	index map[geo.Point]int
+	// Fallback is used when the points are not connected, if set
+	Fallback Router
+}
+
+type graphEdge struct {
+	to     int
+	length float64
+}
+
+// NewGraph creates an empty road graph
+func NewGraph() *Graph {
+	return &Graph{index: map[geo.Point]int{}}
+}
+
+// AddRoad adds a straight road segment between two points, one-way from a to b if oneWay is set
+func (g *Graph) AddRoad(a, b geo.Point, oneWay bool) {
+	from, to := g.node(a), g.node(b)
+	length := geo.HaversineKm(a, b)
+	g.edges[from] = append(g.edges[from], graphEdge{to, length})
+	if !oneWay {
+		g.edges[to] = append(g.edges[to], graphEdge{from, length})
+	}
+}
+
+func (g *Graph) node(p geo.Point) int {
+	if i, ok := g.index[p]; ok {
+		return i
+	}
+	g.index[p] = len(g.nodes)
+	g.nodes = append(g.nodes, p)
+	g.edges = append(g.edges, nil)
+	return len(g.nodes) - 1
+}
+
+// LoadGraph reads road segments from CSV with the header from_lat,from_lng,to_lat,to_lng,one_way
+func LoadGraph(r io.Reader) (*Graph, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := NewGraph()
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		var coordinates [4]float64
+		for j := range coordinates {
+			if coordinates[j], err = strconv.ParseFloat(strings.TrimSpace(record[j]), 64); err != nil {
+				return nil, fmt.Errorf("road graph line %d: invalid coordinate", i+1)
+			}
+		}
+		a := geo.Point{Lat: coordinates[0], Lng: coordinates[1]}
+		b := geo.Point{Lat: coordinates[2], Lng: coordinates[3]}
+		if !a.Valid() || !b.Valid() {
+			return nil, fmt.Errorf("road graph line %d: invalid coordinate", i+1)
+		}
+		oneWay, _ := strconv.ParseBool(strings.TrimSpace(record[4]))
+		graph.AddRoad(a, b, oneWay)
+	}
+	if len(graph.nodes) == 0 {
+		return nil, errors.New("road graph is empty")
+	}
+	return graph, nil
+}
+
+// LoadGraphFile reads a road graph CSV from disk
+func LoadGraphFile(path string) (*Graph, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadGraph(file)
+}
+
+func (g *Graph) DistanceKm(from, to geo.Point) (float64, error) {
+	start, startGap := g.nearest(from)
+	end, endGap := g.nearest(to)
+	if start < 0 || end < 0 {
+		return g.fallback(from, to)
+	}
+
+	distances := make([]float64, len(g.nodes))
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
+	distances[start] = 0
+	queue := &nodeQueue{{start, 0}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queuedNode)
+		if current.distance > distances[current.node] {
+			continue
+		}
+		if current.node == end {
+			return startGap + current.distance + endGap, nil
+		}
+		for _, edge := range g.edges[current.node] {
+			if next := current.distance + edge.length; next < distances[edge.to] {
+				distances[edge.to] = next
+				heap.Push(queue, queuedNode{edge.to, next})
+			}
+		}
+	}
+	return g.fallback(from, to)
+}
+
+func (g *Graph) fallback(from, to geo.Point) (float64, error) {
+	if g.Fallback == nil {
+		return 0, ErrNoRoute
+	}
+	return g.Fallback.DistanceKm(from, to)
+}
+
+// nearest returns the node closest to p and how far away it is, or -1 for an empty graph
+func (g *Graph) nearest(p geo.Point) (int, float64) {
+	best, bestDistance := -1, math.Inf(1)
+	for i, node := range g.nodes {
+		if distance := geo.HaversineKm(p, node); distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best, bestDistance
+}
+
+type queuedNode struct {
+	node     int
+	distance float64
+}
+
+// nodeQueue is a min-heap of nodes by distance
+type nodeQueue []queuedNode
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].distance < q[j].distance }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queuedNode)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,163 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GeoJSON geometry types accepted for areas
+const (
+	TypePolygon      = "Polygon"
+	TypeMultiPolygon = "MultiPolygon"
+)
+
+// maxVertices caps the size of an area so containment checks stay cheap
+const maxVertices = 10000
+
+// km per degree of latitude, and of longitude at the equator
+const (
+	kmPerDegreeLat = 110.574
+	kmPerDegreeLng = 111.320
+)
+
+// Geometry is a GeoJSON Polygon or MultiPolygon. Positions are [longitude, latitude] and each ring must
+// be closed; the first ring of a polygon is its outline and any further rings are holes.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates" swaggertype:"array,number"`
+}
+
+// Ring is a closed loop of points
+type Ring []Point
+
+// Polygon is an outline followed by any holes
+type Polygon []Ring
+
+// Shape is the parsed form of a Geometry, one or more polygons
+type Shape []Polygon
+
+// Shape parses and validates the geometry
+func (g Geometry) Shape() (Shape, error) {
+	var polygons [][][][]float64
+	switch g.Type {
+	case TypePolygon:
+		var polygon [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, errors.New("polygon coordinates must be an array of rings of [lng, lat] positions")
+		}
+		polygons = [][][][]float64{polygon}
+	case TypeMultiPolygon:
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return nil, errors.New("multipolygon coordinates must be an array of polygons")
+		}
+	default:
+		return nil, errors.New("geometry type must be Polygon or MultiPolygon")
+	}
+	if len(polygons) == 0 {
+		return nil, errors.New("geometry has no polygons")
+	}
+
+	vertices := 0
+	shape := make(Shape, 0, len(polygons))
+	for _, rings := range polygons {
+		if len(rings) == 0 {
+			return nil, errors.New("polygon has no rings")
+		}
+		polygon := make(Polygon, 0, len(rings))
+		for _, positions := range rings {
+			if len(positions) < 4 {
+				return nil, errors.New("each ring needs at least four positions")
+			}
+			ring := make(Ring, 0, len(positions))
+			for _, position := range positions {
+				if len(position) < 2 {
+					return nil, errors.New("positions must be [lng, lat]")
+				}
+				point := Point{Lat: position[1], Lng: position[0]}
+				if !point.Valid() {
+					return nil, fmt.Errorf("position [%g, %g] is not a valid coordinate", position[0], position[1])
+				}
+				ring = append(ring, point)
+			}
+			if ring[0] != ring[len(ring)-1] {
+				return nil, errors.New("each ring must end at the position it starts from")
+			}
+			vertices += len(ring)
+			polygon = append(polygon, ring)
+		}
+		shape = append(shape, polygon)
+	}
+	if vertices > maxVertices {
+		return nil, fmt.Errorf("geometry has more than %d positions", maxVertices)
+	}
+	return shape, nil
+}
+
+// Contains reports whether the point is inside any polygon of the shape and outside its holes.
+// Points exactly on an edge may fall either way.
+func (s Shape) Contains(p Point) bool {
+	for _, polygon := range s {
+		if polygon[0].contains(p) {
+			inHole := false
+			for _, hole := range polygon[1:] {
+				if hole.contains(p) {
+					inHole = true
+					break
+				}
+			}
+			if !inHole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DistanceKm is how far the point is from the edge of the shape, or 0 if it is inside.
+// It uses a flat projection around the point, which is accurate enough at city scale.
+func (s Shape) DistanceKm(p Point) float64 {
+	if s.Contains(p) {
+		return 0
+	}
+	scaleLng := kmPerDegreeLng * math.Cos(p.Lat*math.Pi/180)
+	project := func(q Point) (float64, float64) {
+		return (q.Lng - p.Lng) * scaleLng, (q.Lat - p.Lat) * kmPerDegreeLat
+	}
+
+	best := math.Inf(1)
+	for _, polygon := range s {
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				ax, ay := project(ring[i])
+				bx, by := project(ring[i+1])
+				best = math.Min(best, originToSegment(ax, ay, bx, by))
+			}
+		}
+	}
+	return best
+}
+
+// contains is the even-odd ray casting test, run on raw degrees
+func (r Ring) contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// originToSegment is the distance from (0, 0) to the segment from a to b
+func originToSegment(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
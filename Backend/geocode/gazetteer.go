@@ -0,0 +1,214 @@
+package geocode
+
+import (
+	"PTS/geo"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed gazetteer.csv
+var builtinGazetteer string
+
+// Place is one row of a gazetteer: a district, or a city's centre when District is empty
+type Place struct {
+	CountryCode string
+	Country     string
+	Region      string
+	City        string
+	District    string
+	PostalCode  string
+	Point       geo.Point
+}
+
+// Gazetteer is an offline geocoder over a fixed list of places. It resolves addresses to the centre of
+// their district or city, which is enough for zones, pricing and ETAs without calling an outside service.
+type Gazetteer struct {
+	places []Place
+}
+
+// NewGazetteer creates a geocoder over the given places
+func NewGazetteer(places []Place) *Gazetteer {
+	return &Gazetteer{places: places}
+}
+
+// LoadGazetteer reads places from CSV with the header
+// country_code,country,region,city,district,postal_code,lat,lng
+func LoadGazetteer(r io.Reader) (*Gazetteer, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 8
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("gazetteer is empty")
+	}
+
+	var places []Place
+	for i, record := range records[1:] {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[6]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(record[7]), 64)
+		point := geo.Point{Lat: lat, Lng: lng}
+		if latErr != nil || lngErr != nil || !point.Valid() {
+			return nil, fmt.Errorf("gazetteer line %d: invalid coordinates", i+2)
+		}
+		places = append(places, Place{
+			CountryCode: strings.TrimSpace(record[0]),
+			Country:     strings.TrimSpace(record[1]),
+			Region:      strings.TrimSpace(record[2]),
+			City:        strings.TrimSpace(record[3]),
+			District:    strings.TrimSpace(record[4]),
+			PostalCode:  strings.TrimSpace(record[5]),
+			Point:       point,
+		})
+	}
+	return NewGazetteer(places), nil
+}
+
+// LoadGazetteerFile reads a gazetteer CSV from disk
+func LoadGazetteerFile(path string) (*Gazetteer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadGazetteer(file)
+}
+
+// mustLoadBuiltin loads the gazetteer shipped with the API, covering the cities PTS delivers in
+func mustLoadBuiltin() *Gazetteer {
+	gazetteer, err := LoadGazetteer(strings.NewReader(builtinGazetteer))
+	if err != nil {
+		panic("geocode: invalid built-in gazetteer: " + err.Error())
+	}
+	return gazetteer
+}
+
+// Geocode matches the address's city (or region) and, where it can, its district by name or postal code
+func (g *Gazetteer) Geocode(address Address) (Address, error) {
+	city := normalize(address.City)
+	country := normalize(address.Country)
+	district := normalize(address.District)
+	street := normalize(address.Line1 + " " + address.Line2)
+
+	var centre, best *Place
+	for i := range g.places {
+		place := &g.places[i]
+		if country != "" && country != normalize(place.CountryCode) && country != normalize(place.Country) {
+			continue
+		}
+		if city != normalize(place.City) && city != normalize(place.Region) {
+			continue
+		}
+		if place.District == "" {
+			if centre == nil || normalize(place.City) == city {
+				centre = place
+			}
+			continue
+		}
+
+		name := normalize(place.District)
+		matched := name == district || containsPhrase(street, name) ||
+			(address.PostalCode != "" && normalize(address.PostalCode) == normalize(place.PostalCode))
+		if matched && (best == nil || len(place.District) > len(best.District)) {
+			best = place
+		}
+	}
+
+	match, precision := best, PrecisionDistrict
+	if match == nil {
+		match, precision = centre, PrecisionCity
+	}
+	if match == nil {
+		return Address{}, ErrNotFound
+	}
+
+	resolved := address
+	resolved.City, resolved.Region, resolved.Country = match.City, match.Region, match.CountryCode
+	if best != nil {
+		resolved.District = best.District
+		if resolved.PostalCode == "" {
+			resolved.PostalCode = best.PostalCode
+		}
+	}
+
+	if address.Location != nil {
+		if geo.HaversineKm(*address.Location, match.Point) > MaxPinDistanceKm {
+			return Address{}, ErrLocationMismatch
+		}
+		resolved.Precision = PrecisionPin
+		return resolved, nil
+	}
+
+	point := match.Point
+	resolved.Location, resolved.Precision = &point, precision
+	return resolved, nil
+}
+
+// Search scores places by the district, city, region and postal code named in the text
+func (g *Gazetteer) Search(text string, limit int) ([]Address, error) {
+	query := normalize(text)
+	if query == "" || limit <= 0 {
+		return nil, nil
+	}
+
+	type scored struct {
+		place *Place
+		score int
+	}
+	var matches []scored
+	for i := range g.places {
+		place := &g.places[i]
+		score := 0
+		if containsPhrase(query, normalize(place.District)) {
+			score += 3
+		}
+		if containsPhrase(query, normalize(place.PostalCode)) {
+			score += 3
+		}
+		if containsPhrase(query, normalize(place.City)) {
+			score += 2
+		}
+		if region := normalize(place.Region); region != normalize(place.City) && containsPhrase(query, region) {
+			score++
+		}
+		// A city on its own names the city, not every district in it
+		if score > 0 && (place.District == "" || score > 2) {
+			matches = append(matches, scored{place, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	addresses := make([]Address, 0, len(matches))
+	for _, match := range matches {
+		point := match.place.Point
+		precision := PrecisionDistrict
+		if match.place.District == "" {
+			precision = PrecisionCity
+		}
+		addresses = append(addresses, Address{
+			Line1:      strings.TrimSpace(text),
+			District:   match.place.District,
+			City:       match.place.City,
+			Region:     match.place.Region,
+			PostalCode: match.place.PostalCode,
+			Country:    match.place.CountryCode,
+			Location:   &point,
+			Precision:  precision,
+		})
+	}
+	return addresses, nil
+}
@@ -0,0 +1,107 @@
+// Package geocode turns the addresses customers type into structured addresses with coordinates.
+// Orders keep a snapshot of the resolved address, so later edits to the address book do not change them.
+package geocode
+
+import (
+	"PTS/geo"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Precisions of a resolved address's location
+const (
+	PrecisionPin      = "pin"
+	PrecisionDistrict = "district"
+	PrecisionCity     = "city"
+)
+
+// maxFieldLength caps each free-text address field
+const maxFieldLength = 200
+
+// MaxPinDistanceKm is how far a location pinned by the customer may be from the city it claims to be in
+const MaxPinDistanceKm = 50.0
+
+var (
+	// ErrNotFound is returned when an address does not match any known place
+	ErrNotFound = errors.New("address not found")
+	// ErrLocationMismatch is returned when a pinned location is nowhere near the address's city
+	ErrLocationMismatch = errors.New("location is too far from the address's city")
+)
+
+// Address is a structured postal address. Location and Precision are filled in by a Geocoder.
+type Address struct {
+	Line1      string     `json:"line1"`
+	Line2      string     `json:"line2,omitempty"`
+	District   string     `json:"district,omitempty"`
+	City       string     `json:"city"`
+	Region     string     `json:"region,omitempty"`
+	PostalCode string     `json:"postal_code,omitempty"`
+	Country    string     `json:"country,omitempty"`
+	Location   *geo.Point `json:"location,omitempty"`
+	// Precision says how the location was found: pinned by the customer, or the centre of the district or city
+	Precision string `json:"precision,omitempty"`
+}
+
+// Validate checks the address has the fields needed to geocode it
+func (a Address) Validate() error {
+	if strings.TrimSpace(a.Line1) == "" || strings.TrimSpace(a.City) == "" {
+		return errors.New("line1 and city are required")
+	}
+	for _, field := range []string{a.Line1, a.Line2, a.District, a.City, a.Region, a.PostalCode, a.Country} {
+		if len(field) > maxFieldLength {
+			return errors.New("address fields must be at most 200 characters")
+		}
+	}
+	if a.Location != nil && !a.Location.Valid() {
+		return errors.New("location must have lat between -90 and 90 and lng between -180 and 180")
+	}
+	return nil
+}
+
+// String formats the address on one line, as stored in an order's pickup and drop-off locations
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Line1, a.Line2, a.District, a.City} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Geocoder resolves addresses to coordinates
+type Geocoder interface {
+	// Geocode validates a structured address and fills in its location and canonical place names.
+	// It returns ErrNotFound if the city is unknown.
+	Geocode(address Address) (Address, error)
+	// Search finds the addresses best matching free text such as "12 Road 9, Maadi, Cairo", best first
+	Search(text string, limit int) ([]Address, error)
+}
+
+// Default is the geocoder used by the API, configured in main
+var Default Geocoder = mustLoadBuiltin()
+
+// normalize lowercases text and reduces punctuation to single spaces so place names compare loosely
+func normalize(text string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// containsPhrase reports whether the normalized phrase appears in text as whole words
+func containsPhrase(text, phrase string) bool {
+	if phrase == "" {
+		return false
+	}
+	return strings.Contains(" "+text+" ", " "+phrase+" ")
+}
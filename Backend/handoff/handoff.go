@@ -0,0 +1,203 @@
+// Package handoff issues per-order delivery codes that the customer gives the courier at the door,
+// so an order can only be marked delivered once the code was checked or an admin overrode it.
+package handoff
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// CodeDigits is the length of generated delivery codes, and MaxAttempts the number of wrong codes
+// a courier may submit before the code is locked and needs an admin override
+var (
+	CodeDigits  = utils.GetEnvInt("DELIVERY_CODE_DIGITS", 6)
+	MaxAttempts = utils.GetEnvInt("DELIVERY_CODE_MAX_ATTEMPTS", 5)
+)
+
+var (
+	// ErrCodeRequired is returned when no code was submitted for an order that has one
+	ErrCodeRequired = errors.New("delivery code is required")
+	// ErrLocked is returned once all attempts are used up
+	ErrLocked = errors.New("too many wrong delivery codes; ask the store to override")
+	// ErrNotCleared is returned when delivering an order whose code was neither verified nor overridden
+	ErrNotCleared = errors.New("delivery code has not been verified")
+	// ErrNoCode is returned when overriding or viewing the code of an order that has none
+	ErrNoCode = errors.New("order has no delivery code")
+)
+
+// MismatchError is returned for a wrong code, with the attempts left before the code locks
+type MismatchError struct {
+	Remaining int
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("wrong delivery code, %d attempts left", e.Remaining)
+}
+
+// Generate creates the order's delivery code within the transaction that places it
+func Generate(tx *sql.Tx, orderID string) error {
+	code, err := randomCode(CodeDigits)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO delivery_codes (order_id, code, created_at) VALUES ($1, $2, $3)", orderID, code, time.Now())
+	return err
+}
+
+// Verify checks a code submitted by the courier. Wrong codes are counted even though the delivery fails,
+// so the check runs in its own transaction. It reports whether the code was verified by this or an
+// earlier submission; orders cleared by an override, or placed before delivery codes existed, need no code.
+func Verify(orderID, code string) (bool, error) {
+	verified := false
+	// A wrong code is reported only after the transaction commits so that the attempt is kept
+	var mismatch error
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		var stored string
+		var attempts int
+		var verifiedAt, overriddenAt *time.Time
+		query := "SELECT code, attempts, verified_at, overridden_at FROM delivery_codes WHERE order_id = $1 FOR UPDATE"
+		err := tx.QueryRow(query, orderID).Scan(&stored, &attempts, &verifiedAt, &overriddenAt)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if verifiedAt != nil {
+			verified = true
+			return nil
+		}
+		if overriddenAt != nil {
+			return nil
+		}
+		if attempts >= MaxAttempts {
+			return ErrLocked
+		}
+		if code == "" {
+			return ErrCodeRequired
+		}
+
+		if subtle.ConstantTimeCompare([]byte(code), []byte(stored)) != 1 {
+			attempts++
+			if _, err := tx.Exec("UPDATE delivery_codes SET attempts = $1 WHERE order_id = $2", attempts, orderID); err != nil {
+				return err
+			}
+			if attempts >= MaxAttempts {
+				mismatch = ErrLocked
+			} else {
+				mismatch = &MismatchError{Remaining: MaxAttempts - attempts}
+			}
+			return nil
+		}
+
+		if _, err := tx.Exec("UPDATE delivery_codes SET verified_at = $1 WHERE order_id = $2", time.Now(), orderID); err != nil {
+			return err
+		}
+		verified = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return verified, mismatch
+}
+
+// RequireCleared returns ErrNotCleared unless the order's code was verified or overridden.
+// It is called within the transaction that marks the order delivered.
+func RequireCleared(tx *sql.Tx, orderID string) error {
+	var cleared bool
+	query := "SELECT verified_at IS NOT NULL OR overridden_at IS NOT NULL FROM delivery_codes WHERE order_id = $1"
+	err := tx.QueryRow(query, orderID).Scan(&cleared)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		return ErrNotCleared
+	}
+	return nil
+}
+
+// Override lets store staff clear an order for delivery without its code, recording who did it and why
+func Override(orderID string, identity *models.Identity, reason string) error {
+	return utils.WithTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		result, err := tx.Exec("UPDATE delivery_codes SET overridden_at = $1 WHERE order_id = $2 AND verified_at IS NULL", now, orderID)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM delivery_codes WHERE order_id = $1)", orderID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNoCode
+			}
+		}
+
+		query := `
+            INSERT INTO delivery_code_overrides (order_id, overridden_by, role, reason, created_at)
+            VALUES ($1, $2, $3, $4, $5)
+        `
+		_, err = tx.Exec(query, orderID, identity.UserID, identity.Role, reason, now)
+		return err
+	})
+}
+
+// Get returns the order's delivery code state, including the latest override. The code itself is
+// only included when withCode is set.
+func Get(orderID string, withCode bool) (*models.DeliveryCode, error) {
+	dc := models.DeliveryCode{OrderID: orderID, MaxAttempts: MaxAttempts}
+	var code string
+	query := `
+        SELECT d.code, d.attempts, d.verified_at, d.overridden_at, o.overridden_by, o.reason, d.created_at
+        FROM delivery_codes d
+        LEFT JOIN LATERAL (
+            SELECT overridden_by::text, reason FROM delivery_code_overrides
+            WHERE order_id = d.order_id ORDER BY created_at DESC LIMIT 1
+        ) o ON TRUE
+        WHERE d.order_id = $1
+    `
+	err := utils.DB.QueryRow(query, orderID).Scan(&code, &dc.Attempts, &dc.VerifiedAt, &dc.OverriddenAt,
+		&dc.OverriddenBy, &dc.OverrideReason, &dc.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dc.Locked = dc.VerifiedAt == nil && dc.OverriddenAt == nil && dc.Attempts >= MaxAttempts
+	if withCode {
+		dc.Code = &code
+	}
+	return &dc, nil
+}
+
+// randomCode returns a uniformly random string of decimal digits
+func randomCode(digits int) (string, error) {
+	if digits < 4 {
+		digits = 4
+	}
+	code := make([]byte, digits)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
@@ -0,0 +1,130 @@
+// Package hub is an in-process publish/subscribe hub used to push order events to live subscribers.
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published for orders
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status_changed"
+	OrderAssigned      = "order.assigned"
+	OrderLocation      = "order.location"
+	OrderETA           = "order.eta"
+)
+
+// subscriberBuffer is how many events a slow subscriber can fall behind before events are dropped
+const subscriberBuffer = 64
+
+// Event is a single update about an order
+type Event struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	OrderID string      `json:"order_id"`
+	StoreId string      `json:"store_id"`
+	Data    interface{} `json:"data,omitempty"`
+	At      time.Time   `json:"at"`
+}
+
+// Hub fans events out to subscribers of order and store topics
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives events for the topics it subscribed to until it is closed
+type Subscription struct {
+	C <-chan Event
+	// Lost is closed once an event has been dropped because the subscriber fell too far behind. The events it
+	// receives after that are incomplete, so it should start over from the current state.
+	Lost <-chan struct{}
+
+	ch       chan Event
+	lost     chan struct{}
+	lostOnce sync.Once
+	hub      *Hub
+	topics   []string
+	once     sync.Once
+}
+
+// Default is the hub shared by the whole API
+var Default = New()
+
+// New creates an empty hub
+func New() *Hub {
+	return &Hub{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+// OrderTopic is the topic carrying every event for one order
+func OrderTopic(orderID string) string {
+	return "order:" + orderID
+}
+
+// StoreTopic is the topic carrying every event for the orders of one store
+func StoreTopic(storeID string) string {
+	return "store:" + storeID
+}
+
+// Subscribe registers a new subscription to the given topics
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	lost := make(chan struct{})
+	sub := &Subscription{C: ch, Lost: lost, ch: ch, lost: lost, hub: h, topics: topics}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Subscription]struct{})
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+
+	return sub
+}
+
+// Publish delivers an event to subscribers of its order and store topics. It never blocks:
+// subscribers whose buffer is full miss the event, and their Lost channel is closed.
+func (h *Hub) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// A subscriber to both topics should only see the event once
+	delivered := make(map[*Subscription]struct{})
+	for _, topic := range []string{OrderTopic(event.OrderID), StoreTopic(event.StoreId)} {
+		for sub := range h.topics[topic] {
+			if _, ok := delivered[sub]; ok {
+				continue
+			}
+			delivered[sub] = struct{}{}
+
+			select {
+			case sub.ch <- event:
+			default:
+				sub.lostOnce.Do(func() { close(sub.lost) })
+			}
+		}
+	}
+}
+
+// Close unsubscribes from all topics and closes the event channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		defer s.hub.mu.Unlock()
+
+		for _, topic := range s.topics {
+			delete(s.hub.topics[topic], s)
+			if len(s.hub.topics[topic]) == 0 {
+				delete(s.hub.topics, topic)
+			}
+		}
+		close(s.ch)
+	})
+}
@@ -0,0 +1,198 @@
+// Package invoices issues numbered invoices for delivered orders and monthly statements per store.
+package invoices
+
+import (
+	"PTS/models"
+	"PTS/pricing"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+const invoiceColumns = "id, store_id, order_id, user_id, number, invoice_number, currency, lines, subtotal, discount, tax_lines, tax_total, total, issued_at"
+
+// TaxName and TaxRatePercent describe the sales tax included in delivery prices
+var (
+	TaxName        = utils.GetEnv("INVOICE_TAX_NAME", "VAT")
+	TaxRatePercent = taxRateFromEnv()
+)
+
+func taxRateFromEnv() float64 {
+	value := utils.GetEnv("INVOICE_TAX_RATE_PERCENT", "14")
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		log.Printf("Invalid INVOICE_TAX_RATE_PERCENT (%q), using 14", value)
+		return 14
+	}
+	return rate
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Issue creates the invoice for a delivered order within the transaction that marks it delivered,
+// taking the store's next invoice number. Orders placed without a price get a zero-priced invoice in the
+// currency of the store's rate card, so every delivery is numbered. Orders that already have an invoice are skipped.
+func Issue(tx *sql.Tx, order *models.Order) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM invoices WHERE order_id = $1)", order.ID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	var subtotal int64
+	var currency string
+	if order.PriceTotal != nil && order.PriceCurrency != nil {
+		subtotal, currency = *order.PriceTotal, *order.PriceCurrency
+	} else {
+		card, err := pricing.StoreProvider{}.CurrentRateCard(order.StoreId)
+		if err != nil {
+			return err
+		}
+		currency = card.Currency
+	}
+
+	lines, err := quotedLines(tx, order, subtotal)
+	if err != nil {
+		return err
+	}
+
+	var discount int64
+	if order.DiscountTotal != nil {
+		discount = *order.DiscountTotal
+	}
+	total := subtotal - discount
+	taxLines := []models.TaxLine{{
+		Name:        TaxName,
+		RatePercent: TaxRatePercent,
+		Amount:      int64(math.Round(float64(total) * TaxRatePercent / (100 + TaxRatePercent))),
+		Included:    true,
+	}}
+
+	// Numbers are sequential per store; the counter row is locked until the order's transaction commits
+	var number int64
+	counterQuery := `
+        INSERT INTO invoice_counters (store_id, last_number) VALUES ($1, 1)
+        ON CONFLICT (store_id) DO UPDATE SET last_number = invoice_counters.last_number + 1
+        RETURNING last_number
+    `
+	if err := tx.QueryRow(counterQuery, order.StoreId).Scan(&number); err != nil {
+		return err
+	}
+
+	linesJSON, err := json.Marshal(lines)
+	if err != nil {
+		return err
+	}
+	taxJSON, err := json.Marshal(taxLines)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO invoices (store_id, order_id, user_id, number, invoice_number, currency, lines, subtotal, discount, tax_lines, tax_total, total, issued_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    `
+	_, err = tx.Exec(query, order.StoreId, order.ID, order.UserID, number, fmt.Sprintf("INV-%06d", number), currency,
+		linesJSON, subtotal, discount, taxJSON, taxLines[0].Amount, total, time.Now())
+	return err
+}
+
+// quotedLines itemises the order's price from its quote, or as a single line of subtotal if the quote has no breakdown
+func quotedLines(tx *sql.Tx, order *models.Order, subtotal int64) ([]models.InvoiceLine, error) {
+	lines := []models.InvoiceLine{}
+	if order.QuoteID != nil {
+		var priceJSON []byte
+		err := tx.QueryRow("SELECT price FROM price_quotes WHERE id = $1", *order.QuoteID).Scan(&priceJSON)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if priceJSON != nil {
+			var price pricing.Price
+			if err := json.Unmarshal(priceJSON, &price); err != nil {
+				return nil, err
+			}
+			for _, line := range price.Lines {
+				lines = append(lines, models.InvoiceLine{Description: line.Label, Amount: line.Amount})
+			}
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, models.InvoiceLine{Description: "Delivery", Amount: subtotal})
+	}
+	return lines, nil
+}
+
+// ForOrder returns the order's invoice with its current payment details, or sql.ErrNoRows
+func ForOrder(orderID string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	query := "SELECT " + invoiceColumns + " FROM invoices WHERE order_id = $1"
+	if err := scanInvoice(utils.DB.QueryRow(query, orderID), &invoice); err != nil {
+		return nil, err
+	}
+
+	paymentQuery := "SELECT method, status FROM payments WHERE order_id = $1 ORDER BY created_at DESC LIMIT 1"
+	err := utils.DB.QueryRow(paymentQuery, orderID).Scan(&invoice.PaymentMethod, &invoice.PaymentStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// Statement totals the invoices a store issued in the month starting at month
+func Statement(storeID string, month time.Time) (*models.StoreStatement, error) {
+	statement := models.StoreStatement{
+		StoreId:  storeID,
+		Month:    month.Format("2006-01"),
+		Invoices: []models.StatementInvoice{},
+	}
+
+	query := `
+        SELECT invoice_number, order_id, currency, subtotal, discount, tax_total, total, issued_at
+        FROM invoices
+        WHERE store_id = $1 AND issued_at >= $2 AND issued_at < $3
+        ORDER BY number
+    `
+	rows, err := utils.DB.Query(query, storeID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.StatementInvoice
+		var subtotal, discount int64
+		err := rows.Scan(&line.InvoiceNumber, &line.OrderID, &statement.Currency, &subtotal, &discount, &line.TaxTotal, &line.Total, &line.IssuedAt)
+		if err != nil {
+			return nil, err
+		}
+		statement.Subtotal += subtotal
+		statement.Discount += discount
+		statement.TaxTotal += line.TaxTotal
+		statement.Total += line.Total
+		statement.Invoices = append(statement.Invoices, line)
+	}
+	statement.InvoiceCount = len(statement.Invoices)
+	return &statement, rows.Err()
+}
+
+func scanInvoice(row rowScanner, invoice *models.Invoice) error {
+	var linesJSON, taxJSON []byte
+	err := row.Scan(&invoice.ID, &invoice.StoreId, &invoice.OrderID, &invoice.UserID, &invoice.Number, &invoice.InvoiceNumber,
+		&invoice.Currency, &linesJSON, &invoice.Subtotal, &invoice.Discount, &taxJSON, &invoice.TaxTotal, &invoice.Total, &invoice.IssuedAt)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(linesJSON, &invoice.Lines); err != nil {
+		return err
+	}
+	return json.Unmarshal(taxJSON, &invoice.TaxLines)
+}
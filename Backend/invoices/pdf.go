@@ -0,0 +1,95 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF page layout, in points on an A4 page
+const (
+	pageWidth     = 595
+	pageHeight    = 842
+	pageMargin    = 50
+	lineHeight    = 14
+	fontSize      = 10
+	titleSize     = 16
+	linesPerPage  = (pageHeight - 2*pageMargin - 2*lineHeight) / lineHeight
+	pdfObjectsPer = 2 // page and content stream objects per page
+)
+
+// renderPDF lays out a title and lines of monospaced text as an A4 PDF document, adding pages as needed.
+// Only the standard Courier font is used, so the file needs no embedded fonts; characters outside
+// Latin-1 are replaced with "?".
+func renderPDF(title string, lines []string) []byte {
+	var pages [][]string
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	var buf bytes.Buffer
+	offsets := []int{}
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1: catalog, 2: page tree, 3: fonts, then a page and content object per page
+	firstPage := 4
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*pdfObjectsPer)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	writeObject("<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >> " +
+		"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>")
+
+	for i, pageLines := range pages {
+		var content bytes.Buffer
+		y := pageHeight - pageMargin
+		if i == 0 {
+			fmt.Fprintf(&content, "BT /F2 %d Tf %d %d Td (%s) Tj ET\n", titleSize, pageMargin, y, escapePDF(title))
+		}
+		y -= 2 * lineHeight
+		content.WriteString(fmt.Sprintf("BT /F1 %d Tf %d TL %d %d Td\n", fontSize, lineHeight, pageMargin, y))
+		for _, line := range pageLines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDF(line))
+		}
+		fmt.Fprintf(&content, "ET\nBT /F1 8 Tf %d %d Td (Page %d of %d) Tj ET\n", pageMargin, pageMargin/2, i+1, len(pages))
+
+		contentID := firstPage + i*pdfObjectsPer + 1
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font 3 0 R >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, contentID))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// escapePDF makes text safe to place in a PDF string literal
+func escapePDF(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
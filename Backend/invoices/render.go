@@ -0,0 +1,84 @@
+package invoices
+
+import (
+	"PTS/models"
+	"fmt"
+	"strings"
+)
+
+// amountWidth is the column the amounts of invoice lines are right-aligned to
+const amountWidth = 72
+
+// InvoicePDF renders an invoice, or a receipt once it has been paid, as a PDF document
+func InvoicePDF(invoice *models.Invoice) []byte {
+	title := "Invoice " + invoice.InvoiceNumber
+	if invoice.PaymentStatus != nil && *invoice.PaymentStatus == "captured" {
+		title = "Receipt " + invoice.InvoiceNumber
+	}
+
+	lines := []string{
+		"Issued:   " + invoice.IssuedAt.Format("2006-01-02 15:04 MST"),
+		"Order:    " + invoice.OrderID,
+		"Store:    " + invoice.StoreId,
+		"",
+	}
+	for _, line := range invoice.Lines {
+		lines = append(lines, amountLine(line.Description, line.Amount, invoice.Currency))
+	}
+	lines = append(lines, strings.Repeat("-", amountWidth))
+	lines = append(lines, amountLine("Subtotal", invoice.Subtotal, invoice.Currency))
+	if invoice.Discount != 0 {
+		lines = append(lines, amountLine("Discount", -invoice.Discount, invoice.Currency))
+	}
+	lines = append(lines, amountLine("Total", invoice.Total, invoice.Currency))
+	for _, tax := range invoice.TaxLines {
+		label := fmt.Sprintf("%s %g%%", tax.Name, tax.RatePercent)
+		if tax.Included {
+			label += " (included)"
+		}
+		lines = append(lines, amountLine(label, tax.Amount, invoice.Currency))
+	}
+
+	if invoice.PaymentMethod != nil {
+		lines = append(lines, "", "Payment:  "+*invoice.PaymentMethod+" ("+*invoice.PaymentStatus+")")
+	}
+	return renderPDF(title, lines)
+}
+
+// StatementPDF renders a store's monthly statement as a PDF document
+func StatementPDF(statement *models.StoreStatement) []byte {
+	lines := []string{
+		"Store:    " + statement.StoreId,
+		fmt.Sprintf("Invoices: %d", statement.InvoiceCount),
+		"",
+	}
+	for _, invoice := range statement.Invoices {
+		label := invoice.InvoiceNumber + "  " + invoice.IssuedAt.Format("2006-01-02") + "  " + invoice.OrderID
+		lines = append(lines, amountLine(label, invoice.Total, statement.Currency))
+	}
+	lines = append(lines, strings.Repeat("-", amountWidth))
+	lines = append(lines, amountLine("Subtotal", statement.Subtotal, statement.Currency))
+	lines = append(lines, amountLine("Discounts", -statement.Discount, statement.Currency))
+	lines = append(lines, amountLine("Total", statement.Total, statement.Currency))
+	lines = append(lines, amountLine(TaxName+" included", statement.TaxTotal, statement.Currency))
+	return renderPDF("Statement "+statement.Month, lines)
+}
+
+// amountLine right-aligns a formatted amount after a label
+func amountLine(label string, amount int64, currency string) string {
+	value := FormatAmount(amount, currency)
+	padding := amountWidth - len(label) - len(value)
+	if padding < 1 {
+		padding = 1
+	}
+	return label + strings.Repeat(" ", padding) + value
+}
+
+// FormatAmount writes an amount in minor units with two decimals, e.g. 12345 EGP as "123.45 EGP"
+func FormatAmount(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, currency)
+}
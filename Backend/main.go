@@ -2,11 +2,23 @@ package main
 
 import (
 	UserAPIs "PTS/APIs"
+	"PTS/blobstore"
+	"PTS/controllers"
+	"PTS/eta"
+	"PTS/geocode"
+	"PTS/hub"
+	"PTS/notifications"
+	"PTS/outbox"
+	"PTS/payments"
+	"PTS/recurring"
 	"PTS/utils"
+	"PTS/webhooks"
+	"PTS/workers"
 	"fmt"
 	"log"
 	"net/http"
 	"os/exec"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -23,17 +35,84 @@ import (
 // @license.url     Project Repo link
 // @host            localhost:8080
 // @BasePath        /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
 	fmt.Println("Starting the server...")
 
 	// Connect to the database
 	utils.ConnectDB()
+	utils.EnsureSchema()
+
+	// Mark couriers unavailable when they stop sending heartbeats
+	courierIdleTimeout := utils.GetEnvDuration("COURIER_IDLE_TIMEOUT", 10*time.Minute)
+	go workers.StartCourierIdleSweeper(time.Minute, courierIdleTimeout)
+
+	// Purge courier location pings past their retention period
+	go workers.StartLocationRetentionWorker(time.Hour, controllers.LocationPingRetention)
+
+	// Relay order events from the outbox to live subscribers, webhooks, notifications and the log
+	relay := outbox.NewRelay(outbox.HubSink{}, outbox.WebhookSink{}, outbox.NotificationSink{}, outbox.InboxSink{}, outbox.LogSink{})
+	go relay.Run(2 * time.Second)
+
+	// Whichever instance relays an event, every instance pushes it to its own live subscribers
+	go outbox.ListenHub(hub.Default)
+
+	// Send queued customer notifications and retry failed ones
+	go notifications.NewDispatcher(newNotifiers()...).Run(5 * time.Second)
+
+	// Send queued webhook deliveries and retry failed ones
+	go webhooks.NewDispatcher().Run(5 * time.Second)
+
+	// Payment providers. Card payments go to PAYMENT_GATEWAY_URL, which defaults to the local mock gateway (cmd/mockgateway).
+	payments.Register(payments.CashOnDelivery{})
+	payments.Register(payments.NewCardGatewayProvider(
+		utils.GetEnv("PAYMENT_GATEWAY_URL", "http://localhost:8090"),
+		utils.GetEnv("PAYMENT_GATEWAY_API_KEY", ""),
+	))
+
+	// Void or refund the payments of cancelled orders that could not be released straight away
+	go workers.StartPaymentSettlementWorker(time.Minute)
+
+	// Attachments are kept on local disk under BLOB_STORAGE_DIR, or with BLOB_STORE=s3 in an S3-compatible
+	// bucket (the local stand-in is cmd/mocks3). Orphaned blobs are collected once BLOB_GC_GRACE has passed.
+	blobstore.Default = newBlobStore()
+	if secret := utils.GetEnv("BLOB_URL_SECRET", ""); secret != "" {
+		blobstore.URLSecret = []byte(secret)
+	}
+	go workers.StartBlobGC(time.Hour, utils.GetEnvDuration("BLOB_GC_GRACE", 24*time.Hour))
+
+	// Addresses are geocoded offline against the built-in gazetteer, or the CSV at GEOCODER_GAZETTEER
+	if path := utils.GetEnv("GEOCODER_GAZETTEER", ""); path != "" {
+		gazetteer, err := geocode.LoadGazetteerFile(path)
+		if err != nil {
+			log.Fatal("Error loading gazetteer: ", err)
+		}
+		geocode.Default = gazetteer
+	}
+
+	// ETAs measure road distance on the graph at ETA_ROAD_GRAPH if set, otherwise the straight line with a detour factor
+	if path := utils.GetEnv("ETA_ROAD_GRAPH", ""); path != "" {
+		graph, err := eta.LoadGraphFile(path)
+		if err != nil {
+			log.Fatal("Error loading road graph: ", err)
+		}
+		graph.Fallback = eta.DefaultRouter
+		eta.DefaultRouter = graph
+	}
+
+	// Place upcoming occurrences of recurring orders RECURRING_ORDER_LEAD_DAYS ahead of their delivery date
+	go recurring.NewScheduler(controllers.PlaceRecurringOrder, outbox.Notify).Run(time.Minute)
 
 	// Initialize the router
 	router := mux.NewRouter()
 
-	// Wrap the router with CORS middleware
-	handler := cors.Default().Handler(router)
+	// Wrap the router with CORS middleware, allowing authenticated and non-GET/POST requests
+	handler := cors.New(cors.Options{
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "Authorization", "Idempotency-Key"},
+	}).Handler(router)
 
 	// Register API routes
 	UserAPIs.RegisterAuthRoutes(router)
@@ -82,3 +161,58 @@ func main() {
 	log.Println("Server running on port 8080")
 	log.Fatal(http.ListenAndServe(":8080", handler))
 }
+
+// newNotifiers configures the notification channels from the environment. Email and SMS are
+// written to the console (or NOTIFY_DEV_LOG_FILE) unless an SMTP server or SMS gateway is set.
+func newNotifiers() []notifications.Notifier {
+	notifiers := []notifications.Notifier{&notifications.InAppNotifier{}}
+	devLogFile := utils.GetEnv("NOTIFY_DEV_LOG_FILE", "")
+
+	if host := utils.GetEnv("NOTIFY_SMTP_HOST", ""); host != "" {
+		notifiers = append(notifiers, &notifications.EmailNotifier{
+			Host:     host,
+			Port:     utils.GetEnv("NOTIFY_SMTP_PORT", "587"),
+			Username: utils.GetEnv("NOTIFY_SMTP_USERNAME", ""),
+			Password: utils.GetEnv("NOTIFY_SMTP_PASSWORD", ""),
+			From:     utils.GetEnv("NOTIFY_SMTP_FROM", "no-reply@pts.local"),
+		})
+	} else if console, err := notifications.NewConsoleNotifier(notifications.ChannelEmail, devLogFile); err == nil {
+		notifiers = append(notifiers, console)
+	} else {
+		log.Fatal("Error opening notification log file: ", err)
+	}
+
+	if gateway := utils.GetEnv("NOTIFY_SMS_GATEWAY_URL", ""); gateway != "" {
+		notifiers = append(notifiers, notifications.NewSMSGatewayNotifier(gateway, utils.GetEnv("NOTIFY_SMS_API_KEY", "")))
+	} else if console, err := notifications.NewConsoleNotifier(notifications.ChannelSMS, devLogFile); err == nil {
+		notifiers = append(notifiers, console)
+	} else {
+		log.Fatal("Error opening notification log file: ", err)
+	}
+
+	return notifiers
+}
+
+// newBlobStore configures attachment storage from the environment: local disk by default, or an
+// S3-compatible bucket with BLOB_STORE=s3. The S3 defaults match cmd/mocks3.
+func newBlobStore() blobstore.Store {
+	switch kind := utils.GetEnv("BLOB_STORE", "local"); kind {
+	case "local":
+		return blobstore.NewLocalStore(utils.GetEnv("BLOB_STORAGE_DIR", "data/blobs"))
+	case "s3":
+		store, err := blobstore.NewS3Store(
+			utils.GetEnv("BLOB_S3_ENDPOINT", "http://localhost:9000"),
+			utils.GetEnv("BLOB_S3_REGION", "us-east-1"),
+			utils.GetEnv("BLOB_S3_BUCKET", "pts"),
+			utils.GetEnv("BLOB_S3_ACCESS_KEY", "pts-dev"),
+			utils.GetEnv("BLOB_S3_SECRET_KEY", "pts-dev-secret"),
+		)
+		if err != nil {
+			log.Fatal("Error configuring S3 blob store: ", err)
+		}
+		return store
+	default:
+		log.Fatalf("Unknown BLOB_STORE %q (expected local or s3)", kind)
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"PTS/geocode"
+	"time"
+)
+
+// SavedAddress is an address in a customer's address book, geocoded when it was saved
+type SavedAddress struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	Label     string          `json:"label"`
+	Address   geocode.Address `json:"address"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// SavedAddressRequest represents the structure for adding or changing a saved address.
+// Set address.location to pin the exact spot; otherwise the district or city centre is used.
+type SavedAddressRequest struct {
+	Label   string          `json:"label"`
+	Address geocode.Address `json:"address"`
+}
+
+// OrderAddresses picks an order's pickup and drop-off from the address book or as structured addresses,
+// instead of the free-text pickup and dropOff. The resolved addresses are copied onto the order.
+type OrderAddresses struct {
+	PickupAddressID  string           `json:"pickup_address_id,omitempty"`
+	DropOffAddressID string           `json:"drop_off_address_id,omitempty"`
+	PickupAddress    *geocode.Address `json:"pickup_address,omitempty"`
+	DropOffAddress   *geocode.Address `json:"drop_off_address,omitempty"`
+}
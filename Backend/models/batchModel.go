@@ -0,0 +1,24 @@
+package models
+
+import (
+	"PTS/vehicles"
+)
+
+// AssignBatchRequest represents the structure for assigning several orders to one courier at once
+type AssignBatchRequest struct {
+	CourierID string   `json:"courier_id"`
+	OrderIDs  []string `json:"order_ids"`
+}
+
+// BatchSuggestion is a set of compatible pending orders that fit in a courier's vehicle
+type BatchSuggestion struct {
+	CourierID string `json:"courier_id"`
+	// Vehicle is omitted for couriers whose vehicle type has no profile, which are not limited
+	Vehicle        *vehicles.Profile `json:"vehicle,omitempty"`
+	CurrentLoad    vehicles.Load     `json:"current_load"`
+	DeliveryDate   string            `json:"delivery_date,omitempty"`
+	DeliveryWindow string            `json:"delivery_window,omitempty"`
+	Orders         []Order           `json:"orders"`
+	// Load is what the courier would carry with the suggested orders added
+	Load vehicles.Load `json:"load"`
+}
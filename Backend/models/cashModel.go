@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+type CashLedgerEntry struct {
+	ID           string    `json:"id"`
+	CourierID    string    `json:"courier_id"`
+	StoreId      string    `json:"store_id"`
+	EntryType    string    `json:"entry_type"`
+	Amount       int64     `json:"amount"`
+	Currency     string    `json:"currency"`
+	OrderID      *string   `json:"order_id,omitempty"`
+	PaymentID    *string   `json:"payment_id,omitempty"`
+	SettlementID *string   `json:"settlement_id,omitempty"`
+	ShiftID      *string   `json:"shift_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CashSettlement struct {
+	ID             string    `json:"id"`
+	CourierID      string    `json:"courier_id"`
+	StoreId        string    `json:"store_id"`
+	ShiftID        *string   `json:"shift_id,omitempty"`
+	ExpectedAmount int64     `json:"expected_amount"`
+	HandedInAmount int64     `json:"handed_in_amount"`
+	Discrepancy    int64     `json:"discrepancy"`
+	Note           *string   `json:"note,omitempty"`
+	SettledBy      string    `json:"settled_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SettleCashRequest represents the structure for recording the cash a courier handed in
+type SettleCashRequest struct {
+	HandedIn *int64  `json:"handed_in"`
+	Note     *string `json:"note"`
+}
+
+// CourierCashResponse is a courier's ledger for a period and what they currently owe
+type CourierCashResponse struct {
+	Outstanding int64             `json:"outstanding"`
+	Entries     []CashLedgerEntry `json:"entries"`
+}
+
+// CourierCashReport totals a courier's cash for a period. A negative discrepancy means cash was short.
+type CourierCashReport struct {
+	CourierID   string `json:"courier_id"`
+	Collected   int64  `json:"collected"`
+	HandedIn    int64  `json:"handed_in"`
+	Discrepancy int64  `json:"discrepancy"`
+	Settlements int    `json:"settlements"`
+	Outstanding int64  `json:"outstanding"`
+}
+
+// StoreCashReport totals the cash of a store's couriers for a period
+type StoreCashReport struct {
+	StoreId     string              `json:"store_id"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Collected   int64               `json:"collected"`
+	HandedIn    int64               `json:"handed_in"`
+	Discrepancy int64               `json:"discrepancy"`
+	Outstanding int64               `json:"outstanding"`
+	Couriers    []CourierCashReport `json:"couriers"`
+}
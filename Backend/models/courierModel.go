@@ -9,6 +9,7 @@ type Courier struct {
 	VehicleType    string
 	AssignedOrders []string
 	Available      bool
+	Status         string
 	LastActiveAt   time.Time
 	StoreId        string
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"PTS/routing"
+	"time"
+)
+
+// Courier route statuses. A new suggestion replaces the courier's earlier ones, and accepting a route
+// supersedes the one accepted before it.
+const (
+	RouteSuggested  = "suggested"
+	RouteAccepted   = "accepted"
+	RouteSuperseded = "superseded"
+)
+
+// CourierRoute is a suggested sequence of stops for a courier's current orders
+type CourierRoute struct {
+	ID        string `json:"id"`
+	CourierID string `json:"courier_id"`
+	Status    string `json:"status"`
+	routing.Route
+	// UnroutedOrders were placed without geocoded addresses, so they cannot be put on the route
+	UnroutedOrders []string   `json:"unrouted_orders"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+}
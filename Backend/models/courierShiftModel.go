@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Courier shift statuses
+const (
+	CourierOffShift = "off_shift"
+	CourierOnShift  = "on_shift"
+	CourierOnBreak  = "on_break"
+)
+
+type CourierShift struct {
+	ID        string         `json:"id"`
+	CourierID string         `json:"courier_id"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   *time.Time     `json:"ended_at,omitempty"`
+	Breaks    []CourierBreak `json:"breaks"`
+}
+
+type CourierBreak struct {
+	ID        string     `json:"id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// CourierStatusResponse represents a courier's current availability and shift
+type CourierStatusResponse struct {
+	Status       string        `json:"status"`
+	Available    bool          `json:"available"`
+	LastActiveAt time.Time     `json:"last_active_at"`
+	Shift        *CourierShift `json:"shift,omitempty"`
+}
+
+// CourierAvailabilityRequest represents the structure for going online or offline during a shift
+type CourierAvailabilityRequest struct {
+	Available *bool `json:"available"`
+}
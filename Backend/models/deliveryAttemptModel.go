@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Reasons a courier can give for a failed delivery attempt
+const (
+	AttemptCustomerAbsent = "customer_absent"
+	AttemptWrongAddress   = "wrong_address"
+	AttemptRefused        = "refused"
+)
+
+// Outcomes of a failed delivery attempt
+const (
+	AttemptRescheduled = "rescheduled"
+	AttemptReturning   = "returning"
+)
+
+type DeliveryAttempt struct {
+	ID                string    `json:"id"`
+	OrderID           string    `json:"order_id"`
+	CourierID         string    `json:"courier_id"`
+	AttemptNumber     int       `json:"attempt_number"`
+	Reason            string    `json:"reason"`
+	Note              *string   `json:"note,omitempty"`
+	Lat               *float64  `json:"lat,omitempty"`
+	Lng               *float64  `json:"lng,omitempty"`
+	Outcome           string    `json:"outcome"`
+	RescheduledWindow *string   `json:"rescheduled_window,omitempty"`
+	RescheduledDate   *string   `json:"rescheduled_date,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// FailedAttemptRequest represents the structure for recording a failed delivery attempt
+type FailedAttemptRequest struct {
+	Reason string   `json:"reason"`
+	Note   string   `json:"note,omitempty"`
+	Lat    *float64 `json:"lat,omitempty"`
+	Lng    *float64 `json:"lng,omitempty"`
+}
+
+// FailedAttemptResponse is the recorded attempt together with the order after rescheduling or return
+type FailedAttemptResponse struct {
+	Attempt DeliveryAttempt `json:"attempt"`
+	Order   Order           `json:"order"`
+}
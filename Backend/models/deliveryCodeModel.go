@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// DeliveryCode is the handoff code the customer gives the courier to confirm a delivery.
+// The code itself is only shown to the customer.
+type DeliveryCode struct {
+	OrderID        string     `json:"order_id"`
+	Code           *string    `json:"code,omitempty"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"max_attempts"`
+	Locked         bool       `json:"locked"`
+	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
+	OverriddenAt   *time.Time `json:"overridden_at,omitempty"`
+	OverriddenBy   *string    `json:"overridden_by,omitempty"`
+	OverrideReason *string    `json:"override_reason,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type DeliveryCodeOverrideRequest struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+type EarningEntry struct {
+	ID          string    `json:"id"`
+	CourierID   string    `json:"courier_id"`
+	OrderID     *string   `json:"order_id,omitempty"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	EarnedOn    string    `json:"earned_on"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EarningsBucket totals a courier's earnings for one day or week, starting on Start
+type EarningsBucket struct {
+	Start      string `json:"start"`
+	Deliveries int    `json:"deliveries"`
+	Amount     int64  `json:"amount"`
+}
+
+// EarningsSummary is a courier's daily or weekly earnings for a period
+type EarningsSummary struct {
+	CourierID  string           `json:"courier_id"`
+	Group      string           `json:"group"`
+	From       string           `json:"from"`
+	To         string           `json:"to"`
+	Deliveries int              `json:"deliveries"`
+	Total      int64            `json:"total"`
+	Buckets    []EarningsBucket `json:"buckets"`
+}
+
+// CourierPayout is what one courier earned in a payout statement
+type CourierPayout struct {
+	CourierID  string         `json:"courier_id"`
+	Currency   string         `json:"currency"`
+	Deliveries int            `json:"deliveries"`
+	Total      int64          `json:"total"`
+	Entries    []EarningEntry `json:"entries"`
+}
+
+// PayoutStatement is what a store owes its couriers for a period
+type PayoutStatement struct {
+	StoreId  string          `json:"store_id"`
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Total    int64           `json:"total"`
+	Couriers []CourierPayout `json:"couriers"`
+}
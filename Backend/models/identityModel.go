@@ -0,0 +1,18 @@
+package models
+
+// Roles a PTS account can have
+const (
+	RoleUser    = "user"
+	RoleCourier = "courier"
+	RoleAdmin   = "admin"
+	RoleOwner   = "owner"
+)
+
+// Identity describes the account behind an authenticated request
+type Identity struct {
+	UserID    string
+	Email     string
+	Role      string
+	CourierID string
+	StoreId   string
+}
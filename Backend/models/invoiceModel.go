@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+type Invoice struct {
+	ID            string        `json:"id"`
+	StoreId       string        `json:"store_id"`
+	OrderID       string        `json:"order_id"`
+	UserID        string        `json:"user_id"`
+	Number        int64         `json:"number"`
+	InvoiceNumber string        `json:"invoice_number"`
+	Currency      string        `json:"currency"`
+	Lines         []InvoiceLine `json:"lines"`
+	Subtotal      int64         `json:"subtotal"`
+	Discount      int64         `json:"discount"`
+	TaxLines      []TaxLine     `json:"tax_lines"`
+	TaxTotal      int64         `json:"tax_total"`
+	Total         int64         `json:"total"`
+	PaymentMethod *string       `json:"payment_method,omitempty"`
+	PaymentStatus *string       `json:"payment_status,omitempty"`
+	IssuedAt      time.Time     `json:"issued_at"`
+}
+
+type InvoiceLine struct {
+	Description string `json:"description"`
+	Amount      int64  `json:"amount"`
+}
+
+// TaxLine is a tax charged on an invoice. Included taxes are already part of the total.
+type TaxLine struct {
+	Name        string  `json:"name"`
+	RatePercent float64 `json:"rate_percent"`
+	Amount      int64   `json:"amount"`
+	Included    bool    `json:"included"`
+}
+
+// StoreStatement totals the invoices a store issued in one month
+type StoreStatement struct {
+	StoreId      string             `json:"store_id"`
+	Month        string             `json:"month"`
+	Currency     string             `json:"currency"`
+	InvoiceCount int                `json:"invoice_count"`
+	Subtotal     int64              `json:"subtotal"`
+	Discount     int64              `json:"discount"`
+	TaxTotal     int64              `json:"tax_total"`
+	Total        int64              `json:"total"`
+	Invoices     []StatementInvoice `json:"invoices"`
+}
+
+type StatementInvoice struct {
+	InvoiceNumber string    `json:"invoice_number"`
+	OrderID       string    `json:"order_id"`
+	TaxTotal      int64     `json:"tax_total"`
+	Total         int64     `json:"total"`
+	IssuedAt      time.Time `json:"issued_at"`
+}
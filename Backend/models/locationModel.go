@@ -0,0 +1,30 @@
+package models
+
+import (
+	"PTS/eta"
+	"time"
+)
+
+// LocationPing is a single GPS reading sent by a courier's device
+type LocationPing struct {
+	Latitude   float64   `json:"lat"`
+	Longitude  float64   `json:"lng"`
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	Speed      *float64  `json:"speed,omitempty"`
+	RecordedAt time.Time `json:"timestamp"`
+}
+
+// LocationBatchRequest represents the structure for uploading a batch of location pings
+type LocationBatchRequest struct {
+	Pings []LocationPing `json:"pings"`
+}
+
+// OrderTrackingResponse represents the latest courier position and breadcrumb trail for an order
+type OrderTrackingResponse struct {
+	OrderID   string         `json:"order_id"`
+	Status    string         `json:"status"`
+	CourierID *string        `json:"courier_id,omitempty"`
+	Latest    *LocationPing  `json:"latest,omitempty"`
+	Trail     []LocationPing `json:"trail"`
+	ETA       *eta.Estimate  `json:"eta,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationPreferences represents which notification channels (email, sms, in_app) a user has enabled
+type NotificationPreferences struct {
+	Channels map[string]bool `json:"channels"`
+}
+
+type Notification struct {
+	ID        string     `json:"id"`
+	EventType string     `json:"event_type"`
+	OrderID   *string    `json:"order_id,omitempty"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// MarkNotificationsReadRequest represents the structure for marking notifications as read,
+// either by ID or all at once
+type MarkNotificationsReadRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+// UnreadCountResponse represents the badge count of unread notifications
+type UnreadCountResponse struct {
+	Unread int `json:"unread"`
+}
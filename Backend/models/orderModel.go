@@ -0,0 +1,100 @@
+package models
+
+import (
+	"PTS/eta"
+	"PTS/geocode"
+	"PTS/vehicles"
+	"time"
+)
+
+// Order statuses
+const (
+	OrderPending   = "pending"
+	OrderAssigned  = "assigned"
+	OrderPickedUp  = "picked_up"
+	OrderInTransit = "in_transit"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	// A delivery attempt failed and the order waits for its next window
+	OrderRescheduled = "rescheduled"
+	// The courier is taking the package back to the store
+	OrderReturning = "returning"
+	OrderReturned  = "returned"
+)
+
+type Order struct {
+	ID              string  `json:"id"`
+	UserID          string  `json:"user_id"`
+	StoreId         string  `json:"store_id"`
+	CourierID       *string `json:"courier_id,omitempty"`
+	PickupLocation  string  `json:"pickup_location"`
+	DropOffLocation string  `json:"drop_off_location"`
+	// PickupAddress and DropOffAddress are the geocoded addresses as they were when the order was placed
+	PickupAddress  *geocode.Address `json:"pickup_address,omitempty"`
+	DropOffAddress *geocode.Address `json:"drop_off_address,omitempty"`
+	DeliveryWindow string           `json:"delivery_window"`
+	DeliveryDate   *string          `json:"delivery_date,omitempty"`
+	Attempts       int              `json:"attempts"`
+	PackageDetails string           `json:"package_details"`
+	// Package is the package's weight and dimensions, if the customer gave them
+	Package       *vehicles.Package `json:"package,omitempty"`
+	Status        string            `json:"status"`
+	QuoteID       *string           `json:"quote_id,omitempty"`
+	PriceTotal    *int64            `json:"price_total,omitempty"`
+	PriceCurrency *string           `json:"price_currency,omitempty"`
+	RateCardID    *string           `json:"rate_card_id,omitempty"`
+	PromoCodeID   *string           `json:"promo_code_id,omitempty"`
+	DiscountTotal *int64            `json:"discount_total,omitempty"`
+	PaymentStatus *string           `json:"payment_status,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	// ETA is filled in on order details while the order is on its way
+	ETA *eta.Estimate `json:"eta,omitempty"`
+}
+
+// AmountDue is the quoted price less any promo discount, or 0 if the order was not priced
+func (o *Order) AmountDue() int64 {
+	if o.PriceTotal == nil {
+		return 0
+	}
+	due := *o.PriceTotal
+	if o.DiscountTotal != nil {
+		due -= *o.DiscountTotal
+	}
+	return due
+}
+
+// PlaceOrderRequest represents the structure for placing an order
+type PlaceOrderRequest struct {
+	StoreId        string `json:"store_id"`
+	Pickup         string `json:"pickup"`
+	DropOff        string `json:"dropOff"`
+	Delivery       string `json:"delivery"`
+	PackageDetails string `json:"packageDetails"`
+	// Package gives the package's weight and dimensions, so couriers are not given more than their vehicle carries. It is required with a quote.
+	Package *vehicles.Package `json:"package,omitempty"`
+	// DeliveryDate optionally picks the day (YYYY-MM-DD); by default the first day the window is still open
+	DeliveryDate string `json:"delivery_date,omitempty"`
+	// QuoteID optionally locks in a price from POST /quotes
+	QuoteID string `json:"quote_id,omitempty"`
+	// PromoCode optionally discounts the quoted price
+	PromoCode string `json:"promo_code,omitempty"`
+	OrderAddresses
+}
+
+// UpdateOrderStatusRequest represents the structure for moving an order to a new status
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// AssignOrderRequest represents the structure for assigning an order to a courier
+type AssignOrderRequest struct {
+	CourierID string `json:"courier_id"`
+}
+
+// OrderEventData is the payload of an order domain event
+type OrderEventData struct {
+	Order             Order   `json:"order"`
+	PreviousStatus    string  `json:"previous_status,omitempty"`
+	PreviousCourierID *string `json:"previous_courier_id,omitempty"`
+}
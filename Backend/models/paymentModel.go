@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+type Payment struct {
+	ID                string    `json:"id"`
+	OrderID           string    `json:"order_id"`
+	Method            string    `json:"method"`
+	Status            string    `json:"status"`
+	Amount            int64     `json:"amount"`
+	Currency          string    `json:"currency"`
+	CapturedAmount    int64     `json:"captured_amount"`
+	RefundedAmount    int64     `json:"refunded_amount"`
+	ProviderReference *string   `json:"provider_reference,omitempty"`
+	FailureReason     *string   `json:"failure_reason,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// CreatePaymentRequest represents the structure for choosing how to pay for an order.
+// Source is the card token from the gateway's client SDK and is only used for card payments.
+type CreatePaymentRequest struct {
+	Method string `json:"method"`
+	Source string `json:"source"`
+}
+
+// PaymentAmountRequest represents the structure for capturing or refunding a payment.
+// For cash on delivery captures, Amount is what the courier collected. Zero means the full outstanding amount.
+type PaymentAmountRequest struct {
+	Amount int64 `json:"amount"`
+}
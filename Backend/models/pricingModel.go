@@ -0,0 +1,29 @@
+package models
+
+import (
+	"PTS/geo"
+	"PTS/pricing"
+	"time"
+)
+
+// QuoteRequest represents the structure for asking a store for a delivery price
+type QuoteRequest struct {
+	StoreId      string    `json:"store_id"`
+	PickupPoint  geo.Point `json:"pickup_point"`
+	DropOffPoint geo.Point `json:"drop_off_point"`
+	Delivery     string    `json:"delivery"`
+	DeliveryDate string    `json:"delivery_date,omitempty"`
+	SizeClass    string    `json:"size_class"`
+	WeightKg     float64   `json:"weight_kg"`
+	VehicleType  string    `json:"vehicle_type,omitempty"`
+}
+
+// Quote is a price offered to a customer. It can be locked into one order until it expires.
+type Quote struct {
+	ID           string        `json:"id"`
+	StoreId      string        `json:"store_id"`
+	DeliveryDate string        `json:"delivery_date"`
+	Price        pricing.Price `json:"price"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
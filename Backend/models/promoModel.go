@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+type PromoCode struct {
+	ID              string     `json:"id"`
+	StoreId         string     `json:"store_id"`
+	Code            string     `json:"code"`
+	Kind            string     `json:"kind"`
+	Value           int64      `json:"value"`
+	MaxDiscount     *int64     `json:"max_discount,omitempty"`
+	MinOrderValue   int64      `json:"min_order_value"`
+	MaxRedemptions  *int64     `json:"max_redemptions,omitempty"`
+	MaxPerUser      *int64     `json:"max_per_user,omitempty"`
+	RedemptionCount int64      `json:"redemption_count"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Active          bool       `json:"active"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PromoCodeRequest represents the structure for creating or updating a promo code.
+// Kind is "percent" (Value is a percentage) or "fixed" (Value is in minor currency units).
+type PromoCodeRequest struct {
+	Code           string     `json:"code"`
+	Kind           string     `json:"kind"`
+	Value          int64      `json:"value"`
+	MaxDiscount    *int64     `json:"max_discount"`
+	MinOrderValue  int64      `json:"min_order_value"`
+	MaxRedemptions *int64     `json:"max_redemptions"`
+	MaxPerUser     *int64     `json:"max_per_user"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Active         *bool      `json:"active"`
+}
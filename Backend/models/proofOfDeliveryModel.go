@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+type ProofOfDelivery struct {
+	ID            string     `json:"id"`
+	OrderID       string     `json:"order_id"`
+	CourierID     string     `json:"courier_id"`
+	RecipientName string     `json:"recipient_name"`
+	Lat           float64    `json:"lat"`
+	Lng           float64    `json:"lng"`
+	CapturedAt    time.Time  `json:"captured_at"`
+	PinVerified   bool       `json:"pin_verified"`
+	SignatureURL  *string    `json:"signature_url,omitempty"`
+	PhotoURL      *string    `json:"photo_url,omitempty"`
+	LinksExpireAt *time.Time `json:"links_expire_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
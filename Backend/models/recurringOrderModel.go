@@ -0,0 +1,56 @@
+package models
+
+import (
+	"PTS/geocode"
+	"PTS/recurring"
+	"time"
+)
+
+// RecurringOrder repeats an order on the days its rule matches, between StartDate and the optional EndDate
+type RecurringOrder struct {
+	ID              string           `json:"id"`
+	UserID          string           `json:"user_id"`
+	StoreId         string           `json:"store_id"`
+	PickupLocation  string           `json:"pickup_location"`
+	DropOffLocation string           `json:"drop_off_location"`
+	PickupAddress   *geocode.Address `json:"pickup_address,omitempty"`
+	DropOffAddress  *geocode.Address `json:"drop_off_address,omitempty"`
+	DeliveryWindow  string           `json:"delivery_window"`
+	PackageDetails  string           `json:"package_details"`
+	Rule            recurring.Rule   `json:"rule"`
+	StartDate       string           `json:"start_date"`
+	EndDate         *string          `json:"end_date,omitempty"`
+	Status          string           `json:"status"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	// Upcoming lists the next dates the order repeats on, with those already placed, failed or skipped
+	Upcoming []RecurringOccurrence `json:"upcoming,omitempty"`
+}
+
+// RecurringOccurrence is one date of a recurring order. Status is empty until the scheduler places it.
+type RecurringOccurrence struct {
+	Date    string  `json:"date"`
+	Status  string  `json:"status,omitempty"`
+	OrderID *string `json:"order_id,omitempty"`
+	Reason  *string `json:"reason,omitempty"`
+}
+
+// RecurringOrderRequest represents the structure for creating a recurring order
+type RecurringOrderRequest struct {
+	StoreId        string         `json:"store_id"`
+	Pickup         string         `json:"pickup"`
+	DropOff        string         `json:"dropOff"`
+	Delivery       string         `json:"delivery"`
+	PackageDetails string         `json:"packageDetails"`
+	Rule           recurring.Rule `json:"rule"`
+	// StartDate is the first day (YYYY-MM-DD) the order can repeat on, tomorrow by default
+	StartDate string `json:"start_date,omitempty"`
+	// EndDate optionally stops the order after this day
+	EndDate string `json:"end_date,omitempty"`
+	OrderAddresses
+}
+
+// SkipOccurrenceRequest represents the structure for skipping one date of a recurring order
+type SkipOccurrenceRequest struct {
+	Date string `json:"date"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"PTS/geo"
+)
+
+// ServiceZoneRequest represents the structure for creating or replacing a store's service zone
+type ServiceZoneRequest struct {
+	Name string `json:"name"`
+	// Area is a GeoJSON Polygon or MultiPolygon with [lng, lat] positions
+	Area   geo.Geometry `json:"area"`
+	Active *bool        `json:"active,omitempty"`
+}
+
+// ServiceAreaCheck says whether a store delivers to a point, and if not how far away its nearest zone is
+type ServiceAreaCheck struct {
+	Inside     bool    `json:"inside"`
+	ZoneID     string  `json:"zone_id,omitempty"`
+	ZoneName   string  `json:"zone_name,omitempty"`
+	Nearest    string  `json:"nearest_zone,omitempty"`
+	DistanceKm float64 `json:"distance_km,omitempty"`
+	Message    string  `json:"message,omitempty"`
+}
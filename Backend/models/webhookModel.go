@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookEndpoint struct {
+	ID        string    `json:"id"`
+	StoreId   string    `json:"store_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID                 string           `json:"id"`
+	EndpointID         string           `json:"endpoint_id"`
+	EventID            string           `json:"event_id"`
+	EventType          string           `json:"event_type"`
+	Payload            json.RawMessage  `json:"payload" swaggertype:"object"`
+	Status             string           `json:"status"`
+	Attempts           int              `json:"attempts"`
+	NextAttemptAt      *time.Time       `json:"next_attempt_at,omitempty"`
+	LastResponseStatus *int             `json:"last_response_status,omitempty"`
+	LastError          *string          `json:"last_error,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	AttemptLog         []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt    time.Time `json:"attempted_at"`
+	ResponseStatus *int      `json:"response_status,omitempty"`
+	Error          *string   `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// WebhookEndpointRequest represents the structure for registering or updating a webhook endpoint
+type WebhookEndpointRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
@@ -0,0 +1,42 @@
+package notifications
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ConsoleNotifier writes messages to a writer instead of sending them. It stands in for the email
+// and SMS channels during development.
+type ConsoleNotifier struct {
+	ChannelName string
+	Out         io.Writer
+
+	mu sync.Mutex
+}
+
+// NewConsoleNotifier creates a notifier for the channel that writes to stdout, or appends to path if it is set
+func NewConsoleNotifier(channel, path string) (*ConsoleNotifier, error) {
+	var out io.Writer = os.Stdout
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+	return &ConsoleNotifier{ChannelName: channel, Out: out}, nil
+}
+
+func (n *ConsoleNotifier) Channel() string { return n.ChannelName }
+
+func (n *ConsoleNotifier) Send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.Out, "[%s] %s to user=%s email=%s phone=%s\nSubject: %s\n%s\n\n",
+		time.Now().Format(time.RFC3339), n.ChannelName, msg.UserID, msg.Email, msg.Phone, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// EmailNotifier sends messages through an SMTP server
+type EmailNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (n *EmailNotifier) Channel() string { return ChannelEmail }
+
+func (n *EmailNotifier) Send(msg Message) error {
+	if msg.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.Email)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	return smtp.SendMail(net.JoinHostPort(n.Host, n.Port), auth, n.From, []string{msg.Email}, []byte(body.String()))
+}
@@ -0,0 +1,21 @@
+package notifications
+
+import (
+	"PTS/utils"
+	"time"
+)
+
+// InAppNotifier stores messages in the user's notification inbox
+type InAppNotifier struct{}
+
+func (n *InAppNotifier) Channel() string { return ChannelInApp }
+
+func (n *InAppNotifier) Send(msg Message) error {
+	query := `
+        INSERT INTO notifications (event_id, user_id, event_type, order_id, title, body, created_at)
+        VALUES ($1, $2, $3, NULLIF($4, '')::UUID, $5, $6, $7)
+        ON CONFLICT (event_id, user_id) DO NOTHING
+    `
+	_, err := utils.DB.Exec(query, msg.EventID, msg.UserID, msg.EventType, msg.OrderID, msg.Subject, msg.Body, time.Now())
+	return err
+}
@@ -0,0 +1,126 @@
+package notifications
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// courierTemplates are sent to the courier an order is assigned to
+var courierTemplates = map[string]messageTemplate{
+	"order.assigned": newMessageTemplate("courier.order.assigned",
+		"New order assigned",
+		"Order {{.ID}} has been assigned to you. Pick it up from {{.PickupLocation}}."),
+	"order.cancelled": newMessageTemplate("courier.order.cancelled",
+		"Order cancelled",
+		"Order {{.ID}}, assigned to you, has been cancelled."),
+}
+
+// unassignedTemplate is sent to a courier whose order was reassigned to someone else
+var unassignedTemplate = newMessageTemplate("courier.order.unassigned",
+	"Order reassigned",
+	"Order {{.ID}} has been reassigned to another courier.")
+
+// staffTemplates are sent to the admins and owner of the order's store
+var staffTemplates = map[string]messageTemplate{
+	"order.created": newMessageTemplate("staff.order.created",
+		"New order",
+		"A new order {{.ID}} from {{.PickupLocation}} to {{.DropOffLocation}} is waiting to be assigned."),
+	"order.delivered": newMessageTemplate("staff.order.delivered",
+		"Order delivered",
+		"Order {{.ID}} has been delivered to {{.DropOffLocation}}."),
+	"order.cancelled": newMessageTemplate("staff.order.cancelled",
+		"Order cancelled",
+		"Order {{.ID}} has been cancelled."),
+	"order.returning": newMessageTemplate("staff.order.returning",
+		"Order coming back",
+		"Order {{.ID}} could not be delivered after {{.Attempts}} attempt(s) and is on its way back to the store."),
+	"order.returned": newMessageTemplate("staff.order.returned",
+		"Order returned",
+		"Order {{.ID}} has been returned to the store."),
+}
+
+// FeedInbox adds in-app notifications about an order event for the couriers and store staff it concerns.
+// Customers are notified through Enqueue, which honours their channel preferences.
+// eventID deduplicates the notifications, so feeding the same event twice adds them once.
+func FeedInbox(eventID, eventType string, data models.OrderEventData) error {
+	order := data.Order
+
+	if order.CourierID != nil {
+		if tmpl, ok := courierTemplates[eventType]; ok {
+			if err := addCourierToInbox(eventID, eventType, order, tmpl, *order.CourierID); err != nil {
+				return err
+			}
+		}
+
+		if eventType == "order.assigned" && data.PreviousCourierID != nil && *data.PreviousCourierID != *order.CourierID {
+			if err := addCourierToInbox(eventID, eventType, order, unassignedTemplate, *data.PreviousCourierID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tmpl, ok := staffTemplates[eventType]; ok {
+		query := `
+            SELECT user_id FROM admins WHERE store_id = $1
+            UNION
+            SELECT user_id FROM owners WHERE store_id = $1
+        `
+		rows, err := utils.DB.Query(query, order.StoreId)
+		if err != nil {
+			return err
+		}
+		var staff []string
+		for rows.Next() {
+			var userID string
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return err
+			}
+			staff = append(staff, userID)
+		}
+		rows.Close()
+
+		if err := addToInbox(eventID, eventType, order, tmpl, staff); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCourierToInbox notifies the user account behind a courier. A courier that has since been deleted has no one to notify.
+func addCourierToInbox(eventID, eventType string, order models.Order, tmpl messageTemplate, courierID string) error {
+	var userID string
+	err := utils.DB.QueryRow("SELECT user_id FROM couriers WHERE id = $1", courierID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return addToInbox(eventID, eventType, order, tmpl, []string{userID})
+}
+
+// addToInbox renders the template for the order and stores it in each user's inbox
+func addToInbox(eventID, eventType string, order models.Order, tmpl messageTemplate, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	subject, body, err := tmpl.render(order)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO notifications (event_id, user_id, event_type, order_id, title, body, created_at)
+        SELECT $1::UUID, unnest($2::UUID[]), $3::TEXT, $4::UUID, $5::TEXT, $6::TEXT, $7::TIMESTAMP
+        ON CONFLICT (event_id, user_id) DO NOTHING
+    `
+	_, err = utils.DB.Exec(query, eventID, pq.Array(userIDs), eventType, order.ID, subject, body, time.Now())
+	return err
+}
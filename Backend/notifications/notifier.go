@@ -0,0 +1,37 @@
+// Package notifications tells users about their orders over email, SMS and the in-app inbox.
+package notifications
+
+// Notification channels
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelInApp = "in_app"
+)
+
+// Channels lists every channel a user can enable or disable
+var Channels = []string{ChannelEmail, ChannelSMS, ChannelInApp}
+
+// defaultChannels are enabled for users who have not saved preferences
+var defaultChannels = map[string]bool{
+	ChannelEmail: true,
+	ChannelSMS:   false,
+	ChannelInApp: true,
+}
+
+// Message is a rendered notification addressed to one user
+type Message struct {
+	EventID   string
+	UserID    string
+	Email     string
+	Phone     string
+	EventType string
+	OrderID   string
+	Subject   string
+	Body      string
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Channel() string
+	Send(msg Message) error
+}
@@ -0,0 +1,48 @@
+package notifications
+
+import (
+	"PTS/utils"
+	"database/sql"
+)
+
+// EnabledChannels returns each channel's enabled flag for a user, falling back to the defaults
+// for channels the user has not configured
+func EnabledChannels(userID string) (map[string]bool, error) {
+	enabled := make(map[string]bool, len(defaultChannels))
+	for channel, on := range defaultChannels {
+		enabled[channel] = on
+	}
+
+	rows, err := utils.DB.Query("SELECT channel, enabled FROM notification_preferences WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channel string
+		var on bool
+		if err := rows.Scan(&channel, &on); err != nil {
+			return nil, err
+		}
+		enabled[channel] = on
+	}
+	return enabled, rows.Err()
+}
+
+// SetChannel saves whether a user wants notifications over a channel
+func SetChannel(tx *sql.Tx, userID, channel string, enabled bool) error {
+	query := `
+        INSERT INTO notification_preferences (user_id, channel, enabled)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, channel) DO UPDATE SET enabled = EXCLUDED.enabled
+    `
+	_, err := tx.Exec(query, userID, channel, enabled)
+	return err
+}
+
+// IsValidChannel reports whether channel is a known notification channel
+func IsValidChannel(channel string) bool {
+	_, ok := defaultChannels[channel]
+	return ok
+}
@@ -0,0 +1,156 @@
+package notifications
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"log"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseBackoff = time.Minute
+	jobLease           = 2 * time.Minute
+	jobBatchSize       = 50
+)
+
+// Enqueue renders the message for an order event and queues one job per channel the customer has enabled.
+// eventID deduplicates jobs, so enqueueing the same event twice sends it once.
+func Enqueue(eventID, eventType string, order models.Order) error {
+	subject, body, ok, err := Render(eventType, order)
+	if err != nil || !ok {
+		return err
+	}
+
+	enabled, err := EnabledChannels(order.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `
+        INSERT INTO notification_jobs (event_id, user_id, channel, event_type, order_id, subject, body, status, next_attempt_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', $8, $8)
+        ON CONFLICT (event_id, user_id, channel) DO NOTHING
+    `
+	for _, channel := range Channels {
+		if !enabled[channel] {
+			continue
+		}
+		if _, err := utils.DB.Exec(query, eventID, order.UserID, channel, eventType, order.ID, subject, body, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatcher sends queued notification jobs through the notifier for each channel, retrying failures with backoff
+type Dispatcher struct {
+	Notifiers   map[string]Notifier
+	MaxAttempts int
+	BaseBackoff time.Duration
+}
+
+// NewDispatcher creates a dispatcher for the given notifiers with the default retry policy
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	byChannel := make(map[string]Notifier, len(notifiers))
+	for _, notifier := range notifiers {
+		byChannel[notifier.Channel()] = notifier
+	}
+	return &Dispatcher{Notifiers: byChannel, MaxAttempts: defaultMaxAttempts, BaseBackoff: defaultBaseBackoff}
+}
+
+// Run sends due jobs every interval
+func (d *Dispatcher) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.SendDue()
+	}
+}
+
+// queuedJob is a claimed job with the recipient's contact details
+type queuedJob struct {
+	ID       string
+	Channel  string
+	Attempts int
+	Message  Message
+}
+
+// SendDue claims one batch of due jobs and sends them
+func (d *Dispatcher) SendDue() {
+	now := time.Now()
+
+	// Lease the jobs so other API instances skip them while they are being sent
+	query := `
+        UPDATE notification_jobs j SET next_attempt_at = $1
+        FROM users u
+        WHERE u.id = j.user_id AND j.id IN (
+            SELECT id FROM notification_jobs
+            WHERE status = 'pending' AND next_attempt_at <= $2
+            ORDER BY next_attempt_at
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING j.id, j.channel, j.attempts, j.event_id, j.user_id, u.email, u.phone, j.event_type, COALESCE(j.order_id::TEXT, ''), j.subject, j.body
+    `
+	rows, err := utils.DB.Query(query, now.Add(jobLease), now, jobBatchSize)
+	if err != nil {
+		log.Println("Error claiming notification jobs:", err)
+		return
+	}
+
+	var jobs []queuedJob
+	for rows.Next() {
+		var job queuedJob
+		msg := &job.Message
+		if err := rows.Scan(&job.ID, &job.Channel, &job.Attempts, &msg.EventID, &msg.UserID, &msg.Email, &msg.Phone, &msg.EventType, &msg.OrderID, &msg.Subject, &msg.Body); err != nil {
+			log.Println("Error scanning notification job:", err)
+			break
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	for _, job := range jobs {
+		d.send(job)
+	}
+}
+
+// send delivers one job and records the outcome
+func (d *Dispatcher) send(job queuedJob) {
+	notifier, ok := d.Notifiers[job.Channel]
+	if !ok {
+		d.finish(job, models.DeliveryFailed, nil, "no notifier configured for channel "+job.Channel)
+		return
+	}
+
+	err := notifier.Send(job.Message)
+	if err == nil {
+		d.finish(job, models.DeliverySucceeded, nil, "")
+		return
+	}
+
+	attempts := job.Attempts + 1
+	if attempts >= d.MaxAttempts {
+		d.finish(job, models.DeliveryFailed, nil, err.Error())
+		return
+	}
+
+	wait := d.BaseBackoff << (attempts - 1)
+	next := time.Now().Add(wait)
+	d.finish(job, models.DeliveryPending, &next, err.Error())
+}
+
+// finish records a send attempt and schedules the next one, if any
+func (d *Dispatcher) finish(job queuedJob, status string, nextAttemptAt *time.Time, lastError string) {
+	query := `
+        UPDATE notification_jobs
+        SET status = $1, attempts = attempts + 1, next_attempt_at = COALESCE($2, next_attempt_at), last_error = NULLIF($3, ''), sent_at = CASE WHEN $1 = 'succeeded' THEN $4::TIMESTAMP END
+        WHERE id = $5
+    `
+	if _, err := utils.DB.Exec(query, status, nextAttemptAt, lastError, time.Now(), job.ID); err != nil {
+		log.Println("Error updating notification job:", err)
+	}
+}
@@ -0,0 +1,55 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SMSGatewayNotifier sends text messages by POSTing {"to", "message"} as JSON to an SMS gateway
+type SMSGatewayNotifier struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewSMSGatewayNotifier creates a notifier for the gateway at url
+func NewSMSGatewayNotifier(url, apiKey string) *SMSGatewayNotifier {
+	return &SMSGatewayNotifier{URL: url, APIKey: apiKey, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *SMSGatewayNotifier) Channel() string { return ChannelSMS }
+
+func (n *SMSGatewayNotifier) Send(msg Message) error {
+	if msg.Phone == "" {
+		return errors.New("user has no phone number")
+	}
+
+	payload, err := json.Marshal(map[string]string{"to": msg.Phone, "message": msg.Body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.APIKey)
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sms gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"PTS/models"
+	"strings"
+	"text/template"
+)
+
+// messageTemplate is the subject and body sent for one order event
+type messageTemplate struct {
+	Subject *template.Template
+	Body    *template.Template
+}
+
+func newMessageTemplate(name, subject, body string) messageTemplate {
+	return messageTemplate{
+		Subject: template.Must(template.New(name + ".subject").Parse(subject)),
+		Body:    template.Must(template.New(name + ".body").Parse(body)),
+	}
+}
+
+// customerTemplates are keyed by webhook-style event names (order.<status>)
+var customerTemplates = map[string]messageTemplate{
+	"order.created": newMessageTemplate("order.created",
+		"We received your order",
+		"Your order {{.ID}} from {{.PickupLocation}} to {{.DropOffLocation}} has been placed and is waiting for a courier."),
+	"order.assigned": newMessageTemplate("order.assigned",
+		"A courier has been assigned to your order",
+		"A courier has been assigned to your order {{.ID}} and will pick it up from {{.PickupLocation}} soon."),
+	"order.picked_up": newMessageTemplate("order.picked_up",
+		"Your order has been picked up",
+		"Your order {{.ID}} has been picked up and is on its way to {{.DropOffLocation}}."),
+	"order.in_transit": newMessageTemplate("order.in_transit",
+		"Your order is in transit",
+		"Your order {{.ID}} is in transit to {{.DropOffLocation}}."),
+	"order.delivered": newMessageTemplate("order.delivered",
+		"Your order has been delivered",
+		"Your order {{.ID}} has been delivered to {{.DropOffLocation}}. Thank you for using PTS!"),
+	"order.cancelled": newMessageTemplate("order.cancelled",
+		"Your order has been cancelled",
+		"Your order {{.ID}} has been cancelled."),
+	"order.rescheduled": newMessageTemplate("order.rescheduled",
+		"We missed you",
+		"We could not deliver your order {{.ID}} to {{.DropOffLocation}}. We will try again in the {{.DeliveryWindow}} window on {{.DeliveryDate}}."),
+	"order.returning": newMessageTemplate("order.returning",
+		"Your order is being returned",
+		"We could not deliver your order {{.ID}}, so it is being returned to the store."),
+	"order.returned": newMessageTemplate("order.returned",
+		"Your order was returned",
+		"Your order {{.ID}} has been returned to the store."),
+}
+
+// Render fills in the customer template for an order event. ok is false if the event has no template.
+func Render(eventType string, order models.Order) (subject, body string, ok bool, err error) {
+	tmpl, ok := customerTemplates[eventType]
+	if !ok {
+		return "", "", false, nil
+	}
+
+	subject, body, err = tmpl.render(order)
+	return subject, body, true, err
+}
+
+// render executes the subject and body templates for an order
+func (t messageTemplate) render(order models.Order) (string, string, error) {
+	var subject, body strings.Builder
+	if err := t.Subject.Execute(&subject, order); err != nil {
+		return "", "", err
+	}
+	if err := t.Body.Execute(&body, order); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
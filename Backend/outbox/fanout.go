@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"PTS/hub"
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// hubChannel is the Postgres notification channel that carries live events to the hub of every API instance
+const hubChannel = "pts_hub_events"
+
+// hubNotification is the payload sent on hubChannel. Relayed events are sent by their outbox ID and loaded
+// by each instance, as an order can be larger than the 8000 bytes a notification holds; live-only events
+// such as location pings are small and sent whole.
+type hubNotification struct {
+	OutboxID string          `json:"outbox_id,omitempty"`
+	Event    *broadcastEvent `json:"event,omitempty"`
+}
+
+// broadcastEvent is a hub.Event whose data is passed through as already encoded JSON
+type broadcastEvent struct {
+	Type    string          `json:"type"`
+	OrderID string          `json:"order_id"`
+	StoreId string          `json:"store_id"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// Broadcast publishes a live-only event, one that is not worth keeping in the outbox, to every API instance
+func Broadcast(event hub.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	return notifyHub(hubNotification{Event: &broadcastEvent{
+		Type:    event.Type,
+		OrderID: event.OrderID,
+		StoreId: event.StoreId,
+		Data:    data,
+		At:      event.At,
+	}})
+}
+
+func notifyHub(notification hubNotification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	_, err = utils.DB.Exec("SELECT pg_notify($1, $2)", hubChannel, string(payload))
+	return err
+}
+
+// ListenHub publishes the events announced on hubChannel to h, reconnecting when the connection drops.
+// Events announced while it is disconnected are missed; streams start from a snapshot, so clients that
+// reconnect catch up.
+func ListenHub(h *hub.Hub) {
+	listener := pq.NewListener(utils.ConnString(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Error on hub event listener:", err)
+		}
+	})
+	if err := listener.Listen(hubChannel); err != nil {
+		log.Println("Error listening for hub events:", err)
+		return
+	}
+
+	for notification := range listener.Notify {
+		// A nil notification means the connection was re-established
+		if notification == nil {
+			continue
+		}
+		event, err := decodeHubNotification(notification.Extra)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			log.Println("Error reading hub event:", err)
+			continue
+		}
+		h.Publish(event)
+	}
+}
+
+// decodeHubNotification turns a hubChannel payload back into the event to publish
+func decodeHubNotification(payload string) (hub.Event, error) {
+	var notification hubNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		return hub.Event{}, err
+	}
+	if notification.Event != nil {
+		event := notification.Event
+		return hub.Event{Type: event.Type, OrderID: event.OrderID, StoreId: event.StoreId, Data: event.Data, At: event.At}, nil
+	}
+
+	var event hub.Event
+	var data []byte
+	query := "SELECT id, event_type, order_id, store_id, payload, created_at FROM outbox_events WHERE id = $1"
+	err := utils.DB.QueryRow(query, notification.OutboxID).Scan(&event.ID, &event.Type, &event.OrderID, &event.StoreId, &data, &event.At)
+	if err != nil {
+		return hub.Event{}, err
+	}
+	event.Data = json.RawMessage(data)
+	return event, nil
+}
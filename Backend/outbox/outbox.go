@@ -0,0 +1,54 @@
+// Package outbox records domain events in the same transaction as the state change that caused them,
+// and relays them to sinks in the background so that no event is lost if the process crashes.
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a domain event waiting in, or relayed from, the outbox
+type Event struct {
+	// ID is stable across relay retries, so sinks and their consumers can use it to deduplicate
+	ID        string
+	Type      string
+	OrderID   string
+	StoreId   string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Execer is satisfied by *sql.Tx, so events can be written alongside the state change
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// wake lets writers nudge the relay so events go out without waiting for the next poll
+var wake = make(chan struct{}, 1)
+
+// Write adds an event to the outbox using the caller's transaction
+func Write(tx Execer, eventType, orderID, storeID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `
+        INSERT INTO outbox_events (id, event_type, order_id, store_id, payload, created_at, next_attempt_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+    `
+	_, err = tx.Exec(query, uuid.NewString(), eventType, orderID, storeID, payload, now)
+	return err
+}
+
+// Notify wakes the relay after a transaction that wrote events has committed
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"PTS/utils"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	relayBatchSize    = 100
+	relayBaseBackoff  = 5 * time.Second
+	relayMaxBackoff   = 10 * time.Minute
+	publishedRetained = 7 * 24 * time.Hour
+)
+
+// Sink receives relayed events. Publish may be called more than once for the same event ID.
+type Sink interface {
+	Name() string
+	Publish(event Event) error
+}
+
+// Relay moves pending outbox events to its sinks, retrying each sink until it succeeds
+type Relay struct {
+	Sinks []Sink
+}
+
+// NewRelay creates a relay that publishes to the given sinks
+func NewRelay(sinks ...Sink) *Relay {
+	return &Relay{Sinks: sinks}
+}
+
+// Run relays pending events every interval, or sooner when Notify is called
+func (r *Relay) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-wake:
+		case <-cleanup.C:
+			r.purgePublished()
+			continue
+		}
+
+		// Keep going while full batches come back so a backlog drains quickly
+		for r.RelayPending() == relayBatchSize {
+		}
+	}
+}
+
+// RelayPending publishes one batch of due events and returns how many it processed
+func (r *Relay) RelayPending() int {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		log.Println("Error starting outbox transaction:", err)
+		return 0
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several API instances relay concurrently without sending an event twice at once
+	query := `
+        SELECT id, event_type, order_id, store_id, payload, created_at, attempts, published_sinks
+        FROM outbox_events
+        WHERE published_at IS NULL AND next_attempt_at <= $1
+        ORDER BY created_at
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `
+	rows, err := tx.Query(query, time.Now(), relayBatchSize)
+	if err != nil {
+		log.Println("Error reading outbox events:", err)
+		return 0
+	}
+
+	type pendingEvent struct {
+		Event
+		attempts  int
+		published []string
+	}
+	var pending []pendingEvent
+	for rows.Next() {
+		var event pendingEvent
+		var payload []byte
+		err := rows.Scan(&event.ID, &event.Type, &event.OrderID, &event.StoreId, &payload, &event.CreatedAt, &event.attempts, pq.Array(&event.published))
+		if err != nil {
+			rows.Close()
+			log.Println("Error scanning outbox event:", err)
+			return 0
+		}
+		event.Payload = payload
+		pending = append(pending, event)
+	}
+	rows.Close()
+
+	for _, event := range pending {
+		published := event.published
+		var lastErr error
+		for _, sink := range r.Sinks {
+			if contains(published, sink.Name()) {
+				continue
+			}
+			if err := sink.Publish(event.Event); err != nil {
+				log.Printf("Error publishing outbox event %s to %s: %v", event.ID, sink.Name(), err)
+				lastErr = err
+				continue
+			}
+			published = append(published, sink.Name())
+		}
+
+		if lastErr == nil {
+			_, err = tx.Exec("UPDATE outbox_events SET published_sinks = $1, published_at = $2, last_error = NULL WHERE id = $3",
+				pq.Array(published), time.Now(), event.ID)
+		} else {
+			attempts := event.attempts + 1
+			_, err = tx.Exec("UPDATE outbox_events SET published_sinks = $1, attempts = $2, next_attempt_at = $3, last_error = $4 WHERE id = $5",
+				pq.Array(published), attempts, time.Now().Add(backoff(attempts)), lastErr.Error(), event.ID)
+		}
+		if err != nil {
+			log.Println("Error updating outbox event:", err)
+			return 0
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Error committing outbox batch:", err)
+		return 0
+	}
+	return len(pending)
+}
+
+// purgePublished deletes events that were fully published long enough ago
+func (r *Relay) purgePublished() {
+	if _, err := utils.DB.Exec("DELETE FROM outbox_events WHERE published_at < $1", time.Now().Add(-publishedRetained)); err != nil {
+		log.Println("Error purging published outbox events:", err)
+	}
+}
+
+// backoff returns the wait before retrying an event that failed the given number of times
+func backoff(attempts int) time.Duration {
+	wait := relayBaseBackoff
+	for i := 1; i < attempts && wait < relayMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > relayMaxBackoff {
+		return relayMaxBackoff
+	}
+	return wait
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
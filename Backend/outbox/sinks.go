@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"PTS/hub"
+	"PTS/models"
+	"PTS/notifications"
+	"PTS/webhooks"
+	"encoding/json"
+	"log"
+)
+
+// HubSink pushes events to live subscribers on every API instance. The relay runs wherever it wins the
+// batch, so the event is announced through Postgres and each instance's ListenHub publishes it to its own hub.
+type HubSink struct{}
+
+func (s HubSink) Name() string { return "hub" }
+
+func (s HubSink) Publish(event Event) error {
+	return notifyHub(hubNotification{OutboxID: event.ID})
+}
+
+// WebhookSink queues deliveries to the store's webhook endpoints. The outbox event ID is reused as
+// the webhook event ID, so relaying an event twice does not queue duplicate deliveries.
+type WebhookSink struct{}
+
+func (s WebhookSink) Name() string { return "webhooks" }
+
+func (s WebhookSink) Publish(event Event) error {
+	var data models.OrderEventData
+	if err := json.Unmarshal(event.Payload, &data); err != nil {
+		return err
+	}
+
+	webhookEvent := lifecycleEventFor(event.Type, &data.Order)
+	if webhookEvent == "" {
+		return nil
+	}
+	return webhooks.Enqueue(event.ID, event.StoreId, webhookEvent, event.CreatedAt, data.Order)
+}
+
+// lifecycleEventFor maps an order event to the lifecycle event (order.<status>, order.assigned, ...)
+// used by webhooks and notifications, or "" if there is none
+func lifecycleEventFor(eventType string, order *models.Order) string {
+	switch eventType {
+	case hub.OrderCreated:
+		return webhooks.EventOrderCreated
+	case hub.OrderAssigned:
+		return webhooks.EventOrderAssigned
+	case hub.OrderStatusChanged:
+		return "order." + order.Status
+	}
+	return ""
+}
+
+// NotificationSink queues customer notifications for order lifecycle events
+type NotificationSink struct{}
+
+func (s NotificationSink) Name() string { return "notifications" }
+
+func (s NotificationSink) Publish(event Event) error {
+	var data models.OrderEventData
+	if err := json.Unmarshal(event.Payload, &data); err != nil {
+		return err
+	}
+
+	lifecycleEvent := lifecycleEventFor(event.Type, &data.Order)
+	if lifecycleEvent == "" {
+		return nil
+	}
+	return notifications.Enqueue(event.ID, lifecycleEvent, data.Order)
+}
+
+// InboxSink adds in-app notifications for the couriers and store staff an order event concerns
+type InboxSink struct{}
+
+func (s InboxSink) Name() string { return "inbox" }
+
+func (s InboxSink) Publish(event Event) error {
+	var data models.OrderEventData
+	if err := json.Unmarshal(event.Payload, &data); err != nil {
+		return err
+	}
+
+	lifecycleEvent := lifecycleEventFor(event.Type, &data.Order)
+	if lifecycleEvent == "" {
+		return nil
+	}
+	return notifications.FeedInbox(event.ID, lifecycleEvent, data)
+}
+
+// LogSink writes every event to the application log
+type LogSink struct{}
+
+func (s LogSink) Name() string { return "log" }
+
+func (s LogSink) Publish(event Event) error {
+	log.Printf("Event %s %s order=%s store=%s", event.ID, event.Type, event.OrderID, event.StoreId)
+	return nil
+}
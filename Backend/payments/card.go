@@ -0,0 +1,104 @@
+package payments
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CardGatewayProvider charges card tokens through a card gateway's REST API:
+//
+//	POST /charges                 {"amount", "currency", "source", "order_id"} -> {"id", "status", "decline_reason"}
+//	POST /charges/{id}/capture    {"amount"}
+//	POST /charges/{id}/refunds    {"amount"}
+//	POST /charges/{id}/void       {}
+//
+// Every request carries an Idempotency-Key header. The mockgateway package implements the same API for local use.
+type CardGatewayProvider struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// gatewayCharge is the gateway's response to a charge request
+type gatewayCharge struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
+
+// NewCardGatewayProvider creates a provider for the gateway at baseURL
+func NewCardGatewayProvider(baseURL, apiKey string) *CardGatewayProvider {
+	return &CardGatewayProvider{URL: baseURL, APIKey: apiKey, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (p *CardGatewayProvider) Method() string { return MethodCard }
+
+func (p *CardGatewayProvider) Authorize(charge Charge, source, idempotencyKey string) (string, error) {
+	body := map[string]interface{}{
+		"amount":   charge.Amount,
+		"currency": charge.Currency,
+		"source":   source,
+		"order_id": charge.OrderID,
+	}
+	var result gatewayCharge
+	if err := p.post("/charges", body, idempotencyKey, &result); err != nil {
+		return "", err
+	}
+	if result.Status != "authorized" {
+		return "", &DeclinedError{Reason: result.DeclineReason}
+	}
+	return result.ID, nil
+}
+
+func (p *CardGatewayProvider) Capture(charge Charge, amount int64, idempotencyKey string) error {
+	var result gatewayCharge
+	return p.post("/charges/"+url.PathEscape(charge.Reference)+"/capture", map[string]int64{"amount": amount}, idempotencyKey, &result)
+}
+
+func (p *CardGatewayProvider) Refund(charge Charge, amount int64, idempotencyKey string) error {
+	var result gatewayCharge
+	return p.post("/charges/"+url.PathEscape(charge.Reference)+"/refunds", map[string]int64{"amount": amount}, idempotencyKey, &result)
+}
+
+func (p *CardGatewayProvider) Void(charge Charge, idempotencyKey string) error {
+	var result gatewayCharge
+	return p.post("/charges/"+url.PathEscape(charge.Reference)+"/void", map[string]int64{}, idempotencyKey, &result)
+}
+
+// post sends a JSON request to the gateway and decodes the JSON response into out
+func (p *CardGatewayProvider) post(path string, body interface{}, idempotencyKey string, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		var declined gatewayCharge
+		json.NewDecoder(resp.Body).Decode(&declined)
+		return &DeclinedError{Reason: declined.DeclineReason}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("card gateway responded with status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
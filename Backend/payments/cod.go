@@ -0,0 +1,23 @@
+package payments
+
+// CashOnDelivery is paid in cash to the courier. Nothing is reserved up front; capturing records
+// the amount the courier confirms they collected, and refunds are handed back in cash by the store.
+type CashOnDelivery struct{}
+
+func (CashOnDelivery) Method() string { return MethodCashOnDelivery }
+
+func (CashOnDelivery) Authorize(charge Charge, source, idempotencyKey string) (string, error) {
+	return "cod_" + charge.PaymentID, nil
+}
+
+func (CashOnDelivery) Capture(charge Charge, amount int64, idempotencyKey string) error {
+	return nil
+}
+
+func (CashOnDelivery) Refund(charge Charge, amount int64, idempotencyKey string) error {
+	return nil
+}
+
+func (CashOnDelivery) Void(charge Charge, idempotencyKey string) error {
+	return nil
+}
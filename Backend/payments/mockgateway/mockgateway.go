@@ -0,0 +1,125 @@
+// Package mockgateway is an in-memory card gateway implementing the API payments.CardGatewayProvider
+// expects, for local development. Any source authorizes except "tok_decline" and "tok_insufficient_funds".
+package mockgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// charge is the gateway's record of one card charge
+type charge struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Captured int64  `json:"captured"`
+	Refunded int64  `json:"refunded"`
+}
+
+// response is a stored reply, replayed for requests repeating an idempotency key
+type response struct {
+	status int
+	body   interface{}
+}
+
+// Gateway serves the mock card gateway API
+type Gateway struct {
+	mu        sync.Mutex
+	charges   map[string]*charge
+	responses map[string]response
+}
+
+// New creates an empty gateway
+func New() *Gateway {
+	return &Gateway{charges: map[string]*charge{}, responses: map[string]response{}}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+		Source   string `json:"source"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cacheKey := r.URL.Path + "|" + key
+	reply, seen := g.responses[cacheKey]
+	if !seen {
+		reply = g.handle(r.URL.Path, body.Amount, body.Currency, body.Source)
+		g.responses[cacheKey] = reply
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(reply.status)
+	json.NewEncoder(w).Encode(reply.body)
+}
+
+// handle applies one request to the gateway state
+func (g *Gateway) handle(path string, amount int64, currency, source string) response {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "charges":
+		if amount <= 0 {
+			return response{http.StatusBadRequest, map[string]string{"error": "amount must be positive"}}
+		}
+		switch source {
+		case "tok_decline":
+			return response{http.StatusPaymentRequired, map[string]string{"status": "declined", "decline_reason": "card_declined"}}
+		case "tok_insufficient_funds":
+			return response{http.StatusPaymentRequired, map[string]string{"status": "declined", "decline_reason": "insufficient_funds"}}
+		}
+		c := &charge{ID: "ch_" + uuid.NewString(), Status: "authorized", Amount: amount, Currency: currency}
+		g.charges[c.ID] = c
+		return response{http.StatusOK, c}
+
+	case len(parts) == 3 && parts[0] == "charges":
+		c, ok := g.charges[parts[1]]
+		if !ok {
+			return response{http.StatusNotFound, map[string]string{"error": "charge not found"}}
+		}
+
+		switch parts[2] {
+		case "capture":
+			if c.Status != "authorized" || amount <= 0 || amount > c.Amount {
+				return response{http.StatusConflict, map[string]string{"error": "charge cannot be captured"}}
+			}
+			c.Status, c.Captured = "captured", amount
+			return response{http.StatusOK, c}
+		case "refunds":
+			if c.Status != "captured" || amount <= 0 || c.Refunded+amount > c.Captured {
+				return response{http.StatusConflict, map[string]string{"error": "charge cannot be refunded"}}
+			}
+			c.Refunded += amount
+			return response{http.StatusOK, c}
+		case "void":
+			if c.Status != "authorized" {
+				return response{http.StatusConflict, map[string]string{"error": "charge cannot be voided"}}
+			}
+			c.Status = "voided"
+			return response{http.StatusOK, c}
+		}
+	}
+	return response{http.StatusNotFound, map[string]string{"error": "not found"}}
+}
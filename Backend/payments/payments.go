@@ -0,0 +1,412 @@
+package payments
+
+import (
+	"PTS/models"
+	"PTS/settlements"
+	"PTS/utils"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+const paymentColumns = "id, order_id, method, status, amount, currency, captured_amount, refunded_amount, provider_reference, failure_reason, created_at, updated_at"
+
+// Payment operation kinds recorded in payment_operations
+const (
+	operationAuthorize = "authorize"
+	operationCapture   = "capture"
+	operationRefund    = "refund"
+	operationVoid      = "void"
+)
+
+// ErrInvalidAmount is returned when a capture or refund amount is not positive or exceeds what is available
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// StateError is returned when the payment is not in a status that allows the operation
+type StateError struct {
+	Reason string
+}
+
+func (e *StateError) Error() string {
+	return e.Reason
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner, payment *models.Payment) error {
+	return row.Scan(
+		&payment.ID, &payment.OrderID, &payment.Method, &payment.Status, &payment.Amount, &payment.Currency,
+		&payment.CapturedAmount, &payment.RefundedAmount, &payment.ProviderReference, &payment.FailureReason,
+		&payment.CreatedAt, &payment.UpdatedAt,
+	)
+}
+
+// Latest returns the order's most recent payment attempt, or sql.ErrNoRows
+func Latest(orderID string) (*models.Payment, error) {
+	var payment models.Payment
+	query := "SELECT " + paymentColumns + " FROM payments WHERE order_id = $1 ORDER BY created_at DESC LIMIT 1"
+	if err := scanPayment(utils.DB.QueryRow(query, orderID), &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Create starts paying for an order with the given method. Card payments are authorized straight away;
+// cash on delivery stays pending until the courier collects. Calling it again while the order already has
+// a payment with the same method returns that payment. A declined payment is stored with status failed
+// and a new one can then be started.
+//
+// The payment row is committed before the provider is called, and its ID is the idempotency key. A card left
+// pending by a failed call or commit is authorized again with the same key on retry, so it is charged once.
+func Create(order *models.Order, method, source, performedBy string) (*models.Payment, error) {
+	provider, ok := providers[method]
+	if !ok {
+		return nil, &StateError{Reason: "Unsupported payment method"}
+	}
+
+	var payment models.Payment
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		// Serialise payment attempts per order
+		if _, err := tx.Exec("SELECT id FROM orders WHERE id = $1 FOR UPDATE", order.ID); err != nil {
+			return err
+		}
+
+		existing, err := lockActive(tx, order.ID)
+		if err == nil {
+			if existing.Method != method {
+				return &StateError{Reason: "Order already has a " + existing.Method + " payment"}
+			}
+			payment = *existing
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		now := time.Now()
+		query := `
+            INSERT INTO payments (order_id, method, status, amount, currency, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $6)
+            RETURNING ` + paymentColumns
+		if err := scanPayment(tx.QueryRow(query, order.ID, method, StatusPending, order.AmountDue(), *order.PriceCurrency, now), &payment); err != nil {
+			return err
+		}
+		return setOrderPaymentStatus(tx, order.ID, payment.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Payments the provider has already answered for are returned as they are
+	if payment.Status != StatusPending || payment.ProviderReference != nil {
+		return &payment, nil
+	}
+
+	status := StatusAuthorized
+	if method == MethodCashOnDelivery {
+		status = StatusPending
+	}
+	var failureReason *string
+	reference, err := provider.Authorize(chargeFor(&payment), source, payment.ID)
+	var declined *DeclinedError
+	if errors.As(err, &declined) {
+		status, failureReason = StatusFailed, &declined.Reason
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = utils.WithTx(func(tx *sql.Tx) error {
+		var referenceValue *string
+		if reference != "" {
+			referenceValue = &reference
+		}
+		// Another attempt may have recorded the same authorization in the meantime
+		updateQuery := `
+            UPDATE payments SET status = $1, provider_reference = $2, failure_reason = $3, updated_at = $4
+            WHERE id = $5 AND status = 'pending' AND provider_reference IS NULL
+            RETURNING ` + paymentColumns
+		err := scanPayment(tx.QueryRow(updateQuery, status, referenceValue, failureReason, time.Now(), payment.ID), &payment)
+		if err == sql.ErrNoRows {
+			return scanPayment(tx.QueryRow("SELECT "+paymentColumns+" FROM payments WHERE id = $1", payment.ID), &payment)
+		}
+		if err != nil {
+			return err
+		}
+		if status != StatusFailed {
+			if err := recordOperation(tx, payment.ID, operationAuthorize, payment.ID, payment.Amount, performedBy); err != nil {
+				return err
+			}
+		}
+		return setOrderPaymentStatus(tx, order.ID, payment.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Capture takes the money for the order's payment. For cash on delivery, amount is what the courier
+// collected; for cards it may be less than the authorized amount. Zero captures the full amount.
+// Repeating a capture with the same idempotency key returns the payment without capturing again.
+func Capture(orderID string, amount int64, idempotencyKey, performedBy string) (*models.Payment, error) {
+	return operate(orderID, operationCapture, idempotencyKey, performedBy, func(tx *sql.Tx, payment *models.Payment) error {
+		if payment.Status != StatusAuthorized && payment.Status != StatusPending {
+			return &StateError{Reason: "Payment cannot be captured while " + payment.Status}
+		}
+		if payment.ProviderReference == nil {
+			return &StateError{Reason: "Payment has not been authorized yet"}
+		}
+		if amount == 0 {
+			amount = payment.Amount
+		}
+		if amount < 0 || (payment.Method != MethodCashOnDelivery && amount > payment.Amount) {
+			return ErrInvalidAmount
+		}
+
+		if err := providers[payment.Method].Capture(chargeFor(payment), amount, idempotencyKey); err != nil {
+			return err
+		}
+		if err := recordOperation(tx, payment.ID, operationCapture, idempotencyKey, amount, performedBy); err != nil {
+			return err
+		}
+		if payment.Method == MethodCashOnDelivery {
+			if err := settlements.RecordCollection(tx, payment, amount); err != nil {
+				return err
+			}
+		}
+
+		query := `
+            UPDATE payments SET status = $1, captured_amount = $2, updated_at = $3
+            WHERE id = $4
+            RETURNING ` + paymentColumns
+		return scanPayment(tx.QueryRow(query, StatusCaptured, amount, time.Now(), payment.ID), payment)
+	})
+}
+
+// Refund returns part or all of a captured payment. Zero refunds everything not yet refunded.
+// Repeating a refund with the same idempotency key returns the payment without refunding again.
+func Refund(orderID string, amount int64, idempotencyKey, performedBy string) (*models.Payment, error) {
+	return operate(orderID, operationRefund, idempotencyKey, performedBy, func(tx *sql.Tx, payment *models.Payment) error {
+		if payment.Status != StatusCaptured && payment.Status != StatusPartiallyRefunded {
+			return &StateError{Reason: "Payment cannot be refunded while " + payment.Status}
+		}
+		remaining := payment.CapturedAmount - payment.RefundedAmount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return ErrInvalidAmount
+		}
+
+		if err := providers[payment.Method].Refund(chargeFor(payment), amount, idempotencyKey); err != nil {
+			return err
+		}
+		if err := recordOperation(tx, payment.ID, operationRefund, idempotencyKey, amount, performedBy); err != nil {
+			return err
+		}
+
+		status := StatusPartiallyRefunded
+		if amount == remaining {
+			status = StatusRefunded
+		}
+		query := `
+            UPDATE payments SET status = $1, refunded_amount = refunded_amount + $2, updated_at = $3
+            WHERE id = $4
+            RETURNING ` + paymentColumns
+		return scanPayment(tx.QueryRow(query, status, amount, time.Now(), payment.ID), payment)
+	})
+}
+
+// Cancel releases the order's active payment within the transaction that cancels the order. Here the payment
+// is only marked voiding, for authorized cards and uncollected cash, or refunding, for money already taken.
+// The provider is called by SettleCancellation once the cancellation has committed, so a cancel that rolls
+// back never moves money. Orders without a payment are left alone.
+func Cancel(tx *sql.Tx, orderID, performedBy string) error {
+	payment, err := lockActive(tx, orderID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var status, kind string
+	var amount int64
+	switch {
+	case payment.Status == StatusPending && payment.ProviderReference == nil:
+		// The provider never confirmed an authorization, so there is nothing to release. One it made without
+		// answering is never captured and lapses.
+		status, kind, amount = StatusVoided, operationVoid, payment.Amount
+	case payment.Status == StatusPending, payment.Status == StatusAuthorized:
+		status, kind, amount = StatusVoiding, operationVoid, payment.Amount
+	case payment.Status == StatusCaptured, payment.Status == StatusPartiallyRefunded:
+		status, kind, amount = StatusRefunding, operationRefund, payment.CapturedAmount-payment.RefundedAmount
+	default:
+		return nil
+	}
+
+	if err := recordOperation(tx, payment.ID, kind, cancelKey(payment.ID), amount, performedBy); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3", status, time.Now(), payment.ID); err != nil {
+		return err
+	}
+	return setOrderPaymentStatus(tx, orderID, status)
+}
+
+// cancelKey is the idempotency key for releasing a cancelled order's payment. It is fixed per payment, so
+// however often the provider call is retried, money moves once.
+func cancelKey(paymentID string) string {
+	return "cancel_" + paymentID
+}
+
+// SettleCancellation asks the provider to release the payment of a cancelled order, once the cancellation has
+// committed. If it fails the payment stays voiding or refunding and SettleCancellations retries it.
+func SettleCancellation(orderID string) error {
+	var paymentID string
+	query := "SELECT id FROM payments WHERE order_id = $1 AND status IN ('voiding', 'refunding')"
+	err := utils.DB.QueryRow(query, orderID).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return settleCancellation(paymentID)
+}
+
+// SettleCancellations retries releasing the payments of cancelled orders that are still voiding or refunding,
+// and returns how many it settled
+func SettleCancellations() (int, error) {
+	rows, err := utils.DB.Query("SELECT id FROM payments WHERE status IN ('voiding', 'refunding') ORDER BY updated_at LIMIT 100")
+	if err != nil {
+		return 0, err
+	}
+	var paymentIDs []string
+	for rows.Next() {
+		var paymentID string
+		if err := rows.Scan(&paymentID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		paymentIDs = append(paymentIDs, paymentID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, paymentID := range paymentIDs {
+		if err := settleCancellation(paymentID); err != nil {
+			log.Println("Error releasing payment", paymentID+":", err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// settleCancellation calls the provider for one voiding or refunding payment and records the outcome. The row
+// stays locked during the call; if the transaction does not commit, the payment is still voiding or refunding
+// and the retry repeats the call with the same key.
+func settleCancellation(paymentID string) error {
+	return utils.WithTx(func(tx *sql.Tx) error {
+		var payment models.Payment
+		query := "SELECT " + paymentColumns + " FROM payments WHERE id = $1 AND status IN ('voiding', 'refunding') FOR UPDATE SKIP LOCKED"
+		err := scanPayment(tx.QueryRow(query, paymentID), &payment)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		provider := providers[payment.Method]
+		now := time.Now()
+		if payment.Status == StatusVoiding {
+			if err := provider.Void(chargeFor(&payment), cancelKey(payment.ID)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3", StatusVoided, now, payment.ID); err != nil {
+				return err
+			}
+			return setOrderPaymentStatus(tx, payment.OrderID, StatusVoided)
+		}
+
+		if err := provider.Refund(chargeFor(&payment), payment.CapturedAmount-payment.RefundedAmount, cancelKey(payment.ID)); err != nil {
+			return err
+		}
+		updateQuery := "UPDATE payments SET status = $1, refunded_amount = captured_amount, updated_at = $2 WHERE id = $3"
+		if _, err := tx.Exec(updateQuery, StatusRefunded, now, payment.ID); err != nil {
+			return err
+		}
+		return setOrderPaymentStatus(tx, payment.OrderID, StatusRefunded)
+	})
+}
+
+// operate locks the order's active payment and runs apply unless an operation of the same kind with the
+// same idempotency key has already been recorded, then mirrors the resulting status onto the order
+func operate(orderID, kind, idempotencyKey, performedBy string, apply func(tx *sql.Tx, payment *models.Payment) error) (*models.Payment, error) {
+	var payment *models.Payment
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		var err error
+		payment, err = lockActive(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		var replayed bool
+		query := "SELECT EXISTS (SELECT 1 FROM payment_operations WHERE payment_id = $1 AND kind = $2 AND idempotency_key = $3)"
+		if err := tx.QueryRow(query, payment.ID, kind, idempotencyKey).Scan(&replayed); err != nil {
+			return err
+		}
+		if replayed {
+			return nil
+		}
+
+		if err := apply(tx, payment); err != nil {
+			return err
+		}
+		return setOrderPaymentStatus(tx, orderID, payment.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// lockActive loads the order's payment that has not failed and locks it for the rest of tx
+func lockActive(tx *sql.Tx, orderID string) (*models.Payment, error) {
+	var payment models.Payment
+	query := "SELECT " + paymentColumns + " FROM payments WHERE order_id = $1 AND status <> 'failed' FOR UPDATE"
+	if err := scanPayment(tx.QueryRow(query, orderID), &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func recordOperation(tx *sql.Tx, paymentID, kind, idempotencyKey string, amount int64, performedBy string) error {
+	query := `
+        INSERT INTO payment_operations (payment_id, kind, idempotency_key, amount, performed_by, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	_, err := tx.Exec(query, paymentID, kind, idempotencyKey, amount, performedBy, time.Now())
+	return err
+}
+
+func setOrderPaymentStatus(tx *sql.Tx, orderID, status string) error {
+	_, err := tx.Exec("UPDATE orders SET payment_status = $1, updated_at = $2 WHERE id = $3", status, time.Now(), orderID)
+	return err
+}
+
+func chargeFor(payment *models.Payment) Charge {
+	charge := Charge{PaymentID: payment.ID, OrderID: payment.OrderID, Amount: payment.Amount, Currency: payment.Currency}
+	if payment.ProviderReference != nil {
+		charge.Reference = *payment.ProviderReference
+	}
+	return charge
+}
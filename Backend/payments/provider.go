@@ -0,0 +1,65 @@
+// Package payments charges customers for orders through pluggable payment providers.
+package payments
+
+// Payment methods
+const (
+	MethodCashOnDelivery = "cod"
+	MethodCard           = "card"
+)
+
+// Payment statuses, also mirrored on the order as payment_status
+const (
+	StatusPending           = "pending" // cash on delivery, waiting for the courier to collect
+	StatusAuthorized        = "authorized"
+	StatusCaptured          = "captured"
+	StatusPartiallyRefunded = "partially_refunded"
+	StatusRefunded          = "refunded"
+	StatusVoiding           = "voiding"   // the order was cancelled and the authorization is being released
+	StatusRefunding         = "refunding" // the order was cancelled and the captured money is being returned
+	StatusVoided            = "voided"    // released without taking any money, e.g. when the order is cancelled
+	StatusFailed            = "failed"
+)
+
+// Charge identifies the payment a provider is asked to act on
+type Charge struct {
+	PaymentID string
+	OrderID   string
+	Amount    int64
+	Currency  string
+	// Reference is the provider's ID for the charge, set once it has been authorized
+	Reference string
+}
+
+// Provider moves money for one payment method. Every call carries an idempotency key, and
+// retrying a call with the same key must not move money twice.
+type Provider interface {
+	Method() string
+	// Authorize reserves the charge amount from source (e.g. a card token) and returns the provider's reference
+	Authorize(charge Charge, source, idempotencyKey string) (string, error)
+	Capture(charge Charge, amount int64, idempotencyKey string) error
+	Refund(charge Charge, amount int64, idempotencyKey string) error
+	// Void releases an authorized charge without taking any money
+	Void(charge Charge, idempotencyKey string) error
+}
+
+// DeclinedError is returned by providers that refuse a charge
+type DeclinedError struct {
+	Reason string
+}
+
+func (e *DeclinedError) Error() string {
+	return "payment declined: " + e.Reason
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a provider available for its payment method
+func Register(provider Provider) {
+	providers[provider.Method()] = provider
+}
+
+// IsValidMethod reports whether a provider is registered for the payment method
+func IsValidMethod(method string) bool {
+	_, ok := providers[method]
+	return ok
+}
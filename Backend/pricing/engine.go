@@ -0,0 +1,135 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Input describes the delivery being priced
+type Input struct {
+	DistanceKm     float64
+	SizeClass      string
+	WeightKg       float64
+	DeliveryWindow string
+	// Zone is the name of the service zone the drop-off is in, if the store has zones
+	Zone string
+	// VehicleType may be empty, in which case the smallest vehicle that fits the package is used
+	VehicleType string
+	// At is when the delivery is priced for, used for peak-hour multipliers
+	At time.Time
+}
+
+// Line is one component of a price
+type Line struct {
+	Code   string `json:"code"`
+	Label  string `json:"label"`
+	Amount int64  `json:"amount"`
+}
+
+// Price is a computed fee and how it was built up
+type Price struct {
+	RateCardID      string  `json:"rate_card_id,omitempty"`
+	RateCardVersion int     `json:"rate_card_version,omitempty"`
+	Currency        string  `json:"currency"`
+	DistanceKm      float64 `json:"distance_km"`
+	VehicleType     string  `json:"vehicle_type"`
+	Lines           []Line  `json:"lines"`
+	Total           int64   `json:"total"`
+}
+
+// ErrVehicleTooSmall is returned when the requested vehicle cannot carry the package
+var ErrVehicleTooSmall = errors.New("requested vehicle cannot carry this package")
+
+// RequiredVehicle returns the smallest vehicle that can carry a package of the given size and weight
+func RequiredVehicle(sizeClass string, weightKg float64) string {
+	switch {
+	case sizeClass == SizeXL || weightKg > 50:
+		return VehicleVan
+	case sizeClass == SizeLarge || weightKg > 15:
+		return VehicleCar
+	case sizeClass == SizeMedium || weightKg > 5:
+		return VehicleMotorcycle
+	default:
+		return VehicleBike
+	}
+}
+
+// Validate checks that the input can be priced
+func (in Input) Validate() error {
+	if _, ok := sizeRank[in.SizeClass]; !ok {
+		return fmt.Errorf("unknown size class %q", in.SizeClass)
+	}
+	if in.WeightKg < 0 || in.DistanceKm < 0 || math.IsNaN(in.WeightKg) || math.IsNaN(in.DistanceKm) {
+		return errors.New("distance and weight must not be negative")
+	}
+	if in.VehicleType != "" {
+		if _, ok := vehicleRank[in.VehicleType]; !ok {
+			return fmt.Errorf("unknown vehicle type %q", in.VehicleType)
+		}
+	}
+	return nil
+}
+
+// Price computes the fee for a delivery under this rate card
+func (c RateCard) Price(in Input) (Price, error) {
+	if err := in.Validate(); err != nil {
+		return Price{}, err
+	}
+
+	vehicle := RequiredVehicle(in.SizeClass, in.WeightKg)
+	if in.VehicleType != "" {
+		if vehicleRank[in.VehicleType] < vehicleRank[vehicle] {
+			return Price{}, ErrVehicleTooSmall
+		}
+		vehicle = in.VehicleType
+	}
+
+	multiplier := c.VehicleMultipliers[vehicle]
+	if multiplier == 0 {
+		multiplier = 1
+	}
+
+	price := Price{
+		RateCardID:      c.ID,
+		RateCardVersion: c.Version,
+		Currency:        c.Currency,
+		DistanceKm:      round2(in.DistanceKm),
+		VehicleType:     vehicle,
+	}
+	add := func(code, label string, amount int64) {
+		if amount != 0 {
+			price.Lines = append(price.Lines, Line{Code: code, Label: label, Amount: amount})
+			price.Total += amount
+		}
+	}
+
+	add("base", "Base fee", c.BaseFee)
+	add("distance", fmt.Sprintf("Distance (%.2f km)", in.DistanceKm), roundAmount(float64(c.PerKm)*in.DistanceKm*multiplier))
+	if extraKg := in.WeightKg - c.IncludedKg; extraKg > 0 {
+		add("weight", fmt.Sprintf("Weight over %.1f kg", c.IncludedKg), roundAmount(float64(c.PerKg)*extraKg*multiplier))
+	}
+	add("size", "Package size ("+in.SizeClass+")", c.SizeSurcharges[in.SizeClass])
+	add("window", "Delivery window ("+in.DeliveryWindow+")", c.WindowSurcharges[in.DeliveryWindow])
+	if in.Zone != "" {
+		add("zone", "Delivery zone ("+in.Zone+")", c.ZoneSurcharges[in.Zone])
+	}
+	if peak := c.peakMultiplier(in.At); peak > 1 {
+		add("peak", fmt.Sprintf("Peak hours (x%.2f)", peak), roundAmount(float64(price.Total)*(peak-1)))
+	}
+
+	if price.Total < c.MinimumFee {
+		add("minimum", "Minimum fee adjustment", c.MinimumFee-price.Total)
+	}
+
+	return price, nil
+}
+
+func roundAmount(value float64) int64 {
+	return int64(math.Round(value))
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
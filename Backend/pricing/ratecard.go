@@ -0,0 +1,224 @@
+// Package pricing computes delivery fees from a store's rate card.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Package size classes, smallest first
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+	SizeXL     = "xl"
+)
+
+// Vehicle types, smallest first
+const (
+	VehicleBike       = "bike"
+	VehicleMotorcycle = "motorcycle"
+	VehicleCar        = "car"
+	VehicleVan        = "van"
+)
+
+// vehicleRank orders vehicles by what they can carry
+var vehicleRank = map[string]int{
+	VehicleBike:       0,
+	VehicleMotorcycle: 1,
+	VehicleCar:        2,
+	VehicleVan:        3,
+}
+
+// sizeRank orders package size classes
+var sizeRank = map[string]int{
+	SizeSmall:  0,
+	SizeMedium: 1,
+	SizeLarge:  2,
+	SizeXL:     3,
+}
+
+// SizeFits reports whether size is no bigger than the size class limit
+func SizeFits(size, limit string) bool {
+	rank, ok := sizeRank[size]
+	limitRank, limitOK := sizeRank[limit]
+	return ok && limitOK && rank <= limitRank
+}
+
+// VehicleFits reports whether vehicle is no bigger than the vehicle type limit
+func VehicleFits(vehicle, limit string) bool {
+	rank, ok := vehicleRank[vehicle]
+	limitRank, limitOK := vehicleRank[limit]
+	return ok && limitOK && rank <= limitRank
+}
+
+// RateCard is how a store prices deliveries. Amounts are in minor currency units (e.g. piastres).
+type RateCard struct {
+	// ID, Version and CreatedAt are set on cards a store has saved; DefaultRateCard has none
+	ID        string     `json:"id,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	Currency string `json:"currency"`
+
+	BaseFee    int64   `json:"base_fee"`
+	PerKm      int64   `json:"per_km"`
+	PerKg      int64   `json:"per_kg"`
+	IncludedKg float64 `json:"included_kg"`
+	MinimumFee int64   `json:"minimum_fee"`
+
+	// SizeSurcharges and WindowSurcharges are flat amounts added per size class and delivery window
+	SizeSurcharges   map[string]int64 `json:"size_surcharges"`
+	WindowSurcharges map[string]int64 `json:"window_surcharges"`
+	// ZoneSurcharges are flat amounts added when the drop-off is in the service zone with that name
+	ZoneSurcharges map[string]int64 `json:"zone_surcharges,omitempty"`
+
+	// VehicleMultipliers scale the distance and weight part of the fee per vehicle type
+	VehicleMultipliers map[string]float64 `json:"vehicle_multipliers"`
+
+	// PeakPeriods scale the whole fee during busy hours, in the card's Timezone (UTC if empty)
+	PeakPeriods []PeakPeriod `json:"peak_periods,omitempty"`
+	Timezone    string       `json:"timezone,omitempty"`
+}
+
+// PeakPeriod is a recurring busy time. It covers [StartHour, EndHour) on the given weekdays
+// (0 = Sunday), or every day if Weekdays is empty. EndHour may be less than StartHour to wrap past midnight.
+type PeakPeriod struct {
+	Weekdays   []int   `json:"weekdays,omitempty"`
+	StartHour  int     `json:"start_hour"`
+	EndHour    int     `json:"end_hour"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// covers reports whether the period includes the given local time
+func (p PeakPeriod) covers(at time.Time) bool {
+	if len(p.Weekdays) > 0 {
+		matched := false
+		for _, day := range p.Weekdays {
+			if time.Weekday(day) == at.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	hour := at.Hour()
+	if p.StartHour <= p.EndHour {
+		return hour >= p.StartHour && hour < p.EndHour
+	}
+	return hour >= p.StartHour || hour < p.EndHour
+}
+
+// peakMultiplier returns the largest multiplier of the peak periods covering the given time, or 1
+func (c RateCard) peakMultiplier(at time.Time) float64 {
+	if at.IsZero() {
+		return 1
+	}
+	if location, err := time.LoadLocation(c.Timezone); err == nil {
+		at = at.In(location)
+	}
+
+	multiplier := 1.0
+	for _, period := range c.PeakPeriods {
+		if period.covers(at) && period.Multiplier > multiplier {
+			multiplier = period.Multiplier
+		}
+	}
+	return multiplier
+}
+
+// Validate checks that a store's rate card is usable for pricing
+func (c RateCard) Validate() error {
+	if len(c.Currency) != 3 {
+		return errors.New("currency must be a 3-letter ISO code")
+	}
+	if c.BaseFee < 0 || c.PerKm < 0 || c.PerKg < 0 || c.IncludedKg < 0 || c.MinimumFee < 0 {
+		return errors.New("fees must not be negative")
+	}
+	for size, amount := range c.SizeSurcharges {
+		if _, ok := sizeRank[size]; !ok {
+			return fmt.Errorf("unknown size class %q", size)
+		}
+		if amount < 0 {
+			return errors.New("surcharges must not be negative")
+		}
+	}
+	for _, amount := range c.WindowSurcharges {
+		if amount < 0 {
+			return errors.New("surcharges must not be negative")
+		}
+	}
+	for _, amount := range c.ZoneSurcharges {
+		if amount < 0 {
+			return errors.New("surcharges must not be negative")
+		}
+	}
+	for vehicle, multiplier := range c.VehicleMultipliers {
+		if _, ok := vehicleRank[vehicle]; !ok {
+			return fmt.Errorf("unknown vehicle type %q", vehicle)
+		}
+		if multiplier <= 0 {
+			return errors.New("vehicle multipliers must be positive")
+		}
+	}
+	for _, period := range c.PeakPeriods {
+		if period.StartHour < 0 || period.StartHour > 23 || period.EndHour < 0 || period.EndHour > 24 || period.StartHour == period.EndHour {
+			return errors.New("peak periods need distinct start and end hours between 0 and 24")
+		}
+		if period.Multiplier < 1 {
+			return errors.New("peak multipliers must be at least 1")
+		}
+		for _, day := range period.Weekdays {
+			if day < 0 || day > 6 {
+				return errors.New("peak weekdays must be between 0 (Sunday) and 6")
+			}
+		}
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", c.Timezone)
+	}
+	return nil
+}
+
+// DefaultRateCard is used for stores that have not configured their own
+var DefaultRateCard = RateCard{
+	Currency:   "EGP",
+	BaseFee:    2000,
+	PerKm:      500,
+	PerKg:      200,
+	IncludedKg: 2,
+	MinimumFee: 2500,
+	SizeSurcharges: map[string]int64{
+		SizeSmall:  0,
+		SizeMedium: 500,
+		SizeLarge:  1500,
+		SizeXL:     3000,
+	},
+	WindowSurcharges: map[string]int64{
+		"morning": 0,
+		"midDay":  0,
+		"night":   1000,
+	},
+	VehicleMultipliers: map[string]float64{
+		VehicleBike:       1.0,
+		VehicleMotorcycle: 1.1,
+		VehicleCar:        1.4,
+		VehicleVan:        2.0,
+	},
+}
+
+// RateCardProvider looks up the rate card a store currently prices with
+type RateCardProvider interface {
+	CurrentRateCard(storeID string) (RateCard, error)
+}
+
+// DefaultProvider prices every store with DefaultRateCard
+type DefaultProvider struct{}
+
+func (DefaultProvider) CurrentRateCard(storeID string) (RateCard, error) {
+	return DefaultRateCard, nil
+}
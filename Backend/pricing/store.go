@@ -0,0 +1,88 @@
+package pricing
+
+import (
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// StoreProvider prices each store with the latest rate card it has saved, falling back to DefaultRateCard
+type StoreProvider struct{}
+
+func (StoreProvider) CurrentRateCard(storeID string) (RateCard, error) {
+	query := "SELECT id, version, card, created_at FROM rate_cards WHERE store_id = $1 ORDER BY version DESC LIMIT 1"
+	card, err := scanRateCard(utils.DB.QueryRow(query, storeID))
+	if err == sql.ErrNoRows {
+		return DefaultRateCard, nil
+	}
+	return card, err
+}
+
+// Save stores the card as the store's next version and returns it with its ID and version set.
+// Earlier versions are never modified, so orders keep pointing at the card they were priced with.
+func Save(tx *sql.Tx, storeID, createdBy string, card RateCard) (RateCard, error) {
+	card.ID, card.Version, card.CreatedAt = "", 0, nil
+	body, err := json.Marshal(card)
+	if err != nil {
+		return RateCard{}, err
+	}
+
+	// Serialise versioning per store
+	if _, err := tx.Exec("SELECT id FROM stores WHERE id = $1 FOR UPDATE", storeID); err != nil {
+		return RateCard{}, err
+	}
+
+	query := `
+        INSERT INTO rate_cards (store_id, version, card, created_by, created_at)
+        SELECT $1::UUID, COALESCE(MAX(version), 0) + 1, $2::JSONB, $3::UUID, $4::TIMESTAMP FROM rate_cards WHERE store_id = $1::UUID
+        RETURNING id, version, card, created_at
+    `
+	return scanRateCard(tx.QueryRow(query, storeID, body, createdBy, time.Now()))
+}
+
+// List returns every saved version of the store's rate card, newest first
+func List(storeID string) ([]RateCard, error) {
+	rows, err := utils.DB.Query("SELECT id, version, card, created_at FROM rate_cards WHERE store_id = $1 ORDER BY version DESC", storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []RateCard{}
+	for rows.Next() {
+		card, err := scanRateCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// Version returns one saved version of the store's rate card, or sql.ErrNoRows
+func Version(storeID string, version int) (RateCard, error) {
+	query := "SELECT id, version, card, created_at FROM rate_cards WHERE store_id = $1 AND version = $2"
+	return scanRateCard(utils.DB.QueryRow(query, storeID, version))
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRateCard(row rowScanner) (RateCard, error) {
+	var id string
+	var version int
+	var body []byte
+	var createdAt time.Time
+	if err := row.Scan(&id, &version, &body, &createdAt); err != nil {
+		return RateCard{}, err
+	}
+
+	var card RateCard
+	if err := json.Unmarshal(body, &card); err != nil {
+		return RateCard{}, err
+	}
+	card.ID, card.Version, card.CreatedAt = id, version, &createdAt
+	return card, nil
+}
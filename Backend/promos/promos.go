@@ -0,0 +1,148 @@
+// Package promos validates and redeems store promo codes against orders.
+package promos
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Discount kinds
+const (
+	KindPercent = "percent"
+	KindFixed   = "fixed"
+)
+
+// Rejection explains why a promo code cannot be applied to an order
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return "promo code cannot be applied: " + r.Reason
+}
+
+// Redemption is a promo code applied to an order
+type Redemption struct {
+	PromoID  string
+	Discount int64
+}
+
+// promo holds the rules of a promo code as read for redemption
+type promo struct {
+	ID             string
+	Kind           string
+	Value          int64
+	MaxDiscount    sql.NullInt64
+	MinOrderValue  int64
+	MaxRedemptions sql.NullInt64
+	MaxPerUser     sql.NullInt64
+	Redemptions    int64
+	StartsAt       sql.NullTime
+	EndsAt         sql.NullTime
+	Active         bool
+}
+
+// NormalizeCode returns the canonical form promo codes are stored and matched in
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Discount returns how much a promo of the given kind and value takes off an order total
+func Discount(kind string, value int64, maxDiscount *int64, orderTotal int64) int64 {
+	var discount int64
+	switch kind {
+	case KindPercent:
+		discount = orderTotal * value / 100
+	case KindFixed:
+		discount = value
+	}
+	if maxDiscount != nil && discount > *maxDiscount {
+		discount = *maxDiscount
+	}
+	if discount > orderTotal {
+		discount = orderTotal
+	}
+	return discount
+}
+
+// Redeem applies the store's promo code to an order being placed by the user within tx.
+// The promo row is locked for the rest of the transaction, so concurrent checkouts using the same code
+// are counted one at a time and can never exceed the total or per-user caps.
+// It returns a *Rejection if the code is unknown, inactive, outside its validity window, used up,
+// or the order is below the minimum value.
+func Redeem(tx *sql.Tx, storeID, userID, orderID, code string, orderTotal int64) (*Redemption, error) {
+	var p promo
+	query := `
+        SELECT id, kind, value, max_discount, min_order_value, max_redemptions, max_per_user, redemption_count, starts_at, ends_at, active
+        FROM promo_codes
+        WHERE store_id = $1 AND code = $2
+        FOR UPDATE
+    `
+	err := tx.QueryRow(query, storeID, NormalizeCode(code)).Scan(
+		&p.ID, &p.Kind, &p.Value, &p.MaxDiscount, &p.MinOrderValue, &p.MaxRedemptions, &p.MaxPerUser, &p.Redemptions, &p.StartsAt, &p.EndsAt, &p.Active,
+	)
+	if err == sql.ErrNoRows {
+		return nil, &Rejection{Reason: "unknown code"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case !p.Active:
+		return nil, &Rejection{Reason: "code is no longer active"}
+	case p.StartsAt.Valid && now.Before(p.StartsAt.Time):
+		return nil, &Rejection{Reason: "code is not valid yet"}
+	case p.EndsAt.Valid && !now.Before(p.EndsAt.Time):
+		return nil, &Rejection{Reason: "code has expired"}
+	case orderTotal < p.MinOrderValue:
+		return nil, &Rejection{Reason: "order is below the minimum value for this code"}
+	case p.MaxRedemptions.Valid && p.Redemptions >= p.MaxRedemptions.Int64:
+		return nil, &Rejection{Reason: "code has been fully redeemed"}
+	}
+
+	if p.MaxPerUser.Valid {
+		var used int64
+		err := tx.QueryRow("SELECT COUNT(*) FROM promo_redemptions WHERE promo_id = $1 AND user_id = $2", p.ID, userID).Scan(&used)
+		if err != nil {
+			return nil, err
+		}
+		if used >= p.MaxPerUser.Int64 {
+			return nil, &Rejection{Reason: "you have already used this code"}
+		}
+	}
+
+	var maxDiscount *int64
+	if p.MaxDiscount.Valid {
+		maxDiscount = &p.MaxDiscount.Int64
+	}
+	redemption := &Redemption{PromoID: p.ID, Discount: Discount(p.Kind, p.Value, maxDiscount, orderTotal)}
+
+	insertQuery := `
+        INSERT INTO promo_redemptions (promo_id, user_id, order_id, discount, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	if _, err := tx.Exec(insertQuery, p.ID, userID, orderID, redemption.Discount, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE promo_codes SET redemption_count = redemption_count + 1 WHERE id = $1", p.ID); err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// Release gives back the redemption of a cancelled order so it no longer counts towards the caps
+func Release(tx *sql.Tx, orderID string) error {
+	var promoID string
+	err := tx.QueryRow("DELETE FROM promo_redemptions WHERE order_id = $1 RETURNING promo_id", orderID).Scan(&promoID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE promo_codes SET redemption_count = redemption_count - 1 WHERE id = $1 AND redemption_count > 0", promoID)
+	return err
+}
@@ -0,0 +1,222 @@
+// Package recurring repeats a customer's order on a schedule, turning each occurrence into a real order
+// a few days ahead so it can be booked into its delivery window.
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule frequencies
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+	FrequencyCron   = "cron"
+)
+
+// Rule says on which days an order repeats. Daily rules repeat every Interval days from the start date,
+// weekly rules on the listed Weekdays every Interval weeks, and cron rules on the days matching Cron,
+// a cron-style "day-of-month month day-of-week" expression such as "1,15 * *" or "* * mon-fri".
+type Rule struct {
+	Frequency string   `json:"frequency"`
+	Interval  int      `json:"interval,omitempty"`
+	Weekdays  []string `json:"weekdays,omitempty"`
+	Cron      string   `json:"cron,omitempty"`
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// Validate checks the rule can be evaluated
+func (r Rule) Validate() error {
+	if r.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	switch r.Frequency {
+	case FrequencyDaily:
+		return nil
+	case FrequencyWeekly:
+		if len(r.Weekdays) == 0 {
+			return errors.New("weekly rules need at least one weekday")
+		}
+		for _, day := range r.Weekdays {
+			if weekdayIndex(day) < 0 {
+				return fmt.Errorf("unknown weekday %q", day)
+			}
+		}
+		return nil
+	case FrequencyCron:
+		_, err := parseCron(r.Cron)
+		return err
+	default:
+		return errors.New("frequency must be daily, weekly or cron")
+	}
+}
+
+// Matches reports whether the rule, starting on start, has an occurrence on date. Both are calendar days.
+func (r Rule) Matches(start, date time.Time) bool {
+	if date.Before(start) {
+		return false
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	days := daysBetween(start, date)
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		return days%interval == 0
+	case FrequencyWeekly:
+		// Weeks run Sunday to Saturday, counted from the week the schedule starts in
+		weeks := (days + int(start.Weekday())) / 7
+		if weeks%interval != 0 {
+			return false
+		}
+		for _, day := range r.Weekdays {
+			if weekdayIndex(day) == int(date.Weekday()) {
+				return true
+			}
+		}
+		return false
+	case FrequencyCron:
+		spec, err := parseCron(r.Cron)
+		return err == nil && spec.matches(date)
+	}
+	return false
+}
+
+// Next returns up to count occurrence dates on or after from
+func (r Rule) Next(start, from time.Time, count int) []time.Time {
+	if from.Before(start) {
+		from = start
+	}
+	var dates []time.Time
+	for day := 0; day < 366*2 && len(dates) < count; day++ {
+		date := from.AddDate(0, 0, day)
+		if r.Matches(start, date) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+func daysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func weekdayIndex(name string) int {
+	name = strings.ToLower(name)
+	for i, short := range weekdayNames {
+		if name == short || name == strings.ToLower(time.Weekday(i).String()) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cronSpec is a parsed "day-of-month month day-of-week" expression
+type cronSpec struct {
+	days, months, weekdays map[int]bool
+	anyDay, anyWeekday     bool
+}
+
+// matches follows cron: when both day-of-month and day-of-week are restricted, either may match
+func (c cronSpec) matches(date time.Time) bool {
+	if !c.months[int(date.Month())] {
+		return false
+	}
+	dayMatch, weekdayMatch := c.days[date.Day()], c.weekdays[int(date.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekdayMatch
+	case c.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 3 {
+		return cronSpec{}, errors.New(`cron must have three fields: day-of-month month day-of-week (e.g. "* * mon,thu")`)
+	}
+
+	var spec cronSpec
+	var err error
+	if spec.days, err = parseCronField(fields[0], 1, 31, nil); err != nil {
+		return cronSpec{}, fmt.Errorf("day-of-month: %w", err)
+	}
+	if spec.months, err = parseCronField(fields[1], 1, 12, monthNames); err != nil {
+		return cronSpec{}, fmt.Errorf("month: %w", err)
+	}
+	if spec.weekdays, err = parseCronField(fields[2], 0, 7, weekdayNames); err != nil {
+		return cronSpec{}, fmt.Errorf("day-of-week: %w", err)
+	}
+	// 7 is another name for Sunday
+	if spec.weekdays[7] {
+		spec.weekdays[0] = true
+	}
+	spec.anyDay, spec.anyWeekday = fields[0] == "*", fields[2] == "*"
+	return spec, nil
+}
+
+// parseCronField expands a comma-separated list of *, values, ranges and steps (e.g. "*/2", "1-5", "mon,wed")
+func parseCronField(field string, min, max int, names []string) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = cronValue(from, min, max, names); err != nil {
+				return nil, err
+			}
+			high = low
+			if isRange {
+				if high, err = cronValue(to, min, max, names); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				high = max
+			}
+			if high < low {
+				return nil, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for value := low; value <= high; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func cronValue(text string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(text, name) {
+			return i + min, nil
+		}
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	return value, nil
+}
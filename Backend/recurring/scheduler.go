@@ -0,0 +1,217 @@
+package recurring
+
+import (
+	"PTS/geocode"
+	"PTS/utils"
+	"PTS/windows"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// Recurring order statuses
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+)
+
+// Occurrence statuses. Skipped occurrences are recorded by the customer ahead of time so the scheduler passes them by.
+const (
+	OccurrencePlaced  = "placed"
+	OccurrenceFailed  = "failed"
+	OccurrenceSkipped = "skipped"
+)
+
+// LeadDays is how many days ahead of its delivery date an occurrence is turned into an order
+var LeadDays = utils.GetEnvInt("RECURRING_ORDER_LEAD_DAYS", 2)
+
+// Occurrence is one delivery of a recurring order, due on Date (YYYY-MM-DD)
+type Occurrence struct {
+	RecurringID    string
+	UserID         string
+	StoreId        string
+	Pickup         string
+	DropOff        string
+	PickupAddress  *geocode.Address
+	DropOffAddress *geocode.Address
+	DeliveryWindow string
+	PackageDetails string
+	Date           string
+}
+
+// PlaceFunc places the order for an occurrence within tx and returns its ID.
+// It returns a *Failure when the order cannot be placed at all, such as when the window is full.
+type PlaceFunc func(tx *sql.Tx, occurrence Occurrence) (string, error)
+
+// Failure is a reason an occurrence could not be placed that retrying will not fix.
+// The occurrence is marked failed instead of being tried again on the next run.
+type Failure struct {
+	Reason string
+}
+
+func (f *Failure) Error() string {
+	return f.Reason
+}
+
+// Scheduler turns upcoming occurrences of active recurring orders into real orders
+type Scheduler struct {
+	place  PlaceFunc
+	placed func()
+}
+
+// NewScheduler creates a scheduler that places orders with place and calls placed after each one commits
+func NewScheduler(place PlaceFunc, placed func()) *Scheduler {
+	return &Scheduler{place: place, placed: placed}
+}
+
+// Run places due occurrences every interval
+func (s *Scheduler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.PlaceDue(time.Now())
+		<-ticker.C
+	}
+}
+
+// PlaceDue places every occurrence due within LeadDays of now that has not been handled yet
+func (s *Scheduler) PlaceDue(now time.Time) {
+	today := Today(now)
+	horizon := today.AddDate(0, 0, LeadDays)
+
+	query := `
+        SELECT id, user_id, store_id, pickup_location, drop_off_location, delivery_window, package_details, rule,
+               pickup_address, drop_off_address, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD')
+        FROM recurring_orders
+        WHERE status = $1 AND start_date <= $2 AND (end_date IS NULL OR end_date >= $3)
+    `
+	rows, err := utils.DB.Query(query, StatusActive, horizon.Format(time.DateOnly), today.Format(time.DateOnly))
+	if err != nil {
+		log.Println("Error loading recurring orders:", err)
+		return
+	}
+
+	type schedule struct {
+		occurrence Occurrence
+		rule       Rule
+		start, end time.Time
+	}
+	var schedules []schedule
+	for rows.Next() {
+		var sc schedule
+		var rule, pickup, dropOff []byte
+		var start string
+		var end *string
+		o := &sc.occurrence
+		err := rows.Scan(&o.RecurringID, &o.UserID, &o.StoreId, &o.Pickup, &o.DropOff, &o.DeliveryWindow, &o.PackageDetails, &rule, &pickup, &dropOff, &start, &end)
+		if err != nil {
+			log.Println("Error scanning recurring order:", err)
+			rows.Close()
+			return
+		}
+		if err := json.Unmarshal(rule, &sc.rule); err != nil {
+			log.Printf("Skipping recurring order %s with an unreadable rule: %v", o.RecurringID, err)
+			continue
+		}
+		if pickup != nil && dropOff != nil {
+			if err := json.Unmarshal(pickup, &o.PickupAddress); err != nil {
+				log.Printf("Skipping recurring order %s with an unreadable pickup address: %v", o.RecurringID, err)
+				continue
+			}
+			if err := json.Unmarshal(dropOff, &o.DropOffAddress); err != nil {
+				log.Printf("Skipping recurring order %s with an unreadable drop-off address: %v", o.RecurringID, err)
+				continue
+			}
+		}
+		sc.start, _ = time.ParseInLocation(time.DateOnly, start, time.UTC)
+		sc.end = horizon
+		if end != nil {
+			if sc.end, _ = time.ParseInLocation(time.DateOnly, *end, time.UTC); sc.end.After(horizon) {
+				sc.end = horizon
+			}
+		}
+		schedules = append(schedules, sc)
+	}
+	rows.Close()
+
+	for _, sc := range schedules {
+		for date := today; !date.After(sc.end); date = date.AddDate(0, 0, 1) {
+			if !sc.rule.Matches(sc.start, date) {
+				continue
+			}
+			occurrence := sc.occurrence
+			occurrence.Date = date.Format(time.DateOnly)
+			if err := s.placeOccurrence(occurrence); err != nil {
+				log.Printf("Error placing recurring order %s for %s: %v", occurrence.RecurringID, occurrence.Date, err)
+			}
+		}
+	}
+}
+
+// placeOccurrence claims the occurrence and places its order in one transaction.
+// Occurrences that were already placed, failed or skipped are left alone.
+func (s *Scheduler) placeOccurrence(occurrence Occurrence) error {
+	var orderID string
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		// Lock the schedule so a pause or cancel either lands first or waits for this order
+		var status string
+		if err := tx.QueryRow("SELECT status FROM recurring_orders WHERE id = $1 FOR UPDATE", occurrence.RecurringID).Scan(&status); err != nil {
+			return err
+		}
+		if status != StatusActive {
+			return nil
+		}
+
+		result, err := tx.Exec(`
+            INSERT INTO recurring_occurrences (recurring_id, occurrence_date, status, created_at)
+            VALUES ($1, $2, $3, $4)
+            ON CONFLICT (recurring_id, occurrence_date) DO NOTHING
+        `, occurrence.RecurringID, occurrence.Date, OccurrencePlaced, time.Now())
+		if err != nil {
+			return err
+		}
+		if claimed, _ := result.RowsAffected(); claimed == 0 {
+			return nil
+		}
+
+		// A failed order must not leave half its rows behind, but the failure itself should be kept
+		if _, err := tx.Exec("SAVEPOINT place_occurrence"); err != nil {
+			return err
+		}
+		id, err := s.place(tx, occurrence)
+		var failure *Failure
+		if errors.As(err, &failure) {
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT place_occurrence"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("UPDATE recurring_occurrences SET status = $1, reason = $2 WHERE recurring_id = $3 AND occurrence_date = $4",
+				OccurrenceFailed, failure.Reason, occurrence.RecurringID, occurrence.Date)
+			if err == nil {
+				log.Printf("Recurring order %s could not be placed for %s: %s", occurrence.RecurringID, occurrence.Date, failure.Reason)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		orderID = id
+		_, err = tx.Exec("UPDATE recurring_occurrences SET order_id = $1 WHERE recurring_id = $2 AND occurrence_date = $3",
+			id, occurrence.RecurringID, occurrence.Date)
+		return err
+	})
+	if err == nil && orderID != "" && s.placed != nil {
+		s.placed()
+	}
+	return err
+}
+
+// Today is the current calendar day in the delivery timezone, as a UTC midnight for date arithmetic
+func Today(now time.Time) time.Time {
+	year, month, day := now.In(windows.Location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,267 @@
+// Package routing sequences the pickups and drop-offs of a courier's orders. Finding the best order is
+// NP-hard, so a greedy tour is built and then improved by moving single stops while that lowers its cost.
+package routing
+
+import (
+	"PTS/eta"
+	"PTS/geo"
+	"errors"
+	"time"
+)
+
+// Stop kinds. A return takes the package back to the store after a failed delivery.
+const (
+	StopPickup  = "pickup"
+	StopDropOff = "drop_off"
+	StopReturn  = "return"
+)
+
+// lateWeight is how many minutes of travel one minute past a delivery window is worth avoiding
+const lateWeight = 10
+
+// maxImprovementPasses bounds the improvement phase for very long routes
+const maxImprovementPasses = 50
+
+// ErrNoStops is returned by Solve when there is nothing to route
+var ErrNoStops = errors.New("no stops to route")
+
+// Stop is a place the courier has to visit for an order
+type Stop struct {
+	OrderID  string    `json:"order_id"`
+	Kind     string    `json:"kind"`
+	Location geo.Point `json:"location"`
+	Address  string    `json:"address,omitempty"`
+	// Earliest and Latest are the delivery window of a drop-off; arriving early means waiting
+	Earliest *time.Time `json:"earliest,omitempty"`
+	Latest   *time.Time `json:"latest,omitempty"`
+
+	// Filled in by Solve: the distance from the previous stop and when the courier gets here
+	DistanceKm  float64   `json:"distance_km"`
+	ArriveAt    time.Time `json:"arrive_at"`
+	LateMinutes int       `json:"late_minutes,omitempty"`
+	// Completed is set when a saved route is read back after the courier has made the stop
+	Completed bool `json:"completed,omitempty"`
+}
+
+// Problem is what Solve sequences
+type Problem struct {
+	// Start is the courier's position; without one the route starts at its first stop
+	Start    *geo.Point
+	StartAt  time.Time
+	SpeedKmh float64
+	// Dwell is the time spent at each stop
+	Dwell  time.Duration
+	Router eta.Router
+	Stops  []Stop
+}
+
+// Route is the suggested sequence
+type Route struct {
+	Stops      []Stop    `json:"stops"`
+	DistanceKm float64   `json:"distance_km"`
+	FinishAt   time.Time `json:"finish_at"`
+	// LateStops counts drop-offs that cannot make their delivery window
+	LateStops int `json:"late_stops"`
+}
+
+// Solve orders the stops so every pickup comes before the drop-off of the same order, as few drop-offs as
+// possible miss their windows, and the courier spends as little time as possible on the road
+func Solve(p Problem) (Route, error) {
+	if len(p.Stops) == 0 {
+		return Route{}, ErrNoStops
+	}
+	if p.SpeedKmh <= 0 {
+		return Route{}, errors.New("speed must be positive")
+	}
+
+	s, err := newSolver(p)
+	if err != nil {
+		return Route{}, err
+	}
+	sequence := s.construct()
+	s.improve(sequence)
+	return s.route(sequence), nil
+}
+
+type solver struct {
+	problem Problem
+	// distances[i][j] is from stop i to stop j; index len(Stops) is the courier's start
+	distances [][]float64
+	// pickupOf[i] is the index of the pickup that has to come before stop i, or -1
+	pickupOf []int
+}
+
+func newSolver(p Problem) (*solver, error) {
+	n := len(p.Stops)
+	s := &solver{problem: p, distances: make([][]float64, n+1), pickupOf: make([]int, n)}
+
+	points := make([]geo.Point, n+1)
+	for i, stop := range p.Stops {
+		points[i] = stop.Location
+	}
+	for i := range s.distances {
+		s.distances[i] = make([]float64, n+1)
+		if i == n && p.Start == nil {
+			continue
+		}
+		from := points[i]
+		if i == n {
+			from = *p.Start
+		}
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			distance, err := p.Router.DistanceKm(from, points[j])
+			if err != nil {
+				return nil, err
+			}
+			s.distances[i][j] = distance
+		}
+	}
+
+	pickups := map[string]int{}
+	for i, stop := range p.Stops {
+		if stop.Kind == StopPickup {
+			pickups[stop.OrderID] = i
+		}
+	}
+	for i, stop := range p.Stops {
+		s.pickupOf[i] = -1
+		if stop.Kind == StopDropOff {
+			if pickup, ok := pickups[stop.OrderID]; ok {
+				s.pickupOf[i] = pickup
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *solver) travel(from, to int) time.Duration {
+	return time.Duration(s.distances[from][to] / s.problem.SpeedKmh * float64(time.Hour))
+}
+
+// cost walks the sequence and returns minutes on the road or waiting, plus weighted minutes late
+func (s *solver) cost(sequence []int) float64 {
+	at, previous := s.problem.StartAt, len(s.problem.Stops)
+	var late time.Duration
+	for _, i := range sequence {
+		at = at.Add(s.travel(previous, i))
+		stop := s.problem.Stops[i]
+		if stop.Earliest != nil && at.Before(*stop.Earliest) {
+			at = *stop.Earliest
+		}
+		if stop.Latest != nil && at.After(*stop.Latest) {
+			late += at.Sub(*stop.Latest)
+		}
+		at = at.Add(s.problem.Dwell)
+		previous = i
+	}
+	return at.Sub(s.problem.StartAt).Minutes() + late.Minutes()*lateWeight
+}
+
+// valid reports whether every drop-off in the sequence comes after its pickup
+func (s *solver) valid(sequence []int) bool {
+	position := make([]int, len(s.problem.Stops))
+	for p, i := range sequence {
+		position[i] = p
+	}
+	for i, pickup := range s.pickupOf {
+		if pickup >= 0 && position[pickup] > position[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// construct builds a tour by repeatedly going to the cheapest stop that can be visited next
+func (s *solver) construct() []int {
+	n := len(s.problem.Stops)
+	visited := make([]bool, n)
+	sequence := make([]int, 0, n)
+	for len(sequence) < n {
+		best, bestCost := -1, 0.0
+		for i := 0; i < n; i++ {
+			if visited[i] || (s.pickupOf[i] >= 0 && !visited[s.pickupOf[i]]) {
+				continue
+			}
+			if cost := s.cost(append(sequence, i)); best < 0 || cost < bestCost {
+				best, bestCost = i, cost
+			}
+		}
+		visited[best] = true
+		sequence = append(sequence, best)
+	}
+	return sequence
+}
+
+// improve moves single stops to other positions while that lowers the cost (or-opt)
+func (s *solver) improve(sequence []int) {
+	n := len(sequence)
+	candidate := make([]int, n)
+	best := s.cost(sequence)
+	for pass := 0; pass < maxImprovementPasses; pass++ {
+		improved := false
+		for from := 0; from < n; from++ {
+			for to := 0; to < n; to++ {
+				if from == to {
+					continue
+				}
+				move(candidate, sequence, from, to)
+				if !s.valid(candidate) {
+					continue
+				}
+				if cost := s.cost(candidate); cost < best-1e-9 {
+					copy(sequence, candidate)
+					best, improved = cost, true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+// move copies sequence into dst with the stop at from moved to position to
+func move(dst, sequence []int, from, to int) {
+	stop := sequence[from]
+	rest := dst[:0]
+	for i, value := range sequence {
+		if i != from {
+			rest = append(rest, value)
+		}
+	}
+	copy(dst[to+1:], rest[to:len(sequence)-1])
+	dst[to] = stop
+}
+
+// route fills in the stops' distances and arrival times
+func (s *solver) route(sequence []int) Route {
+	route := Route{Stops: make([]Stop, 0, len(sequence))}
+	at, previous := s.problem.StartAt, len(s.problem.Stops)
+	for _, i := range sequence {
+		stop := s.problem.Stops[i]
+		stop.DistanceKm = round2(s.distances[previous][i])
+		at = at.Add(s.travel(previous, i)).Round(time.Second)
+		stop.ArriveAt = at
+		if stop.Earliest != nil && at.Before(*stop.Earliest) {
+			at = *stop.Earliest
+		}
+		if stop.Latest != nil && at.After(*stop.Latest) {
+			stop.LateMinutes = int(at.Sub(*stop.Latest).Minutes() + 0.5)
+			route.LateStops++
+		}
+		at = at.Add(s.problem.Dwell)
+		route.DistanceKm += s.distances[previous][i]
+		route.Stops = append(route.Stops, stop)
+		previous = i
+	}
+	route.DistanceKm = round2(route.DistanceKm)
+	route.FinishAt = at
+	return route
+}
+
+func round2(value float64) float64 {
+	return float64(int64(value*100+0.5)) / 100
+}
@@ -0,0 +1,195 @@
+// Package settlements keeps each courier's ledger of cash collected on delivery and reconciles it
+// against the cash handed in to the store at the end of a shift.
+package settlements
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"database/sql"
+	"time"
+)
+
+// Ledger entry types. Collections are positive amounts owed by the courier; handovers are negative.
+const (
+	EntryCollection = "collection"
+	EntryHandover   = "handover"
+)
+
+const ledgerColumns = "id, courier_id, store_id, entry_type, amount, currency, order_id, payment_id, settlement_id, shift_id, created_at"
+
+const settlementColumns = "id, courier_id, store_id, shift_id, expected_amount, handed_in_amount, discrepancy, note, settled_by, created_at"
+
+// reportQuery totals each courier's cash between $1 and $2; callers append the courier filter
+const reportQuery = `
+    SELECT c.id,
+        COALESCE((SELECT SUM(amount) FROM courier_cash_ledger l
+            WHERE l.courier_id = c.id AND l.entry_type = 'collection' AND l.created_at >= $1 AND l.created_at < $2), 0),
+        COALESCE((SELECT -SUM(amount) FROM courier_cash_ledger l
+            WHERE l.courier_id = c.id AND l.entry_type = 'handover' AND l.created_at >= $1 AND l.created_at < $2), 0),
+        COALESCE((SELECT SUM(discrepancy) FROM cash_settlements s
+            WHERE s.courier_id = c.id AND s.created_at >= $1 AND s.created_at < $2), 0),
+        (SELECT COUNT(*) FROM cash_settlements s
+            WHERE s.courier_id = c.id AND s.created_at >= $1 AND s.created_at < $2),
+        COALESCE((SELECT SUM(amount) FROM courier_cash_ledger l
+            WHERE l.courier_id = c.id AND l.entry_type = 'collection' AND l.settlement_id IS NULL), 0)
+    FROM couriers c
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// RecordCollection adds the cash a courier confirmed collecting for an order to the courier's ledger,
+// against their open shift if they have one. It does nothing if the order has no courier.
+func RecordCollection(tx *sql.Tx, payment *models.Payment, amount int64) error {
+	query := `
+        INSERT INTO courier_cash_ledger (courier_id, store_id, entry_type, amount, currency, order_id, payment_id, shift_id, created_at)
+        SELECT o.courier_id, o.store_id, 'collection', $1::BIGINT, $2::TEXT, o.id, $3::UUID,
+            (SELECT id FROM courier_shifts s WHERE s.courier_id = o.courier_id AND s.ended_at IS NULL ORDER BY s.started_at DESC LIMIT 1),
+            $4::TIMESTAMP
+        FROM orders o
+        WHERE o.id = $5 AND o.courier_id IS NOT NULL
+    `
+	_, err := tx.Exec(query, amount, payment.Currency, payment.ID, time.Now(), payment.OrderID)
+	return err
+}
+
+// Settle records the cash a courier handed in. Every collection not yet settled is closed against the
+// settlement, and the difference between what was handed in and what was expected is kept as the discrepancy.
+// The settlement is linked to the courier's most recent shift.
+func Settle(courierID, storeID string, handedIn int64, note *string, settledBy string) (*models.CashSettlement, error) {
+	var settlement models.CashSettlement
+	err := utils.WithTx(func(tx *sql.Tx) error {
+		// Serialise settlements per courier so a collection can only be settled once
+		if _, err := tx.Exec("SELECT id FROM couriers WHERE id = $1 FOR UPDATE", courierID); err != nil {
+			return err
+		}
+
+		var expected int64
+		var currency sql.NullString
+		query := `
+            SELECT COALESCE(SUM(amount), 0), MAX(currency) FROM courier_cash_ledger
+            WHERE courier_id = $1 AND entry_type = 'collection' AND settlement_id IS NULL
+        `
+		if err := tx.QueryRow(query, courierID).Scan(&expected, &currency); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		insertQuery := `
+            INSERT INTO cash_settlements (courier_id, store_id, shift_id, expected_amount, handed_in_amount, discrepancy, note, settled_by, created_at)
+            SELECT $1::UUID, $2::UUID,
+                (SELECT id FROM courier_shifts WHERE courier_id = $1::UUID ORDER BY started_at DESC LIMIT 1),
+                $3::BIGINT, $4::BIGINT, $4::BIGINT - $3::BIGINT, $5::TEXT, $6::UUID, $7::TIMESTAMP
+            RETURNING ` + settlementColumns
+		row := tx.QueryRow(insertQuery, courierID, storeID, expected, handedIn, note, settledBy, now)
+		if err := scanSettlement(row, &settlement); err != nil {
+			return err
+		}
+
+		updateQuery := `
+            UPDATE courier_cash_ledger SET settlement_id = $1
+            WHERE courier_id = $2 AND entry_type = 'collection' AND settlement_id IS NULL
+        `
+		if _, err := tx.Exec(updateQuery, settlement.ID, courierID); err != nil {
+			return err
+		}
+
+		handoverQuery := `
+            INSERT INTO courier_cash_ledger (courier_id, store_id, entry_type, amount, currency, settlement_id, shift_id, created_at)
+            VALUES ($1, $2, 'handover', $3, $4, $5, $6, $7)
+        `
+		_, err := tx.Exec(handoverQuery, courierID, storeID, -handedIn, currency.String, settlement.ID, settlement.ShiftID, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// Ledger returns a courier's ledger entries between from and to, newest first
+func Ledger(courierID string, from, to time.Time) ([]models.CashLedgerEntry, error) {
+	query := "SELECT " + ledgerColumns + " FROM courier_cash_ledger WHERE courier_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY created_at DESC"
+	rows, err := utils.DB.Query(query, courierID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.CashLedgerEntry{}
+	for rows.Next() {
+		var entry models.CashLedgerEntry
+		err := rows.Scan(&entry.ID, &entry.CourierID, &entry.StoreId, &entry.EntryType, &entry.Amount, &entry.Currency,
+			&entry.OrderID, &entry.PaymentID, &entry.SettlementID, &entry.ShiftID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Settlements returns a courier's settlements between from and to, newest first
+func Settlements(courierID string, from, to time.Time) ([]models.CashSettlement, error) {
+	query := "SELECT " + settlementColumns + " FROM cash_settlements WHERE courier_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY created_at DESC"
+	rows, err := utils.DB.Query(query, courierID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.CashSettlement{}
+	for rows.Next() {
+		var settlement models.CashSettlement
+		if err := scanSettlement(rows, &settlement); err != nil {
+			return nil, err
+		}
+		list = append(list, settlement)
+	}
+	return list, rows.Err()
+}
+
+// CourierReport totals one courier's collections, handovers and discrepancies between from and to.
+// Outstanding is everything collected and not yet settled, regardless of the period.
+func CourierReport(courierID string, from, to time.Time) (*models.CourierCashReport, error) {
+	var report models.CourierCashReport
+	if err := scanReport(utils.DB.QueryRow(reportQuery+" WHERE c.id = $3", from, to, courierID), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// StoreReport totals the cash of every courier of the store between from and to
+func StoreReport(storeID string, from, to time.Time) (*models.StoreCashReport, error) {
+	rows, err := utils.DB.Query(reportQuery+" WHERE c.store_id = $3 ORDER BY c.id", from, to, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := models.StoreCashReport{StoreId: storeID, From: from, To: to, Couriers: []models.CourierCashReport{}}
+	for rows.Next() {
+		var courier models.CourierCashReport
+		if err := scanReport(rows, &courier); err != nil {
+			return nil, err
+		}
+		report.Collected += courier.Collected
+		report.HandedIn += courier.HandedIn
+		report.Discrepancy += courier.Discrepancy
+		report.Outstanding += courier.Outstanding
+		report.Couriers = append(report.Couriers, courier)
+	}
+	return &report, rows.Err()
+}
+
+func scanReport(row rowScanner, report *models.CourierCashReport) error {
+	return row.Scan(&report.CourierID, &report.Collected, &report.HandedIn, &report.Discrepancy, &report.Settlements, &report.Outstanding)
+}
+
+func scanSettlement(row rowScanner, settlement *models.CashSettlement) error {
+	return row.Scan(
+		&settlement.ID, &settlement.CourierID, &settlement.StoreId, &settlement.ShiftID, &settlement.ExpectedAmount,
+		&settlement.HandedInAmount, &settlement.Discrepancy, &settlement.Note, &settlement.SettledBy, &settlement.CreatedAt,
+	)
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type contextKey string
+
+const emailContextKey contextKey = "email"
+
+// ParseJWT validates a JWT token and returns the email it was issued for
+func ParseJWT(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("invalid token claims")
+	}
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return "", errors.New("token has no email claim")
+	}
+
+	return email, nil
+}
+
+// RequireAuth rejects requests without a valid Bearer token and stores the caller's email in the request context
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			http.Error(w, "Missing or invalid authorization header", http.StatusUnauthorized)
+			return
+		}
+
+		email, err := ParseJWT(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), emailContextKey, email)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// RequireStreamAuth is RequireAuth for streaming endpoints. Browsers cannot set headers on an
+// EventSource, so the token may also be passed as the access_token query parameter.
+func RequireStreamAuth(next http.HandlerFunc) http.HandlerFunc {
+	requireAuth := RequireAuth(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		requireAuth(w, r)
+	}
+}
+
+// EmailFromContext returns the authenticated email stored by RequireAuth
+func EmailFromContext(ctx context.Context) (string, bool) {
+	email, ok := ctx.Value(emailContextKey).(string)
+	return email, ok
+}
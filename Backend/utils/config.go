@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv returns the value of an environment variable, or the fallback if it is unset
+func GetEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvDuration parses an environment variable as a duration (e.g. "10m"), or returns the fallback
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using default %s", key, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// GetEnvInt parses an environment variable as an integer, or returns the fallback
+func GetEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s (%q), using default %d", key, value, fallback)
+		return fallback
+	}
+	return number
+}
@@ -19,13 +19,16 @@ const (
 
 var DB *sql.DB
 
-// ConnectDB initializes the database connection
-func ConnectDB() {
-	connStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=%s",
+// ConnString is the connection string for the database, also used by connections that LISTEN for notifications
+func ConnString() string {
+	return fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=%s",
 		DBUser, DBPassword, DBName, DBHost, DBPort, SSLMode)
+}
 
+// ConnectDB initializes the database connection
+func ConnectDB() {
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open("postgres", ConnString())
 	if err != nil {
 		log.Fatal("Error opening database: ", err)
 	}
@@ -37,3 +40,17 @@ func ConnectDB() {
 
 	fmt.Println("Successfully connected to the database")
 }
+
+// WithTx runs fn inside a transaction, committing if it returns nil and rolling back otherwise
+func WithTx(fn func(tx *sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
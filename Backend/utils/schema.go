@@ -0,0 +1,503 @@
+package utils
+
+import (
+	"log"
+)
+
+// schemaStatements holds the tables and columns added on top of the base PTS schema
+// (users, stores, couriers, admins, owners). Every statement must be idempotent.
+var schemaStatements = []string{
+	// Courier availability and shifts
+	`ALTER TABLE couriers ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'off_shift'`,
+	`CREATE TABLE IF NOT EXISTS courier_shifts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS courier_shifts_open_idx ON courier_shifts (courier_id) WHERE ended_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS courier_breaks (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		shift_id UUID NOT NULL REFERENCES courier_shifts(id),
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP
+	)`,
+
+	// Orders
+	`CREATE TABLE IF NOT EXISTS orders (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		courier_id UUID REFERENCES couriers(id),
+		pickup_location TEXT NOT NULL,
+		drop_off_location TEXT NOT NULL,
+		delivery_window TEXT NOT NULL,
+		package_details TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS orders_user_idx ON orders (user_id)`,
+	`CREATE INDEX IF NOT EXISTS orders_store_idx ON orders (store_id)`,
+	`CREATE INDEX IF NOT EXISTS orders_courier_idx ON orders (courier_id)`,
+
+	// Courier location pings
+	`CREATE TABLE IF NOT EXISTS courier_locations (
+		id BIGSERIAL PRIMARY KEY,
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		accuracy REAL,
+		speed REAL,
+		recorded_at TIMESTAMP NOT NULL,
+		received_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS courier_locations_courier_time_idx ON courier_locations (courier_id, recorded_at DESC)`,
+
+	// Outgoing webhooks
+	`CREATE TABLE IF NOT EXISTS webhook_endpoints (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT[] NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		event_type TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_response_status INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (endpoint_id, event_id)`,
+	`CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+		id BIGSERIAL PRIMARY KEY,
+		delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		attempted_at TIMESTAMP NOT NULL,
+		response_status INTEGER,
+		error TEXT,
+		duration_ms BIGINT NOT NULL
+	)`,
+
+	// Transactional outbox of order domain events
+	`CREATE TABLE IF NOT EXISTS outbox_events (
+		id UUID PRIMARY KEY,
+		event_type TEXT NOT NULL,
+		order_id UUID NOT NULL,
+		store_id UUID NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		published_sinks TEXT[] NOT NULL DEFAULT '{}',
+		published_at TIMESTAMP,
+		last_error TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (next_attempt_at) WHERE published_at IS NULL`,
+
+	// Notifications
+	`CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id UUID NOT NULL REFERENCES users(id),
+		channel TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_id, channel)
+	)`,
+	`CREATE TABLE IF NOT EXISTS notification_jobs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		event_id UUID NOT NULL,
+		user_id UUID NOT NULL REFERENCES users(id),
+		channel TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		order_id UUID,
+		subject TEXT NOT NULL,
+		body TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		sent_at TIMESTAMP,
+		UNIQUE (event_id, user_id, channel)
+	)`,
+	`CREATE INDEX IF NOT EXISTS notification_jobs_due_idx ON notification_jobs (next_attempt_at) WHERE status = 'pending'`,
+	`CREATE TABLE IF NOT EXISTS notifications (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		event_id UUID,
+		user_id UUID NOT NULL REFERENCES users(id),
+		event_type TEXT NOT NULL,
+		order_id UUID,
+		title TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		read_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, created_at DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS notifications_event_user_idx ON notifications (event_id, user_id)`,
+
+	// Notification inbox
+	`CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL`,
+
+	// Delivery price quotes
+	`CREATE TABLE IF NOT EXISTS price_quotes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		request JSONB NOT NULL,
+		price JSONB NOT NULL,
+		total BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		order_id UUID,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS quote_id UUID`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS price_total BIGINT`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS price_currency TEXT`,
+
+	// Store rate cards
+	`CREATE TABLE IF NOT EXISTS rate_cards (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		version INTEGER NOT NULL,
+		card JSONB NOT NULL,
+		created_by UUID REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (store_id, version)
+	)`,
+	`ALTER TABLE price_quotes ADD COLUMN IF NOT EXISTS rate_card_id UUID REFERENCES rate_cards(id)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS rate_card_id UUID REFERENCES rate_cards(id)`,
+
+	// Promo codes
+	`CREATE TABLE IF NOT EXISTS promo_codes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		code TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		value BIGINT NOT NULL,
+		max_discount BIGINT,
+		min_order_value BIGINT NOT NULL DEFAULT 0,
+		max_redemptions BIGINT,
+		max_per_user BIGINT,
+		redemption_count BIGINT NOT NULL DEFAULT 0,
+		starts_at TIMESTAMP,
+		ends_at TIMESTAMP,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (store_id, code)
+	)`,
+	`CREATE TABLE IF NOT EXISTS promo_redemptions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		promo_id UUID NOT NULL REFERENCES promo_codes(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id),
+		discount BIGINT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS promo_redemptions_user_idx ON promo_redemptions (promo_id, user_id)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code_id UUID REFERENCES promo_codes(id)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_total BIGINT`,
+
+	// Payments
+	`CREATE TABLE IF NOT EXISTS payments (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		order_id UUID NOT NULL REFERENCES orders(id),
+		method TEXT NOT NULL,
+		status TEXT NOT NULL,
+		amount BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		captured_amount BIGINT NOT NULL DEFAULT 0,
+		refunded_amount BIGINT NOT NULL DEFAULT 0,
+		provider_reference TEXT,
+		failure_reason TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS payments_active_order_idx ON payments (order_id) WHERE status <> 'failed'`,
+	`CREATE TABLE IF NOT EXISTS payment_operations (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		payment_id UUID NOT NULL REFERENCES payments(id),
+		kind TEXT NOT NULL,
+		idempotency_key TEXT NOT NULL,
+		amount BIGINT NOT NULL,
+		performed_by UUID REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (payment_id, kind, idempotency_key)
+	)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_status TEXT`,
+
+	// Courier cash reconciliation
+	`CREATE TABLE IF NOT EXISTS cash_settlements (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		shift_id UUID REFERENCES courier_shifts(id),
+		expected_amount BIGINT NOT NULL,
+		handed_in_amount BIGINT NOT NULL,
+		discrepancy BIGINT NOT NULL,
+		note TEXT,
+		settled_by UUID NOT NULL REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS cash_settlements_courier_idx ON cash_settlements (courier_id, created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS courier_cash_ledger (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		entry_type TEXT NOT NULL,
+		amount BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		order_id UUID REFERENCES orders(id),
+		payment_id UUID REFERENCES payments(id),
+		settlement_id UUID REFERENCES cash_settlements(id),
+		shift_id UUID REFERENCES courier_shifts(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS courier_cash_ledger_courier_idx ON courier_cash_ledger (courier_id, created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS courier_cash_ledger_unsettled_idx ON courier_cash_ledger (courier_id) WHERE settlement_id IS NULL`,
+
+	// Courier earnings
+	`CREATE TABLE IF NOT EXISTS courier_pay_rules (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		version INTEGER NOT NULL,
+		rules JSONB NOT NULL,
+		created_by UUID REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (store_id, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS courier_earnings (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		order_id UUID REFERENCES orders(id),
+		kind TEXT NOT NULL,
+		description TEXT NOT NULL,
+		amount BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		pay_rules_id UUID REFERENCES courier_pay_rules(id),
+		earned_on DATE NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS courier_earnings_courier_idx ON courier_earnings (courier_id, earned_on)`,
+	`CREATE INDEX IF NOT EXISTS courier_earnings_store_idx ON courier_earnings (store_id, earned_on)`,
+
+	// Invoices
+	`CREATE TABLE IF NOT EXISTS invoice_counters (
+		store_id UUID PRIMARY KEY REFERENCES stores(id),
+		last_number BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS invoices (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		number BIGINT NOT NULL,
+		invoice_number TEXT NOT NULL,
+		currency TEXT NOT NULL,
+		lines JSONB NOT NULL,
+		subtotal BIGINT NOT NULL,
+		discount BIGINT NOT NULL,
+		tax_lines JSONB NOT NULL,
+		tax_total BIGINT NOT NULL,
+		total BIGINT NOT NULL,
+		issued_at TIMESTAMP NOT NULL,
+		UNIQUE (store_id, number)
+	)`,
+	`CREATE INDEX IF NOT EXISTS invoices_store_issued_idx ON invoices (store_id, issued_at)`,
+
+	// Proof of delivery
+	`CREATE TABLE IF NOT EXISTS proofs_of_delivery (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		recipient_name TEXT NOT NULL,
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		captured_at TIMESTAMP NOT NULL,
+		pin_verified BOOLEAN NOT NULL DEFAULT FALSE,
+		signature_key TEXT,
+		signature_content_type TEXT,
+		photo_key TEXT,
+		photo_content_type TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+
+	// Delivery handoff codes and their override audit trail
+	`CREATE TABLE IF NOT EXISTS delivery_codes (
+		order_id UUID PRIMARY KEY REFERENCES orders(id),
+		code TEXT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		verified_at TIMESTAMP,
+		overridden_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS delivery_code_overrides (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		order_id UUID NOT NULL REFERENCES orders(id),
+		overridden_by UUID NOT NULL REFERENCES users(id),
+		role TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS delivery_code_overrides_order_idx ON delivery_code_overrides (order_id, created_at)`,
+
+	// Failed delivery attempts and rescheduling
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS delivery_date DATE`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS delivery_attempts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		order_id UUID NOT NULL REFERENCES orders(id),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		attempt_number INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		note TEXT,
+		lat DOUBLE PRECISION,
+		lng DOUBLE PRECISION,
+		outcome TEXT NOT NULL,
+		rescheduled_window TEXT,
+		rescheduled_date DATE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (order_id, attempt_number)
+	)`,
+
+	// Delivery window schedules and capacity bookings
+	`CREATE TABLE IF NOT EXISTS delivery_schedules (
+		store_id UUID PRIMARY KEY REFERENCES stores(id),
+		schedule JSONB NOT NULL,
+		updated_by UUID NOT NULL REFERENCES users(id),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS delivery_window_bookings (
+		store_id UUID NOT NULL REFERENCES stores(id),
+		delivery_date DATE NOT NULL,
+		window_name TEXT NOT NULL,
+		booked INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (store_id, delivery_date, window_name)
+	)`,
+
+	// Recurring orders and the occurrences the scheduler has handled
+	`CREATE TABLE IF NOT EXISTS recurring_orders (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		pickup_location TEXT NOT NULL,
+		drop_off_location TEXT NOT NULL,
+		delivery_window TEXT NOT NULL,
+		package_details TEXT NOT NULL,
+		rule JSONB NOT NULL,
+		start_date DATE NOT NULL,
+		end_date DATE,
+		status TEXT NOT NULL DEFAULT 'active',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS recurring_orders_user_idx ON recurring_orders (user_id, created_at)`,
+	`CREATE INDEX IF NOT EXISTS recurring_orders_active_idx ON recurring_orders (status) WHERE status = 'active'`,
+	`CREATE TABLE IF NOT EXISTS recurring_occurrences (
+		recurring_id UUID NOT NULL REFERENCES recurring_orders(id),
+		occurrence_date DATE NOT NULL,
+		status TEXT NOT NULL,
+		order_id UUID REFERENCES orders(id),
+		reason TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (recurring_id, occurrence_date)
+	)`,
+
+	// Address book and geocoded address snapshots on orders
+	`CREATE TABLE IF NOT EXISTS addresses (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		label TEXT NOT NULL,
+		line1 TEXT NOT NULL,
+		line2 TEXT NOT NULL DEFAULT '',
+		district TEXT NOT NULL DEFAULT '',
+		city TEXT NOT NULL,
+		region TEXT NOT NULL DEFAULT '',
+		postal_code TEXT NOT NULL DEFAULT '',
+		country TEXT NOT NULL DEFAULT '',
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		precision TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS addresses_user_idx ON addresses (user_id, created_at)`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_address JSONB`,
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS drop_off_address JSONB`,
+	`ALTER TABLE recurring_orders ADD COLUMN IF NOT EXISTS pickup_address JSONB`,
+	`ALTER TABLE recurring_orders ADD COLUMN IF NOT EXISTS drop_off_address JSONB`,
+
+	// Store service zones (GeoJSON areas)
+	`CREATE TABLE IF NOT EXISTS service_zones (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		store_id UUID NOT NULL REFERENCES stores(id),
+		name TEXT NOT NULL,
+		area JSONB NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (store_id, name)
+	)`,
+
+	// Delivery ETAs: when orders were picked up, and how long stores' deliveries actually took
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS picked_up_at TIMESTAMP`,
+	`CREATE TABLE IF NOT EXISTS eta_observations (
+		id BIGSERIAL PRIMARY KEY,
+		store_id UUID NOT NULL REFERENCES stores(id),
+		order_id UUID NOT NULL UNIQUE REFERENCES orders(id),
+		vehicle_type TEXT NOT NULL,
+		distance_km DOUBLE PRECISION NOT NULL,
+		duration_seconds DOUBLE PRECISION NOT NULL,
+		observed_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS eta_observations_store_idx ON eta_observations (store_id, vehicle_type, observed_at)`,
+
+	// Courier routes: suggested and accepted sequences of pickups and drop-offs
+	`CREATE TABLE IF NOT EXISTS courier_routes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		courier_id UUID NOT NULL REFERENCES couriers(id),
+		status TEXT NOT NULL,
+		stops JSONB NOT NULL,
+		unrouted_orders JSONB NOT NULL,
+		distance_km DOUBLE PRECISION NOT NULL,
+		late_stops INT NOT NULL,
+		finish_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		accepted_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS courier_routes_courier_idx ON courier_routes (courier_id, status)`,
+
+	// Package weight and dimensions, checked against the courier's vehicle when orders are assigned
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS package_size JSONB`,
+
+	// When the order's package last set off with its courier; tracking only shows the courier's trail from then
+	`ALTER TABLE orders ADD COLUMN IF NOT EXISTS tracking_since TIMESTAMP`,
+	`UPDATE orders SET tracking_since = picked_up_at WHERE tracking_since IS NULL AND status IN ('picked_up', 'in_transit')`,
+
+	// The service zone a quote was priced for, checked when the quote is redeemed
+	`ALTER TABLE price_quotes ADD COLUMN IF NOT EXISTS zone TEXT`,
+}
+
+// EnsureSchema creates any missing tables and columns used by the API
+func EnsureSchema() {
+	for _, statement := range schemaStatements {
+		if _, err := DB.Exec(statement); err != nil {
+			log.Fatal("Error applying database schema: ", err)
+		}
+	}
+
+	log.Println("Database schema is up to date")
+}
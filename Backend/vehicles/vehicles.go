@@ -0,0 +1,176 @@
+// Package vehicles describes what each type of courier vehicle can carry and packs orders into loads that fit.
+// Orders placed without package dimensions take no room, since there is nothing to measure them by.
+package vehicles
+
+import (
+	"PTS/pricing"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Profile is what one vehicle type can carry at once
+type Profile struct {
+	Type            string  `json:"type"`
+	MaxWeightKg     float64 `json:"max_weight_kg"`
+	MaxVolumeLiters float64 `json:"max_volume_liters"`
+	// MaxLengthCm is the longest package the vehicle takes
+	MaxLengthCm float64 `json:"max_length_cm"`
+}
+
+// Profiles are the vehicle types couriers can register with, smallest first
+var Profiles = []Profile{
+	{Type: pricing.VehicleBike, MaxWeightKg: 10, MaxVolumeLiters: 40, MaxLengthCm: 50},
+	{Type: pricing.VehicleMotorcycle, MaxWeightKg: 25, MaxVolumeLiters: 90, MaxLengthCm: 70},
+	{Type: pricing.VehicleCar, MaxWeightKg: 150, MaxVolumeLiters: 400, MaxLengthCm: 150},
+	{Type: pricing.VehicleVan, MaxWeightKg: 800, MaxVolumeLiters: 3000, MaxLengthCm: 300},
+}
+
+// Lookup returns the profile of a vehicle type, ignoring case
+func Lookup(vehicleType string) (Profile, bool) {
+	vehicleType = strings.ToLower(strings.TrimSpace(vehicleType))
+	for _, profile := range Profiles {
+		if profile.Type == vehicleType {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Types lists the vehicle types, for error messages
+func Types() string {
+	types := make([]string, len(Profiles))
+	for i, profile := range Profiles {
+		types[i] = profile.Type
+	}
+	return strings.Join(types, ", ")
+}
+
+// Package is the weight and outer dimensions of an order's package
+type Package struct {
+	WeightKg float64 `json:"weight_kg"`
+	LengthCm float64 `json:"length_cm"`
+	WidthCm  float64 `json:"width_cm"`
+	HeightCm float64 `json:"height_cm"`
+}
+
+// Validate checks the package has real measurements and fits the largest vehicle
+func (p Package) Validate() error {
+	for _, value := range []float64{p.WeightKg, p.LengthCm, p.WidthCm, p.HeightCm} {
+		if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return errors.New("package weight and dimensions must be positive")
+		}
+	}
+	largest := Profiles[len(Profiles)-1]
+	if err := largest.Check(Load{}, &p); err != nil {
+		return fmt.Errorf("package is too big for any vehicle: %w", err)
+	}
+	return nil
+}
+
+// VolumeLiters is the volume of the package's bounding box
+func (p Package) VolumeLiters() float64 {
+	return p.LengthCm * p.WidthCm * p.HeightCm / 1000
+}
+
+// LongestSideCm is the package's largest dimension
+func (p Package) LongestSideCm() float64 {
+	return math.Max(p.LengthCm, math.Max(p.WidthCm, p.HeightCm))
+}
+
+// sizeClasses is the pricing size class of packages that first fit each vehicle type
+var sizeClasses = map[string]string{
+	pricing.VehicleBike:       pricing.SizeSmall,
+	pricing.VehicleMotorcycle: pricing.SizeMedium,
+	pricing.VehicleCar:        pricing.SizeLarge,
+	pricing.VehicleVan:        pricing.SizeXL,
+}
+
+// SizeClass is the pricing size class of the package, from the smallest vehicle its dimensions fit in.
+// Weight is priced separately and does not affect it.
+func (p Package) SizeClass() string {
+	for _, profile := range Profiles {
+		if p.LongestSideCm() <= profile.MaxLengthCm && p.VolumeLiters() <= profile.MaxVolumeLiters {
+			return sizeClasses[profile.Type]
+		}
+	}
+	return pricing.SizeXL
+}
+
+// Load is what a courier is carrying or has been assigned
+type Load struct {
+	Orders       int     `json:"orders"`
+	WeightKg     float64 `json:"weight_kg"`
+	VolumeLiters float64 `json:"volume_liters"`
+}
+
+// Add returns the load with one more order; pkg may be nil
+func (l Load) Add(pkg *Package) Load {
+	l.Orders++
+	if pkg != nil {
+		l.WeightKg += pkg.WeightKg
+		l.VolumeLiters += pkg.VolumeLiters()
+	}
+	return l
+}
+
+// CapacityError is returned when a vehicle cannot take more
+type CapacityError struct {
+	Vehicle string
+	Reason  string
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("a %s cannot carry this: %s", e.Vehicle, e.Reason)
+}
+
+// Check returns a *CapacityError if the vehicle cannot take pkg on top of load. pkg may be nil.
+func (pr Profile) Check(load Load, pkg *Package) error {
+	if pkg == nil {
+		return nil
+	}
+	if pkg.LongestSideCm() > pr.MaxLengthCm {
+		return &CapacityError{pr.Type, fmt.Sprintf("the package is %.0f cm long, over the %.0f cm limit", pkg.LongestSideCm(), pr.MaxLengthCm)}
+	}
+	after := load.Add(pkg)
+	if after.WeightKg > pr.MaxWeightKg {
+		return &CapacityError{pr.Type, fmt.Sprintf("%.1f kg would exceed the %.0f kg limit", after.WeightKg, pr.MaxWeightKg)}
+	}
+	if after.VolumeLiters > pr.MaxVolumeLiters {
+		return &CapacityError{pr.Type, fmt.Sprintf("%.1f l would exceed the %.0f l limit", after.VolumeLiters, pr.MaxVolumeLiters)}
+	}
+	return nil
+}
+
+// Batch picks which of the packages to add to a vehicle already carrying load. The lightest and smallest
+// are taken first, which fits as many orders as possible. It returns the chosen indexes in their original
+// order and the resulting load.
+func (pr Profile) Batch(load Load, packages []*Package) ([]int, Load) {
+	indexes := make([]int, len(packages))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	size := func(pkg *Package) (float64, float64) {
+		if pkg == nil {
+			return 0, 0
+		}
+		return pkg.WeightKg / pr.MaxWeightKg, pkg.VolumeLiters() / pr.MaxVolumeLiters
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		weightA, volumeA := size(packages[indexes[a]])
+		weightB, volumeB := size(packages[indexes[b]])
+		return math.Max(weightA, volumeA) < math.Max(weightB, volumeB)
+	})
+
+	var chosen []int
+	for _, i := range indexes {
+		if pr.Check(load, packages[i]) == nil {
+			load = load.Add(packages[i])
+			chosen = append(chosen, i)
+		}
+	}
+	sort.Ints(chosen)
+	return chosen, load
+}
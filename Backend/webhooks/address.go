@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for endpoints that resolve to loopback, link-local, private or otherwise
+// internal addresses, which store owners must not be able to reach through the API
+var ErrBlockedAddress = errors.New("endpoint address is not allowed")
+
+// blockedNetworks are ranges not covered by the net.IP checks in allowedIP
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"100.64.0.0/10",  // carrier-grade NAT
+	"192.0.0.0/24",   // IETF protocol assignments
+	"198.18.0.0/15",  // benchmarking
+	"240.0.0.0/4",    // reserved
+	"64:ff9b::/96",   // NAT64, which can map to internal IPv4 addresses
+	"64:ff9b:1::/48", // local-use NAT64
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// allowedIP reports whether webhooks may be sent to ip
+func allowedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that a webhook URL is an absolute http or https URL whose host resolves only to public
+// addresses. The address is checked again when each delivery is sent, as DNS can change in between.
+func CheckURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("URL must be an absolute http or https URL")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrBlockedAddress
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("could not resolve host %q", parsed.Hostname())
+	}
+	for _, ip := range ips {
+		if !allowedIP(ip) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// newClient returns an HTTP client that only connects to allowed addresses and does not follow redirects,
+// so an endpoint cannot bounce deliveries to an internal address
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Control runs on the resolved address of every connection, including each one DNS returns
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicError turns a failed request into the message shown to the store owner. Network details could be used
+// to probe what the API can reach, so they are only logged.
+func publicError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		return ErrBlockedAddress
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("endpoint did not respond in time")
+	default:
+		return errors.New("could not connect to endpoint")
+	}
+}
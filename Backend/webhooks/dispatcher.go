@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"PTS/models"
+	"PTS/utils"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBaseBackoff = 30 * time.Second
+	maxBackoff         = 6 * time.Hour
+	deliveryLease      = 2 * time.Minute
+	deliveryBatchSize  = 20
+)
+
+// Dispatcher sends pending webhook deliveries and retries failed ones with exponential backoff
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+}
+
+// dueDelivery is a claimed delivery together with where and how to send it
+type dueDelivery struct {
+	ID        string
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// NewDispatcher creates a dispatcher with the default retry policy
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Client:      newClient(10 * time.Second),
+		MaxAttempts: defaultMaxAttempts,
+		BaseBackoff: defaultBaseBackoff,
+	}
+}
+
+// Backoff returns the wait before the next attempt after the given number of failed attempts
+func Backoff(base time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
+
+// Run polls for due deliveries every interval and sends them
+func (d *Dispatcher) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.DeliverDue()
+	}
+}
+
+// DeliverDue claims and sends one batch of deliveries whose next attempt is due
+func (d *Dispatcher) DeliverDue() {
+	deliveries, err := d.claimDue()
+	if err != nil {
+		log.Println("Error claiming webhook deliveries:", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(delivery)
+	}
+}
+
+// claimDue leases due deliveries by pushing their next attempt into the future, so that
+// other API instances polling at the same time skip them
+func (d *Dispatcher) claimDue() ([]dueDelivery, error) {
+	now := time.Now()
+	query := `
+        UPDATE webhook_deliveries d SET next_attempt_at = $1
+        FROM webhook_endpoints e
+        WHERE e.id = d.endpoint_id AND d.id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= $2
+            ORDER BY next_attempt_at
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING d.id, d.event_id, d.event_type, d.payload, d.attempts, e.url, e.secret
+    `
+	rows, err := utils.DB.Query(query, now.Add(deliveryLease), now, deliveryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []dueDelivery
+	for rows.Next() {
+		var delivery dueDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.EventID, &delivery.EventType, &delivery.Payload, &delivery.Attempts, &delivery.URL, &delivery.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(delivery dueDelivery) {
+	started := time.Now()
+	statusCode, sendErr := d.send(delivery, started)
+	duration := time.Since(started)
+
+	var responseStatus *int
+	if statusCode != 0 {
+		responseStatus = &statusCode
+	}
+	var errorMessage *string
+	if sendErr != nil {
+		message := sendErr.Error()
+		errorMessage = &message
+	}
+
+	attempts := delivery.Attempts + 1
+	status := models.DeliverySucceeded
+	var nextAttemptAt *time.Time
+	if sendErr != nil {
+		if attempts >= d.MaxAttempts {
+			status = models.DeliveryFailed
+		} else {
+			status = models.DeliveryPending
+			next := time.Now().Add(Backoff(d.BaseBackoff, attempts))
+			nextAttemptAt = &next
+		}
+	}
+
+	attemptQuery := `
+        INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, response_status, error, duration_ms)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	if _, err := utils.DB.Exec(attemptQuery, delivery.ID, started, responseStatus, errorMessage, duration.Milliseconds()); err != nil {
+		log.Println("Error recording webhook attempt:", err)
+	}
+
+	updateQuery := `
+        UPDATE webhook_deliveries
+        SET status = $1, attempts = $2, next_attempt_at = $3, last_response_status = $4, last_error = $5, updated_at = $6
+        WHERE id = $7
+    `
+	if _, err := utils.DB.Exec(updateQuery, status, attempts, nextAttemptAt, responseStatus, errorMessage, time.Now(), delivery.ID); err != nil {
+		log.Println("Error updating webhook delivery:", err)
+	}
+}
+
+// send POSTs the signed payload and returns the response status. Any non-2xx response is an error, including
+// redirects, which are not followed.
+func (d *Dispatcher) send(delivery dueDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PTS-Webhooks/1.0")
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDeliveryID, delivery.ID)
+	req.Header.Set(HeaderEventType, delivery.EventType)
+	req.Header.Set(HeaderSignature, SignatureHeader(delivery.Secret, now.Unix(), delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		log.Printf("Error sending webhook delivery %s: %v", delivery.ID, err)
+		return 0, publicError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusError{code: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// statusError reports a non-2xx response from a webhook endpoint
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("endpoint responded with status %d", e.code)
+}
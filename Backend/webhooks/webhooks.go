@@ -0,0 +1,145 @@
+// Package webhooks notifies stores' own systems about order lifecycle events over signed HTTP callbacks.
+package webhooks
+
+import (
+	"PTS/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types stores can subscribe to
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderAssigned  = "order.assigned"
+	EventOrderPickedUp  = "order.picked_up"
+	EventOrderInTransit = "order.in_transit"
+	EventOrderDelivered = "order.delivered"
+	EventOrderCancelled = "order.cancelled"
+	// A delivery attempt failed and the order was moved to a later window
+	EventOrderRescheduled = "order.rescheduled"
+	EventOrderReturning   = "order.returning"
+	EventOrderReturned    = "order.returned"
+	EventPing             = "ping"
+)
+
+// Events lists every event type an endpoint can subscribe to
+var Events = []string{
+	EventOrderCreated,
+	EventOrderAssigned,
+	EventOrderPickedUp,
+	EventOrderInTransit,
+	EventOrderDelivered,
+	EventOrderCancelled,
+	EventOrderRescheduled,
+	EventOrderReturning,
+	EventOrderReturned,
+}
+
+// Headers sent with every delivery
+const (
+	HeaderEventID    = "X-PTS-Event-Id"
+	HeaderDeliveryID = "X-PTS-Delivery-Id"
+	HeaderEventType  = "X-PTS-Event"
+	HeaderSignature  = "X-PTS-Signature"
+)
+
+// Payload is the JSON body POSTed to webhook endpoints
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	StoreId   string      `json:"store_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// IsValidEvent reports whether an endpoint can subscribe to the event type
+func IsValidEvent(event string) bool {
+	for _, known := range Events {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSecret creates a random signing secret for a new endpoint
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the endpoint's secret.
+// Receivers verify a delivery by recomputing it from the t= and v1= parts of the X-PTS-Signature header.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeader builds the X-PTS-Signature header value for a body signed at the given time
+func SignatureHeader(secret string, timestamp int64, body []byte) string {
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + Sign(secret, timestamp, body)
+}
+
+// Enqueue records a delivery of the event for every active endpoint of the store subscribed to it.
+// eventID becomes the payload ID; enqueueing the same event again does not add duplicate deliveries.
+// The dispatcher sends the deliveries in the background.
+func Enqueue(eventID, storeID, eventType string, occurredAt time.Time, data interface{}) error {
+	payload := Payload{
+		ID:        eventID,
+		Type:      eventType,
+		StoreId:   storeID,
+		CreatedAt: occurredAt,
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+        SELECT id, $1::UUID, $2::TEXT, $3::JSONB, 'pending', $4::TIMESTAMP, $4::TIMESTAMP, $4::TIMESTAMP
+        FROM webhook_endpoints
+        WHERE store_id = $5 AND active AND $2 = ANY(events)
+        ON CONFLICT (endpoint_id, event_id) DO NOTHING
+    `
+	_, err = utils.DB.Exec(query, payload.ID, eventType, body, time.Now(), storeID)
+	return err
+}
+
+// EnqueueForEndpoint records a delivery of the event for a single endpoint regardless of its filters
+func EnqueueForEndpoint(endpointID, storeID, eventType string, data interface{}) (string, error) {
+	payload := Payload{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		StoreId:   storeID,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	var deliveryID string
+	query := `
+        INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, 'pending', $5, $5, $5)
+        RETURNING id
+    `
+	err = utils.DB.QueryRow(query, endpointID, payload.ID, eventType, body, payload.CreatedAt).Scan(&deliveryID)
+	return deliveryID, err
+}
@@ -0,0 +1,215 @@
+package windows
+
+import (
+	"PTS/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrFull is returned when a window has no capacity left
+var ErrFull = errors.New("delivery window is full")
+
+// Rejection explains why an order cannot be scheduled into the requested window
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return r.Reason
+}
+
+// Availability is a slot with its capacity and how much of it is booked. Capacity and Remaining are
+// left out for unlimited windows.
+type Availability struct {
+	Slot
+	Capacity  *int `json:"capacity,omitempty"`
+	Booked    int  `json:"booked"`
+	Remaining *int `json:"remaining,omitempty"`
+	Available bool `json:"available"`
+}
+
+// ForStore returns the store's schedule, or DefaultSchedule if it has not set one
+func ForStore(storeID string) (Schedule, error) {
+	var schedule Schedule
+	var body []byte
+	var updatedAt time.Time
+	err := utils.DB.QueryRow("SELECT schedule, updated_at FROM delivery_schedules WHERE store_id = $1", storeID).Scan(&body, &updatedAt)
+	if err == sql.ErrNoRows {
+		return DefaultSchedule(), nil
+	}
+	if err != nil {
+		return Schedule{}, err
+	}
+	if err := json.Unmarshal(body, &schedule); err != nil {
+		return Schedule{}, err
+	}
+	schedule.UpdatedAt = &updatedAt
+	return schedule, nil
+}
+
+// SaveSchedule replaces the store's schedule. Bookings already made keep their slots.
+func SaveSchedule(storeID, updatedBy string, schedule Schedule) (Schedule, error) {
+	now := time.Now()
+	schedule.UpdatedAt = nil
+	if schedule.Days == nil {
+		schedule.Days = map[string][]Window{}
+	}
+	body, err := json.Marshal(schedule)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	query := `
+        INSERT INTO delivery_schedules (store_id, schedule, updated_by, updated_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (store_id) DO UPDATE SET schedule = EXCLUDED.schedule, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+    `
+	if _, err := utils.DB.Exec(query, storeID, body, updatedBy, now); err != nil {
+		return Schedule{}, err
+	}
+	schedule.UpdatedAt = &now
+	return schedule, nil
+}
+
+// courierCount is the number of couriers working for the store, which OrdersPerCourier capacity scales with
+func courierCount(storeID string) (int, error) {
+	var count int
+	err := utils.DB.QueryRow("SELECT COUNT(*) FROM couriers WHERE store_id = $1", storeID).Scan(&count)
+	return count, err
+}
+
+// Resolve finds the slot an order for the window on date (YYYY-MM-DD) goes into, with its capacity.
+// Without a date it takes the first day in the coming week on which the window has not yet ended.
+func Resolve(storeID, window, date string, now time.Time) (Slot, *int, error) {
+	schedule, err := ForStore(storeID)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+
+	var slot Slot
+	var found Window
+	if date != "" {
+		day, err := time.ParseInLocation(time.DateOnly, date, Location)
+		if err != nil {
+			return Slot{}, nil, &Rejection{"delivery_date must be a date (YYYY-MM-DD)"}
+		}
+		var ok bool
+		if found, ok = schedule.Find(window, day); !ok {
+			return Slot{}, nil, &Rejection{"the store does not deliver in the " + window + " window on " + date}
+		}
+		if slot = found.On(day); !now.Before(slot.End) {
+			return Slot{}, nil, &Rejection{"the " + window + " window on " + date + " has already ended"}
+		}
+	} else {
+		year, month, today := now.In(Location).Date()
+		for offset := 0; offset < 7 && slot.Window == ""; offset++ {
+			day := time.Date(year, month, today+offset, 12, 0, 0, 0, Location)
+			if candidate, ok := schedule.Find(window, day); ok && now.Before(candidate.On(day).End) {
+				found, slot = candidate, candidate.On(day)
+			}
+		}
+		if slot.Window == "" {
+			return Slot{}, nil, &Rejection{"the store does not deliver in the " + window + " window in the coming week"}
+		}
+	}
+
+	couriers, err := courierCount(storeID)
+	if err != nil {
+		return Slot{}, nil, err
+	}
+	return slot, found.Capacity(couriers), nil
+}
+
+// Reserve books one order into the slot within tx, returning ErrFull if capacity is used up.
+// The check and increment are a single statement, so concurrent checkouts cannot overbook.
+// A nil capacity books without a limit, for unlimited windows and rescheduled deliveries.
+func Reserve(tx *sql.Tx, storeID string, slot Slot, capacity *int) error {
+	if capacity != nil && *capacity <= 0 {
+		return ErrFull
+	}
+
+	var booked int
+	query := `
+        INSERT INTO delivery_window_bookings (store_id, delivery_date, window_name, booked)
+        VALUES ($1, $2, $3, 1)
+        ON CONFLICT (store_id, delivery_date, window_name) DO UPDATE SET booked = delivery_window_bookings.booked + 1
+        WHERE $4::INTEGER IS NULL OR delivery_window_bookings.booked < $4::INTEGER
+        RETURNING booked
+    `
+	err := tx.QueryRow(query, storeID, slot.Date, slot.Window, capacity).Scan(&booked)
+	if err == sql.ErrNoRows {
+		return ErrFull
+	}
+	return err
+}
+
+// Release gives back an order's booking, when it is cancelled before delivery
+func Release(tx *sql.Tx, storeID, date, window string) error {
+	query := `
+        UPDATE delivery_window_bookings SET booked = booked - 1
+        WHERE store_id = $1 AND delivery_date = $2 AND window_name = $3 AND booked > 0
+    `
+	_, err := tx.Exec(query, storeID, date, window)
+	return err
+}
+
+// StoreAvailability lists the store's slots for days starting on from, with what is left of each.
+// Slots that have ended or are full are included but not available.
+func StoreAvailability(storeID string, from time.Time, days int, now time.Time) ([]Availability, error) {
+	schedule, err := ForStore(storeID)
+	if err != nil {
+		return nil, err
+	}
+	couriers, err := courierCount(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	year, month, first := from.In(Location).Date()
+	start := time.Date(year, month, first, 0, 0, 0, 0, Location)
+	last := start.AddDate(0, 0, days-1)
+
+	booked := map[string]int{}
+	query := `
+        SELECT to_char(delivery_date, 'YYYY-MM-DD'), window_name, booked FROM delivery_window_bookings
+        WHERE store_id = $1 AND delivery_date BETWEEN $2 AND $3
+    `
+	rows, err := utils.DB.Query(query, storeID, start.Format(time.DateOnly), last.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date, window string
+		var count int
+		if err := rows.Scan(&date, &window, &count); err != nil {
+			return nil, err
+		}
+		booked[date+"/"+window] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := []Availability{}
+	for offset := 0; offset < days; offset++ {
+		day := time.Date(year, month, first+offset, 12, 0, 0, 0, Location)
+		for _, window := range schedule.WindowsOn(day) {
+			slot := window.On(day)
+			entry := Availability{Slot: slot, Capacity: window.Capacity(couriers), Booked: booked[slot.Date+"/"+slot.Window]}
+			entry.Available = now.Before(slot.End)
+			if entry.Capacity != nil {
+				remaining := *entry.Capacity - entry.Booked
+				if remaining < 0 {
+					remaining = 0
+				}
+				entry.Remaining = &remaining
+				entry.Available = entry.Available && remaining > 0
+			}
+			list = append(list, entry)
+		}
+	}
+	return list, nil
+}
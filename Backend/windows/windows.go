@@ -0,0 +1,170 @@
+// Package windows defines the delivery windows orders are scheduled into, each store's weekly schedule
+// of windows with their capacity, and the bookings that fill them.
+package windows
+
+import (
+	"PTS/utils"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Window is a named part of the day during which deliveries are made, in the delivery timezone.
+// Capacity is MaxOrders, or OrdersPerCourier times the store's couriers, whichever is lower;
+// a window with neither is unlimited.
+type Window struct {
+	Name             string `json:"name"`
+	StartHour        int    `json:"start_hour"`
+	EndHour          int    `json:"end_hour"`
+	MaxOrders        *int   `json:"max_orders,omitempty"`
+	OrdersPerCourier *int   `json:"orders_per_courier,omitempty"`
+}
+
+// Defaults are the windows offered by PlaceOrderComponent, in the order they occur during the day.
+// Stores that have not set a schedule offer them every day without a capacity limit.
+var Defaults = []Window{
+	{Name: "morning", StartHour: 8, EndHour: 12},
+	{Name: "midDay", StartHour: 12, EndHour: 17},
+	{Name: "night", StartHour: 17, EndHour: 21},
+}
+
+// Location is the timezone delivery windows and dates are expressed in
+var Location = loadLocation(utils.GetEnv("DELIVERY_TIMEZONE", "Africa/Cairo"))
+
+func loadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Invalid DELIVERY_TIMEZONE (%q), using UTC", name)
+		return time.UTC
+	}
+	return location
+}
+
+// Slot is one window on a specific date
+type Slot struct {
+	Window string    `json:"window"`
+	Date   string    `json:"date"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// On returns the window's slot on the day containing t
+func (w Window) On(t time.Time) Slot {
+	day := t.In(Location)
+	year, month, date := day.Date()
+	return Slot{
+		Window: w.Name,
+		Date:   day.Format(time.DateOnly),
+		Start:  time.Date(year, month, date, w.StartHour, 0, 0, 0, Location),
+		End:    time.Date(year, month, date, w.EndHour, 0, 0, 0, Location),
+	}
+}
+
+// Capacity returns how many orders the window takes when the store has couriers, or nil if it is unlimited
+func (w Window) Capacity(couriers int) *int {
+	var capacity *int
+	if w.MaxOrders != nil {
+		limit := *w.MaxOrders
+		capacity = &limit
+	}
+	if w.OrdersPerCourier != nil {
+		limit := *w.OrdersPerCourier * couriers
+		if capacity == nil || limit < *capacity {
+			capacity = &limit
+		}
+	}
+	return capacity
+}
+
+// Weekdays are the keys of Schedule.Days
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// Schedule is a store's delivery windows for each day of the week. Days left out have no deliveries.
+type Schedule struct {
+	Days      map[string][]Window `json:"days"`
+	UpdatedAt *time.Time          `json:"updated_at,omitempty"`
+}
+
+// DefaultSchedule offers the default windows every day
+func DefaultSchedule() Schedule {
+	schedule := Schedule{Days: map[string][]Window{}}
+	for _, day := range Weekdays {
+		schedule.Days[day] = Defaults
+	}
+	return schedule
+}
+
+// Validate checks the schedule names real weekdays and its windows are well-formed and do not overlap
+func (s Schedule) Validate() error {
+	for day, windows := range s.Days {
+		if !isWeekday(day) {
+			return fmt.Errorf("unknown weekday %q", day)
+		}
+
+		names := map[string]bool{}
+		sorted := append([]Window(nil), windows...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartHour < sorted[j].StartHour })
+		for i, window := range sorted {
+			if strings.TrimSpace(window.Name) == "" {
+				return errors.New("every window needs a name")
+			}
+			if names[window.Name] {
+				return fmt.Errorf("window %q appears twice on %s", window.Name, day)
+			}
+			names[window.Name] = true
+			if window.StartHour < 0 || window.EndHour > 24 || window.StartHour >= window.EndHour {
+				return fmt.Errorf("window %q on %s must start before it ends, between hours 0 and 24", window.Name, day)
+			}
+			if i > 0 && window.StartHour < sorted[i-1].EndHour {
+				return fmt.Errorf("windows %q and %q overlap on %s", sorted[i-1].Name, window.Name, day)
+			}
+			if (window.MaxOrders != nil && *window.MaxOrders < 0) || (window.OrdersPerCourier != nil && *window.OrdersPerCourier < 0) {
+				return fmt.Errorf("capacity of window %q on %s must not be negative", window.Name, day)
+			}
+		}
+	}
+	return nil
+}
+
+func isWeekday(day string) bool {
+	for _, weekday := range Weekdays {
+		if weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// WindowsOn returns the windows offered on the day containing t, in the order they occur
+func (s Schedule) WindowsOn(t time.Time) []Window {
+	windows := append([]Window(nil), s.Days[Weekdays[t.In(Location).Weekday()]]...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i].StartHour < windows[j].StartHour })
+	return windows
+}
+
+// Find returns the window with the given name on the day containing t
+func (s Schedule) Find(name string, t time.Time) (Window, bool) {
+	for _, window := range s.Days[Weekdays[t.In(Location).Weekday()]] {
+		if window.Name == name {
+			return window, true
+		}
+	}
+	return Window{}, false
+}
+
+// NextAfter returns the first window that starts after t within the next two weeks
+func (s Schedule) NextAfter(t time.Time) (Slot, Window, bool) {
+	year, month, date := t.In(Location).Date()
+	for offset := 0; offset < 14; offset++ {
+		day := time.Date(year, month, date+offset, 12, 0, 0, 0, Location)
+		for _, window := range s.WindowsOn(day) {
+			if slot := window.On(day); slot.Start.After(t) {
+				return slot, window, true
+			}
+		}
+	}
+	return Slot{}, Window{}, false
+}
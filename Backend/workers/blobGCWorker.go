@@ -0,0 +1,81 @@
+package workers
+
+import (
+	"PTS/blobstore"
+	"PTS/utils"
+	"log"
+	"time"
+)
+
+// blobPrefixes are the parts of the store the collector owns; anything else in the bucket is left alone.
+// Add the prefix of new attachment kinds here along with their columns in blobReferences.
+var blobPrefixes = []string{"pod/"}
+
+// blobReferences selects every blob key still referenced from the database under blobPrefixes
+const blobReferences = `
+    SELECT signature_key FROM proofs_of_delivery WHERE signature_key IS NOT NULL
+    UNION ALL
+    SELECT photo_key FROM proofs_of_delivery WHERE photo_key IS NOT NULL
+`
+
+// StartBlobGC periodically deletes attachment blobs under blobPrefixes that nothing in the database refers to, such as uploads
+// whose request failed before they were recorded. Blobs younger than grace are kept so uploads still in
+// progress are not collected.
+func StartBlobGC(interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		removed, err := collectBlobs(time.Now().Add(-grace))
+		if err != nil {
+			log.Println("Error collecting orphaned blobs:", err)
+			continue
+		}
+		if removed > 0 {
+			log.Printf("Removed %d orphaned blob(s)", removed)
+		}
+	}
+}
+
+func collectBlobs(cutoff time.Time) (int, error) {
+	// List before loading references, so a blob recorded in between is seen as referenced
+	var objects []blobstore.Object
+	for _, prefix := range blobPrefixes {
+		listed, err := blobstore.Default.List(prefix)
+		if err != nil {
+			return 0, err
+		}
+		objects = append(objects, listed...)
+	}
+
+	rows, err := utils.DB.Query(blobReferences)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	referenced := map[string]bool{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return 0, err
+		}
+		referenced[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, object := range objects {
+		if referenced[object.Key] || object.ModifiedAt.After(cutoff) {
+			continue
+		}
+		if err := blobstore.Default.Delete(object.Key); err != nil {
+			log.Println("Error deleting orphaned blob", object.Key+":", err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
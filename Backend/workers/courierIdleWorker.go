@@ -0,0 +1,26 @@
+package workers
+
+import (
+	"PTS/utils"
+	"log"
+	"time"
+)
+
+// StartCourierIdleSweeper periodically marks couriers unavailable once they have not sent a
+// heartbeat for longer than idleTimeout. They stay on shift and can go online again.
+func StartCourierIdleSweeper(interval, idleTimeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-idleTimeout)
+		result, err := utils.DB.Exec("UPDATE couriers SET available = FALSE WHERE available AND last_active_at < $1", cutoff)
+		if err != nil {
+			log.Println("Error marking idle couriers unavailable:", err)
+			continue
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			log.Printf("Marked %d idle courier(s) unavailable", rows)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package workers
+
+import (
+	"PTS/utils"
+	"log"
+	"time"
+)
+
+// StartLocationRetentionWorker periodically deletes courier location pings older than retention
+func StartLocationRetentionWorker(interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result, err := utils.DB.Exec("DELETE FROM courier_locations WHERE recorded_at < $1", time.Now().Add(-retention))
+		if err != nil {
+			log.Println("Error purging old courier locations:", err)
+			continue
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			log.Printf("Purged %d expired courier location ping(s)", rows)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package workers
+
+import (
+	"PTS/payments"
+	"log"
+	"time"
+)
+
+// StartPaymentSettlementWorker periodically retries releasing the payments of cancelled orders whose void or
+// refund did not go through when the order was cancelled
+func StartPaymentSettlementWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settled, err := payments.SettleCancellations()
+		if err != nil {
+			log.Println("Error settling cancelled payments:", err)
+			continue
+		}
+		if settled > 0 {
+			log.Printf("Released %d cancelled order payment(s)", settled)
+		}
+	}
+}
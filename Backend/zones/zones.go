@@ -0,0 +1,117 @@
+// Package zones limits where a store delivers. A store with no active zones delivers anywhere;
+// otherwise the drop-off must fall inside one of them, which is checked in Go so PostGIS is not needed.
+package zones
+
+import (
+	"PTS/geo"
+	"PTS/utils"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Zone is an area a store delivers to
+type Zone struct {
+	ID      string       `json:"id"`
+	StoreId string       `json:"store_id"`
+	Name    string       `json:"name"`
+	Area    geo.Geometry `json:"area"`
+	// Active zones are used to accept orders; inactive ones are kept for later
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	shape geo.Shape
+}
+
+// OutsideError is returned when a point is outside all of a store's zones
+type OutsideError struct {
+	// Nearest is the closest zone and DistanceKm how far the point is from its edge
+	Nearest    string
+	DistanceKm float64
+}
+
+func (e *OutsideError) Error() string {
+	return fmt.Sprintf("the store does not deliver here; its nearest delivery area, %s, is %.1f km away", e.Nearest, e.DistanceKm)
+}
+
+// Columns are the zone columns read by Scan
+const Columns = "id, store_id, name, area, active, created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan reads a row selected with Columns into a zone
+func Scan(row rowScanner) (Zone, error) {
+	var zone Zone
+	var area []byte
+	if err := row.Scan(&zone.ID, &zone.StoreId, &zone.Name, &area, &zone.Active, &zone.CreatedAt, &zone.UpdatedAt); err != nil {
+		return Zone{}, err
+	}
+	if err := json.Unmarshal(area, &zone.Area); err != nil {
+		return Zone{}, err
+	}
+	return zone, nil
+}
+
+// ForStore returns the store's zones by name, or only the active ones
+func ForStore(storeID string, activeOnly bool) ([]Zone, error) {
+	query := "SELECT " + Columns + " FROM service_zones WHERE store_id = $1 AND (active OR NOT $2) ORDER BY name"
+	rows, err := utils.DB.Query(query, storeID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []Zone{}
+	for rows.Next() {
+		zone, err := Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, rows.Err()
+}
+
+// Shape parses the zone's area
+func (z *Zone) Shape() (geo.Shape, error) {
+	if z.shape == nil {
+		shape, err := z.Area.Shape()
+		if err != nil {
+			return nil, err
+		}
+		z.shape = shape
+	}
+	return z.shape, nil
+}
+
+// Locate finds the active zone of the store containing the point. It returns nil without an error if the
+// store has no active zones, and an *OutsideError if the point is outside all of them. Where zones overlap
+// the first by name wins.
+func Locate(storeID string, point geo.Point) (*Zone, error) {
+	zones, err := ForStore(storeID, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	var outside *OutsideError
+	for i := range zones {
+		shape, err := zones[i].Shape()
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zones[i].ID, err)
+		}
+		distance := shape.DistanceKm(point)
+		if distance == 0 {
+			return &zones[i], nil
+		}
+		if outside == nil || distance < outside.DistanceKm {
+			outside = &OutsideError{Nearest: zones[i].Name, DistanceKm: distance}
+		}
+	}
+	return nil, outside
+}